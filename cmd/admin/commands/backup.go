@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/backup"
+)
+
+// Backup copies the database to a file, or into a directory under a name
+// with the current time. It is safe while the web server runs.
+func Backup(dbName, path string) error {
+	if path == "" {
+		return errors.New("path of the backup is required")
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, backup.Name(time.Now()))
+	}
+
+	return withDB(dbName, func(db *sqlx.DB) error {
+		if err := backup.Create(context.Background(), db, path); err != nil {
+			return err
+		}
+		if err := backup.Check(path); err != nil {
+			return err
+		}
+
+		fmt.Printf("backed up %s to %s\n", dbName, path)
+		return nil
+	})
+}
+
+// Restore replaces the database with a backup after checking its integrity.
+// The web server must be stopped first.
+func Restore(dbName, path string) error {
+	if path == "" {
+		return errors.New("path of the backup is required")
+	}
+
+	if err := backup.Restore(path, dbName); err != nil {
+		return err
+	}
+
+	fmt.Printf("restored %s from %s, the previous database is %s.before-restore\n", dbName, path, dbName)
+	return nil
+}
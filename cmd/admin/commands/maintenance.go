@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+)
+
+// Maintenance checks and repairs the database and prints what it found and
+// changed. A dry run prints what it would change.
+func Maintenance(dbName string, dryRun bool) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		r, err := schema.Maintain(db, dryRun)
+		if err != nil {
+			return errors.Wrap(err, "maintain database")
+		}
+
+		verb := "removed"
+		if dryRun {
+			verb = "would remove"
+		}
+		fmt.Println("integrity check: ok")
+		fmt.Printf("foreign key check: %d violations\n", len(r.Violations))
+		var order []string
+		counts := make(map[string]int)
+		for _, v := range r.Violations {
+			key := v.Table + " rows point to missing " + v.Parent + " rows"
+			if counts[key] == 0 {
+				order = append(order, key)
+			}
+			counts[key]++
+		}
+		for _, key := range order {
+			fmt.Printf("  %d %s\n", counts[key], key)
+		}
+		fmt.Printf("%s %d dangling tag links\n", verb, r.DanglingMaybeTags)
+		fmt.Printf("%s %d orphaned tags\n", verb, r.OrphanedTags)
+		if dryRun {
+			fmt.Println("would analyze and vacuum the database")
+			return nil
+		}
+		fmt.Printf("analyzed and vacuumed the database, %d KiB before, %d KiB after\n", r.SizeBefore/1024, r.SizeAfter/1024)
+		return nil
+	})
+}
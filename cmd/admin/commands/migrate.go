@@ -2,7 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
@@ -10,16 +14,105 @@ import (
 
 // Migrate creates the schema in the database.
 func Migrate(dbName string) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		if err := schema.Migrate(db); err != nil {
+			return errors.Wrap(err, "migrate database")
+		}
+
+		fmt.Println("migrations complete")
+		return nil
+	})
+}
+
+// MigrateStatus prints which migrations are applied and fails if an applied
+// one was edited or removed since.
+func MigrateStatus(dbName string) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		versions, err := schema.Status(db)
+		if err != nil {
+			return errors.Wrap(err, "migration status")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tDESCRIPTION\tSTATUS\tAPPLIED")
+		for _, v := range versions {
+			applied := "-"
+			if v.Applied {
+				applied = formatTime(&v.AppliedAt)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", v.Version, v.Description, versionStatus(v), applied)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		return schema.Validate(db)
+	})
+}
+
+// MigrateDryRun prints the scripts of the migrations Migrate would apply
+// without running them.
+func MigrateDryRun(dbName string) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		pending, err := schema.Pending(db)
+		if err != nil {
+			return errors.Wrap(err, "pending migrations")
+		}
+		if len(pending) == 0 {
+			fmt.Println("no pending migrations")
+			return nil
+		}
+
+		for _, v := range pending {
+			fmt.Printf("-- %d: %s\n%s\n\n", v.Version, v.Description, strings.TrimSpace(v.Script))
+		}
+		return nil
+	})
+}
+
+// Rollback undoes all migrations newer than a version.
+func Rollback(dbName string, version int) error {
+	if version < 0 {
+		return errors.New("version to roll back to is required")
+	}
+
+	return withDB(dbName, func(db *sqlx.DB) error {
+		versions, err := schema.Rollback(db, version)
+		if err != nil {
+			return errors.Wrap(err, "roll back database")
+		}
+		if len(versions) == 0 {
+			fmt.Printf("nothing newer than version %d to roll back\n", version)
+			return nil
+		}
+
+		for _, v := range versions {
+			fmt.Printf("rolled back migration %d\n", v)
+		}
+		return nil
+	})
+}
+
+// withDB runs fn with a connection to the database.
+func withDB(dbName string, fn func(db *sqlx.DB) error) error {
 	db, err := database.New(dbName)
 	if err != nil {
 		return errors.Wrap(err, "could not connect to database")
 	}
 	defer db.Close()
 
-	if err := schema.Migrate(db); err != nil {
-		return errors.Wrap(err, "migrate database")
-	}
+	return fn(db)
+}
 
-	fmt.Println("migrations complete")
-	return nil
+func versionStatus(v schema.Version) string {
+	switch {
+	case v.Removed:
+		return "removed"
+	case v.Modified:
+		return "modified"
+	case v.Applied:
+		return "applied"
+	default:
+		return "pending"
+	}
 }
@@ -1,25 +1,47 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
-	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+	"github.com/sophiabrandt/go-maybe-list/internal/fake"
 )
 
 // Seed loads test data into the database.
 func Seed(dbName string) error {
-	db, err := database.New(dbName)
-	if err != nil {
-		return errors.Wrap(err, "could not connect to database")
-	}
-	defer db.Close()
+	return withDB(dbName, func(db *sqlx.DB) error {
+		if err := schema.Seed(db); err != nil {
+			return errors.Wrap(err, "seed database")
+		}
 
-	if err := schema.Seed(db); err != nil {
-		return errors.Wrap(err, "seed database")
-	}
+		fmt.Println("seed data complete")
+		return nil
+	})
+}
+
+// Generate loads generated users, maybes and tags into the database. The data
+// only depends on the counts and the seed, so the same dataset can be loaded
+// again anywhere.
+func Generate(dbName string, users, maybes, tags int, seed int64) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		start := time.Now()
+		stats, err := fake.Generate(context.Background(), db, fake.Options{
+			Users:  users,
+			Maybes: maybes,
+			Tags:   tags,
+			Seed:   seed,
+			End:    time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			return errors.Wrap(err, "generate data")
+		}
 
-	fmt.Println("seed data complete")
-	return nil
+		fmt.Printf("added %d users, %d maybes, %d tags and %d tag links in %s, users log in with the password %q\n",
+			stats.Users, stats.Maybes, stats.Tags, stats.MaybeTags, time.Since(start).Round(time.Millisecond), fake.Password)
+		return nil
+	})
 }
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/stats"
+)
+
+// Stats prints how the instance is used.
+func Stats(dbName, format string, weeks int) error {
+	if weeks < 1 {
+		return errors.New("at least one week is required")
+	}
+
+	return withDB(dbName, func(db *sqlx.DB) error {
+		info, err := stats.New(db).Query(time.Now(), weeks, 10)
+		if err != nil {
+			return errors.Wrap(err, "query stats")
+		}
+		if format == FormatJSON {
+			return printJSON(info)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Users\t%d\n", info.Users.Total)
+		fmt.Fprintf(w, "Active\t%d\n", info.Users.Active)
+		fmt.Fprintf(w, "Verified\t%d\n", info.Users.Verified)
+		fmt.Fprintf(w, "Two-factor\t%d\n", info.Users.TwoFactor)
+		fmt.Fprintf(w, "Admins\t%d\n", info.Users.Admins)
+		fmt.Fprintf(w, "Pending deletion\t%d\n", info.Users.PendingDeletion)
+		fmt.Fprintf(w, "Maybes\t%d\n", info.Totals.Maybes)
+		fmt.Fprintf(w, "Tags\t%d\n", info.Totals.Tags)
+		fmt.Fprintf(w, "Comments\t%d\n", info.Totals.Comments)
+		fmt.Fprintf(w, "Workspaces\t%d\n", info.Totals.Workspaces)
+		fmt.Fprintf(w, "Database size\t%d KiB\n", info.DatabaseSize/1024)
+
+		fmt.Fprintln(w, "\nWEEK OF\tACTIVE USERS\tMAYBES CREATED")
+		for i, week := range info.ActiveUsers {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", week.Start.Format("2006-01-02"), week.Count, info.MaybesCreated[i].Count)
+		}
+
+		fmt.Fprintln(w, "\nTAG\tMAYBES")
+		for _, c := range info.TopTags {
+			fmt.Fprintf(w, "%s\t%d\n", c.Name, c.Count)
+		}
+
+		fmt.Fprintln(w, "\nDOMAIN\tMAYBES")
+		for _, c := range info.TopDomains {
+			fmt.Fprintf(w, "%s\t%d\n", c.Name, c.Count)
+		}
+		return w.Flush()
+	})
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+)
+
+// Unlock lifts the lockout of a user after too many failed logins.
+func Unlock(dbName, email string) error {
+	if email == "" {
+		return errors.New("email address of the user is required")
+	}
+
+	db, err := database.New(dbName)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to database")
+	}
+	defer db.Close()
+
+	if err := user.New(db).Unlock(email); err != nil {
+		return errors.Wrapf(err, "unlock %s", email)
+	}
+
+	fmt.Printf("unlocked %s\n", email)
+	return nil
+}
+
+// FailedLogins prints the latest failed logins for an email address.
+func FailedLogins(dbName, email string) error {
+	if email == "" {
+		return errors.New("email address is required")
+	}
+
+	db, err := database.New(dbName)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to database")
+	}
+	defer db.Close()
+
+	attempts, err := user.New(db).QueryFailedLogins(email, 50)
+	if err != nil {
+		return errors.Wrapf(err, "failed logins of %s", email)
+	}
+
+	for _, a := range attempts {
+		fmt.Printf("%s\t%s\t%s\n", a.CreatedAt.UTC().Format("2006-01-02 15:04:05"), a.IP, a.Reason)
+	}
+	fmt.Printf("%d failed logins\n", len(attempts))
+	return nil
+}
@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+)
+
+// Output formats of the user commands.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// account is what operators get to see of a user.
+type account struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Active      bool       `json:"active"`
+	Verified    bool       `json:"verified"`
+	TwoFactor   bool       `json:"two_factor"`
+	LockedUntil *time.Time `json:"locked_until"`
+	DeleteAt    *time.Time `json:"delete_at"`
+	CreatedAt   string     `json:"created_at"`
+}
+
+func toAccount(usr user.Info) account {
+	return account{
+		ID:          usr.ID,
+		Name:        usr.Name,
+		Email:       usr.Email,
+		Role:        usr.Role,
+		Active:      usr.Active,
+		Verified:    usr.VerifiedAt != nil,
+		TwoFactor:   usr.TOTPEnabledAt != nil,
+		LockedUntil: usr.LockedUntil,
+		DeleteAt:    usr.DeleteAt,
+		CreatedAt:   usr.DateCreated,
+	}
+}
+
+// ListUsers prints all users.
+func ListUsers(dbName, format string) error {
+	return withUsers(dbName, func(ur user.UserRepository) error {
+		users, err := ur.QueryAll()
+		if err != nil {
+			return err
+		}
+
+		accounts := make([]account, 0, len(users))
+		for _, usr := range users {
+			accounts = append(accounts, toAccount(usr))
+		}
+		return printAccounts(format, accounts)
+	})
+}
+
+// ShowUser prints the user with an email address.
+func ShowUser(dbName, format, email string) error {
+	return withAccount(dbName, email, func(ur user.UserRepository, usr user.Info) error {
+		if format == FormatJSON {
+			return printJSON(toAccount(usr))
+		}
+
+		a := toAccount(usr)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\t%s\n", a.ID)
+		fmt.Fprintf(w, "Name\t%s\n", a.Name)
+		fmt.Fprintf(w, "Email\t%s\n", a.Email)
+		fmt.Fprintf(w, "Role\t%s\n", a.Role)
+		fmt.Fprintf(w, "Active\t%t\n", a.Active)
+		fmt.Fprintf(w, "Verified\t%t\n", a.Verified)
+		fmt.Fprintf(w, "Two-factor\t%t\n", a.TwoFactor)
+		fmt.Fprintf(w, "Locked until\t%s\n", formatTime(a.LockedUntil))
+		fmt.Fprintf(w, "Deleted on\t%s\n", formatTime(a.DeleteAt))
+		fmt.Fprintf(w, "Joined\t%s\n", a.CreatedAt)
+		return w.Flush()
+	})
+}
+
+// CreateUser adds a verified user. Without a password, a random one is
+// generated and printed to stderr.
+func CreateUser(dbName, format, name, email, password string) error {
+	if name == "" || email == "" {
+		return errors.New("name and email address of the user are required")
+	}
+
+	return withUsers(dbName, func(ur user.UserRepository) error {
+		password, generated, err := passwordOrRandom(password)
+		if err != nil {
+			return err
+		}
+
+		usr, err := ur.Create(user.NewUser{Name: name, Email: email, Password: password})
+		if err != nil {
+			return errors.Wrapf(err, "create %s", email)
+		}
+		if err := ur.Verify(usr.ID); err != nil {
+			return err
+		}
+		usr, err = ur.QueryByID(usr.ID)
+		if err != nil {
+			return err
+		}
+
+		if generated {
+			printPassword(email, password)
+		}
+		return printAccounts(format, []account{toAccount(usr)})
+	})
+}
+
+// SetActive deactivates or reactivates the user with an email address.
+func SetActive(dbName, email string, active bool) error {
+	return withAccount(dbName, email, func(ur user.UserRepository, usr user.Info) error {
+		if err := ur.SetActive(usr.ID, active); err != nil {
+			return errors.Wrapf(err, "update %s", email)
+		}
+
+		if active {
+			fmt.Printf("reactivated %s\n", email)
+		} else {
+			fmt.Printf("deactivated %s\n", email)
+		}
+		return nil
+	})
+}
+
+// DeleteUser deletes the user with an email address and all their data right away.
+func DeleteUser(dbName, email string) error {
+	return withAccount(dbName, email, func(ur user.UserRepository, usr user.Info) error {
+		if err := ur.Delete(usr.ID); err != nil {
+			return errors.Wrapf(err, "delete %s", email)
+		}
+
+		fmt.Printf("deleted %s\n", email)
+		return nil
+	})
+}
+
+// ResetPassword replaces the password of the user with an email address and
+// logs them out. Without a password, a random one is generated and printed to
+// stderr.
+func ResetPassword(dbName, email, password string) error {
+	return withAccount(dbName, email, func(ur user.UserRepository, usr user.Info) error {
+		password, generated, err := passwordOrRandom(password)
+		if err != nil {
+			return err
+		}
+
+		if err := ur.SetPassword(usr.ID, password); err != nil {
+			return errors.Wrapf(err, "reset password of %s", email)
+		}
+
+		if generated {
+			printPassword(email, password)
+		}
+		fmt.Printf("reset password of %s\n", email)
+		return nil
+	})
+}
+
+// Promote makes the user with an email address an admin.
+func Promote(dbName, email string) error {
+	return withAccount(dbName, email, func(ur user.UserRepository, usr user.Info) error {
+		if err := ur.SetRole(usr.ID, user.RoleAdmin); err != nil {
+			return errors.Wrapf(err, "promote %s", email)
+		}
+
+		fmt.Printf("promoted %s to admin\n", email)
+		return nil
+	})
+}
+
+// withUsers runs fn with the user repository of the database.
+func withUsers(dbName string, fn func(ur user.UserRepository) error) error {
+	return withDB(dbName, func(db *sqlx.DB) error {
+		return fn(user.New(db))
+	})
+}
+
+// withAccount runs fn with the user with an email address, active or not.
+func withAccount(dbName, email string, fn func(ur user.UserRepository, usr user.Info) error) error {
+	if email == "" {
+		return errors.New("email address of the user is required")
+	}
+
+	return withUsers(dbName, func(ur user.UserRepository) error {
+		usr, err := ur.QueryAccount(email)
+		if err != nil {
+			return errors.Wrapf(err, "find %s", email)
+		}
+		return fn(ur, usr)
+	})
+}
+
+func printAccounts(format string, accounts []account) error {
+	if format == FormatJSON {
+		return printJSON(accounts)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tROLE\tACTIVE\tVERIFIED\tJOINED")
+	for _, a := range accounts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\t%s\n", a.ID, a.Name, a.Email, a.Role, a.Active, a.Verified, a.CreatedAt)
+	}
+	return w.Flush()
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// ReadPassword reads a password from the first line of r, so it doesn't end up
+// in the shell history or the process list like a flag would.
+func ReadPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "reading password")
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
+
+// printPassword prints a generated password to stderr, apart from the output
+// of the command.
+func printPassword(email, password string) {
+	fmt.Fprintf(os.Stderr, "password of %s: %s\n", email, password)
+}
+
+// passwordOrRandom returns the password or, if it is empty, a random one.
+func passwordOrRandom(password string) (string, bool, error) {
+	if password != "" {
+		return password, false, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, errors.Wrap(err, "generating password")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
@@ -19,23 +19,141 @@ func main() {
 }
 
 func run(log *log.Logger) error {
-	command := flag.String("action", "", "admin command: migrate | seed")
+	command := flag.String("action", "", "admin command: migrate | seed | backup | restore | maintenance | stats | unlock | logins | users | user | create-user | deactivate | reactivate | delete-user | reset-password | promote")
 	dbName := flag.String("dbName", "database.sqlite", "database name")
+	format := flag.String("format", commands.FormatTable, "output format of user commands and stats: table | json")
+	name := flag.String("name", "", "name of a new user")
+	passwordStdin := flag.Bool("password-stdin", false, "read the password of a new user or for a password reset from stdin, a random one is generated otherwise")
+	users := flag.Int("users", 0, "number of users to generate with seed")
+	maybes := flag.Int("maybes", 0, "number of maybes to generate with seed")
+	tags := flag.Int("tags", 0, "number of tags to generate with seed")
+	seed := flag.Int64("seed", 1, "seed of the random data generated with seed")
+	weeks := flag.Int("weeks", 12, "number of weeks in stats")
+	dryRun := flag.Bool("dry-run", false, "print what migrate or maintenance would change without changing it")
 	flag.Parse()
 
+	var password string
+	if *passwordStdin {
+		var err error
+		if password, err = commands.ReadPassword(os.Stdin); err != nil {
+			return err
+		}
+	}
+
 	switch *command {
 	case "migrate":
-		if err := commands.Migrate(*dbName); err != nil {
-			return errors.Wrap(err, "migrating database")
+		switch flag.Arg(0) {
+		case "status":
+			if err := commands.MigrateStatus(*dbName); err != nil {
+				return errors.Wrap(err, "checking migrations")
+			}
+		case "rollback":
+			rollback := flag.NewFlagSet("rollback", flag.ExitOnError)
+			to := rollback.Int("to", -1, "version to roll back to, 0 undoes all migrations")
+			rollback.Parse(flag.Args()[1:])
+			if err := commands.Rollback(*dbName, *to); err != nil {
+				return errors.Wrap(err, "rolling back database")
+			}
+		case "":
+			if *dryRun {
+				if err := commands.MigrateDryRun(*dbName); err != nil {
+					return errors.Wrap(err, "listing pending migrations")
+				}
+				break
+			}
+			if err := commands.Migrate(*dbName); err != nil {
+				return errors.Wrap(err, "migrating database")
+			}
+		default:
+			return errors.Errorf("unknown migrate command %q", flag.Arg(0))
 		}
 	case "seed":
+		if *users > 0 || *maybes > 0 || *tags > 0 {
+			if err := commands.Generate(*dbName, *users, *maybes, *tags, *seed); err != nil {
+				return errors.Wrap(err, "generating data")
+			}
+			break
+		}
 		if err := commands.Seed(*dbName); err != nil {
 			return errors.Wrap(err, "seeding database")
 		}
+	case "backup":
+		if err := commands.Backup(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "backing up database")
+		}
+	case "restore":
+		if err := commands.Restore(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "restoring database")
+		}
+	case "maintenance":
+		if err := commands.Maintenance(*dbName, *dryRun); err != nil {
+			return errors.Wrap(err, "maintaining database")
+		}
+	case "stats":
+		if err := commands.Stats(*dbName, *format, *weeks); err != nil {
+			return errors.Wrap(err, "showing stats")
+		}
+	case "unlock":
+		if err := commands.Unlock(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "unlocking user")
+		}
+	case "logins":
+		if err := commands.FailedLogins(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "listing failed logins")
+		}
+	case "users":
+		if err := commands.ListUsers(*dbName, *format); err != nil {
+			return errors.Wrap(err, "listing users")
+		}
+	case "user":
+		if err := commands.ShowUser(*dbName, *format, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "showing user")
+		}
+	case "create-user":
+		if err := commands.CreateUser(*dbName, *format, *name, flag.Arg(0), password); err != nil {
+			return errors.Wrap(err, "creating user")
+		}
+	case "deactivate":
+		if err := commands.SetActive(*dbName, flag.Arg(0), false); err != nil {
+			return errors.Wrap(err, "deactivating user")
+		}
+	case "reactivate":
+		if err := commands.SetActive(*dbName, flag.Arg(0), true); err != nil {
+			return errors.Wrap(err, "reactivating user")
+		}
+	case "delete-user":
+		if err := commands.DeleteUser(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "deleting user")
+		}
+	case "reset-password":
+		if err := commands.ResetPassword(*dbName, flag.Arg(0), password); err != nil {
+			return errors.Wrap(err, "resetting password")
+		}
+	case "promote":
+		if err := commands.Promote(*dbName, flag.Arg(0)); err != nil {
+			return errors.Wrap(err, "promoting user")
+		}
 	default:
 		fmt.Println("ADMIN: Possible commands:")
-		fmt.Println("-action=\"migrate\": create the schema in the database")
+		fmt.Println("-action=\"migrate\" [-dry-run]: create the schema in the database, or print the SQL of pending migrations")
+		fmt.Println("-action=\"migrate\" status: show which migrations are applied and check them for edits")
+		fmt.Println("-action=\"migrate\" rollback -to=VERSION: undo all migrations newer than VERSION")
 		fmt.Println("-action=\"seed\": add data to the database")
+		fmt.Println("-action=\"seed\" -users=N -maybes=M -tags=K [-seed=S]: add generated data to the database, the same for the same seed")
+		fmt.Println("-action=\"backup\" PATH: copy the database to a file or directory, also while the web server runs")
+		fmt.Println("-action=\"restore\" PATH: check a backup and replace the database with it, stop the web server first")
+		fmt.Println("-action=\"maintenance\" [-dry-run]: check the database, remove orphaned tags and dangling tag links, analyze and vacuum")
+		fmt.Println("-action=\"stats\" [-weeks=N]: show user counts, weekly activity, top tags and domains, and the database size")
+		fmt.Println("-action=\"unlock\" EMAIL: unlock a user after too many failed logins")
+		fmt.Println("-action=\"logins\" EMAIL: show the latest failed logins for an email address")
+		fmt.Println("-action=\"users\": list all users")
+		fmt.Println("-action=\"user\" EMAIL: show a user")
+		fmt.Println("-action=\"create-user\" -name=NAME [-password-stdin] EMAIL: add a verified user")
+		fmt.Println("-action=\"deactivate\" EMAIL: stop a user from logging in and log them out")
+		fmt.Println("-action=\"reactivate\" EMAIL: let a deactivated user log in again")
+		fmt.Println("-action=\"delete-user\" EMAIL: delete a user and all their data")
+		fmt.Println("-action=\"reset-password\" [-password-stdin] EMAIL: set a new password and log the user out")
+		fmt.Println("-action=\"promote\" EMAIL: make a user an admin")
 	}
 
 	return nil
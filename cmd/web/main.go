@@ -2,8 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"flag"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,11 +13,27 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/oidc"
+	"github.com/sophiabrandt/go-maybe-list/internal/backup"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/stats"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/digest"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/export"
+	"github.com/sophiabrandt/go-maybe-list/internal/logger"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+	"github.com/sophiabrandt/go-maybe-list/internal/reminder"
+	"github.com/sophiabrandt/go-maybe-list/internal/scheduler"
 	"github.com/sophiabrandt/go-maybe-list/internal/server"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/handlers"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/session"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/templates"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
 func main() {
@@ -25,29 +42,60 @@ func main() {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
 
-	log := log.New(os.Stdout, "MAYBE-LIST: ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
-
 	// listen for interrupt signals
 	go func() {
 		oscall := <-c
-		log.Printf("main: system call: %+v", oscall)
+		slog.Info("system call", "signal", oscall.String())
 		cancel()
 	}()
 
-	// run APP
-	if err := run(ctx, log); err != nil {
-		log.Println("main: error:", err)
+	// run APP, the default logger is the configured one once it is set up
+	if err := run(ctx); err != nil {
+		slog.Error("app stopped", "err", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, log *log.Logger) error {
-	log.Println("main : Started : Application initializing")
-
+func run(ctx context.Context) error {
 	addr := flag.String("addr", "0.0.0.0:4000", "Http network address")
 	dbName := flag.String("dbName", "database.sqlite", "database name")
+	baseURL := flag.String("baseURL", "https://localhost:4000", "public URL of the app, used for links in emails")
+	smtpAddr := flag.String("smtpAddr", "", "SMTP server address (host:port), disables emails if empty")
+	smtpUser := flag.String("smtpUser", "", "SMTP username")
+	smtpPassword := flag.String("smtpPassword", "", "SMTP password")
+	smtpFrom := flag.String("smtpFrom", "Maybe List <noreply@localhost>", "sender address of emails")
+	mailDir := flag.String("mailDir", "", "directory to write emails to instead of sending them, used if no SMTP server is configured")
+	secret := flag.String("secret", "", "secret key to sign links in emails, a random key is used if empty")
+	webhookURL := flag.String("webhookURL", "", "URL to post notifications to, disables webhooks if empty")
+	schedulerInterval := flag.Duration("schedulerInterval", time.Minute, "interval of background jobs like reminders")
+	sessionStore := flag.String("sessionStore", "sqlite", "where to keep sessions: sqlite | memory")
+	oidcIssuer := flag.String("oidcIssuer", "", "URL of an OpenID Connect provider to log in with, disables single sign-on if empty")
+	oidcClientID := flag.String("oidcClientID", "", "client ID at the OpenID Connect provider")
+	oidcClientSecret := flag.String("oidcClientSecret", "", "client secret at the OpenID Connect provider")
+	oidcName := flag.String("oidcName", "Single Sign-On", "name of the OpenID Connect provider on the login page")
+	backupDir := flag.String("backupDir", "", "directory for periodic database backups, disables them if empty")
+	backupInterval := flag.Duration("backupInterval", 24*time.Hour, "interval of periodic database backups")
+	backupKeep := flag.Int("backupKeep", 7, "number of periodic database backups to keep, 0 keeps all")
+	logFormat := flag.String("logFormat", logger.FormatText, "format of log lines: text | json")
+	logLevel := flag.String("logLevel", "info", "lowest level to log: debug | info | warn | error")
+	trustedProxies := flag.String("trustedProxies", "", "comma separated IP addresses and networks of reverse proxies whose X-Forwarded-For header is trusted")
+	metricsAddr := flag.String("metricsAddr", "", "internal network address to serve metrics on at /metrics, disables the listener if empty")
+	metricsAllow := flag.String("metricsAllow", "", "comma separated IP addresses and networks of clients that may scrape /debug/metrics")
+	metricsToken := flag.String("metricsToken", "", "bearer token that allows scraping /debug/metrics from anywhere, disabled if empty")
 	flag.Parse()
 
+	// logging
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return errors.Wrap(err, "parsing log level")
+	}
+	log, err := logger.New(os.Stdout, *logFormat, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(log)
+	log.Info("application initializing")
+
 	// database
 	db, err := database.New(*dbName)
 	if err != nil {
@@ -58,23 +106,185 @@ func run(ctx context.Context, log *log.Logger) error {
 	// initialize global dependencies
 	tc, err := templates.NewCache("./ui/html")
 	ses := session.New()
+	var sqliteStore *session.SQLiteStore
+	switch *sessionStore {
+	case "sqlite":
+		sqliteStore = session.NewSQLiteStore(db)
+		ses.Store = sqliteStore
+	case "memory":
+		log.Warn("sessions are kept in memory, restarts log out all users")
+	default:
+		return errors.Errorf("unknown session store %q", *sessionStore)
+	}
 
 	env := env.New(log, tc, ses)
+	env.BaseURL = *baseURL
+	if *secret != "" {
+		env.Secret = []byte(*secret)
+	} else {
+		log.Warn("no secret configured, links in emails stop working on restart")
+		env.Secret = make([]byte, 32)
+		if _, err := rand.Read(env.Secret); err != nil {
+			return errors.Wrap(err, "generating secret")
+		}
+	}
+	env.MailTemplates, err = mail.NewTemplates("./ui/email")
+	if err != nil {
+		return errors.Wrap(err, "loading email templates")
+	}
+
+	// account emails always need a mailer, without SMTP they are written to files or the log
+	var sender mail.Sender
+	switch {
+	case *smtpAddr != "":
+		sender = mail.SMTP{Addr: *smtpAddr, Username: *smtpUser, Password: *smtpPassword, From: *smtpFrom}
+		env.Mailer = sender
+	case *mailDir != "":
+		env.Mailer = mail.File{Dir: *mailDir, From: *smtpFrom}
+	default:
+		env.Mailer = mail.Log{Log: log}
+	}
+
+	if *oidcIssuer != "" {
+		env.OIDC, err = oidc.New(ctx, oidc.Config{
+			Name:         *oidcName,
+			Issuer:       *oidcIssuer,
+			ClientID:     *oidcClientID,
+			ClientSecret: *oidcClientSecret,
+			RedirectURL:  *baseURL + "/users/oidc/callback",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *backupDir != "" {
+		env.Backups = &backup.Job{DB: db, Dir: *backupDir, Every: *backupInterval, Keep: *backupKeep}
+	}
+
+	env.TrustedProxies, err = web.ParseNetworks(*trustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "parsing trusted proxies")
+	}
+
+	// metrics
+	networks, err := web.ParseNetworks(*metricsAllow)
+	if err != nil {
+		return errors.Wrap(err, "parsing metrics networks")
+	}
+	env.MetricsAccess = &metrics.Access{Networks: networks, Token: *metricsToken}
+	env.Metrics.RegisterDB(db.DB)
+	sr := stats.New(db)
+	env.Metrics.RegisterCounts(func() (metrics.Counts, error) {
+		u, t, err := sr.QueryCounts()
+		return metrics.Counts{
+			Users:       u.Total,
+			ActiveUsers: u.Active,
+			Maybes:      t.Maybes,
+			Tags:        t.Tags,
+			Comments:    t.Comments,
+			Workspaces:  t.Workspaces,
+		}, err
+	})
 
 	router := handlers.New(env, db)
 
+	// reminders are always delivered to the in-app inbox, email and webhooks are optional
+	notifier := notify.Multi{notify.Inbox{Repo: notification.New(db)}}
+	if sender != nil {
+		notifier = append(notifier, notify.Email{Sender: sender, BaseURL: *baseURL})
+	}
+	if *webhookURL != "" {
+		notifier = append(notifier, notify.Webhook{URL: *webhookURL, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+
+	// background jobs stop with the context on shutdown
+	sched := scheduler.New(scheduler.RealClock{}, *schedulerInterval, log)
+	sched.Add("reminders", reminder.Job{Maybes: maybe.New(db), Notifier: notifier}.Run)
+	sched.Add("sessions", func(ctx context.Context, now time.Time) error {
+		if sqliteStore != nil {
+			if err := sqliteStore.DeleteExpired(now); err != nil {
+				return err
+			}
+		}
+		return user.New(db).DeleteStaleSessions(now.Add(-session.Lifetime))
+	})
+	sched.Add("exports", export.Job{
+		Users:         user.New(db),
+		Maybes:        maybe.New(db),
+		Comments:      comment.New(db),
+		Notifications: notification.New(db),
+		Notifier:      notifier,
+	}.Run)
+	sched.Add("deletions", func(ctx context.Context, now time.Time) error {
+		n, err := user.New(db).DeleteDue(now)
+		if n > 0 {
+			log.InfoContext(ctx, "deleted accounts", "count", n)
+		}
+		return err
+	})
+	if env.Backups != nil {
+		sched.Add("backups", env.Backups.Run)
+	}
+	if sender != nil {
+		sched.Add("digest", digest.Generator{
+			Users:     user.New(db),
+			Maybes:    maybe.New(db),
+			Templates: env.MailTemplates,
+			Sender:    sender,
+			BaseURL:   *baseURL,
+		}.Run)
+	} else {
+		log.Warn("no SMTP server configured, weekly digests are disabled")
+	}
+
+	// the jobs use the database, so wait for them to stop before it is closed,
+	// also if the server fails and the context of the app is not canceled
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		sched.Run(jobsCtx)
+	}()
+	defer func() {
+		stopJobs()
+		<-jobsDone
+	}()
+
 	// create server
 	srv := server.New(*addr, router)
+	srv.ErrorLog = slog.NewLogLogger(log.Handler(), slog.LevelError)
 
+	serverErr := make(chan error, 2)
 	go func() {
-		log.Printf("main: APP listening on %s", *addr)
+		log.Info("listening", "addr", *addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("main: %+s\n", err)
+			serverErr <- err
 		}
 	}()
 
-	<-ctx.Done()
-	log.Println("main: Start shutdown")
+	// metrics for scrapers in the internal network, this listener must not be
+	// reachable through the reverse proxy
+	var metricsSrv *http.Server
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", env.Metrics.Handler(log))
+		metricsSrv = server.New(*metricsAddr, mux)
+		metricsSrv.ErrorLog = srv.ErrorLog
+		go func() {
+			log.Info("serving metrics", "addr", *metricsAddr)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErr <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		return errors.Wrap(err, "serving")
+	}
+	log.Info("start shutdown")
 
 	// shutdown server
 	ctxShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -82,15 +292,15 @@ func run(ctx context.Context, log *log.Logger) error {
 		cancel()
 	}()
 
-	if err = srv.Shutdown(ctxShutdown); err != nil {
-		log.Fatalf("main: Shutdown Failed: %+s", err)
+	if err := srv.Shutdown(ctxShutdown); err != nil {
+		return errors.Wrap(err, "shutting down")
 	}
-
-	log.Println("main: APP exited properly")
-
-	if err == http.ErrServerClosed {
-		err = nil
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctxShutdown); err != nil {
+			return errors.Wrap(err, "shutting down metrics")
+		}
 	}
 
+	log.Info("app exited properly")
 	return nil
 }
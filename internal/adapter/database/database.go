@@ -8,7 +8,9 @@ import (
 
 // New returns a new database connection pool.
 func New(dbName string) (*sqlx.DB, error) {
-	db, err := sqlx.Open("sqlite", dbName)
+	// foreign keys are a setting of each connection, so they are turned on
+	// for every connection of the pool with the DSN
+	db, err := sqlx.Open("sqlite", dbName+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, errors.Wrap(err, "Unable to open database")
 	}
@@ -17,7 +19,6 @@ func New(dbName string) (*sqlx.DB, error) {
 	}
 
 	const q = `
-	PRAGMA foreign_keys = ON;
 	PRAGMA synchronous = NORMAL;
 	PRAGMA journal_mode = 'WAL';
 	PRAGMA cache_size = -64000;
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Log writes emails to a logger instead of sending them. It is meant for
+// development, where links in emails can be copied from the log.
+type Log struct {
+	Log *slog.Logger
+}
+
+// Send logs the recipient, subject and plain-text body of a message.
+func (l Log) Send(msg Message) error {
+	l.Log.Info("mail", "to", msg.To, "subject", msg.Subject, "text", msg.Text)
+	return nil
+}
+
+// File writes emails as .eml files into a directory instead of sending them.
+// Most mail clients can open these files.
+type File struct {
+	Dir  string
+	From string
+}
+
+// Send writes a message to a new file in the directory.
+func (f File) Send(msg Message) error {
+	now := time.Now()
+	body, err := msg.Bytes(f.From, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return errors.Wrapf(err, "creating mail directory %q", f.Dir)
+	}
+	name := filepath.Join(f.Dir, fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405"), now.UnixNano()))
+	if err := os.WriteFile(name, body, 0o644); err != nil {
+		return errors.Wrapf(err, "writing mail to %q", name)
+	}
+	return nil
+}
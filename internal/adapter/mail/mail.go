@@ -0,0 +1,109 @@
+// Package mail sends emails.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message is an email with a plain-text body and an optional HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender sends emails.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTP sends emails through an SMTP server. Username and Password are
+// optional, servers without authentication only need an address.
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers a message to the SMTP server.
+func (s SMTP) Send(msg Message) error {
+	body, err := msg.Bytes(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return errors.Wrapf(err, "parsing SMTP address %q", s.Addr)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, body); err != nil {
+		return errors.Wrapf(err, "sending mail to %q", msg.To)
+	}
+	return nil
+}
+
+// Bytes returns the message in MIME format. Messages with an HTML body are
+// sent as multipart/alternative with the plain-text body as fallback.
+func (msg Message) Bytes(from string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		buf.WriteString("\r\n")
+		buf.WriteString(normalize(msg.Text))
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, errors.Wrap(err, "creating mail part")
+		}
+		if _, err := w.Write([]byte(normalize(part.body))); err != nil {
+			return nil, errors.Wrap(err, "writing mail part")
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, errors.Wrap(err, "closing mail")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// normalize converts line endings to CRLF as required by SMTP.
+func normalize(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
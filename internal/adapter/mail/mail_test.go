@@ -0,0 +1,144 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is a local stand-in for an SMTP server that accepts a single
+// message and hands its data to the test.
+func smtpServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				b, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				data <- string(b)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), data
+}
+
+func TestSMTPSend(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want []string
+	}{
+		{
+			name: "Plain text",
+			msg:  Message{To: "alice@example.com", Subject: "Hello", Text: "Hi Alice,\nbye"},
+			want: []string{"To: alice@example.com", "Subject: Hello", "Content-Type: text/plain; charset=utf-8", "Hi Alice,\nbye"},
+		},
+		{
+			name: "HTML",
+			msg:  Message{To: "alice@example.com", Subject: "Hello", Text: "Hi Alice", HTML: "<p>Hi Alice</p>"},
+			want: []string{"multipart/alternative", "Hi Alice", "Content-Type: text/html; charset=utf-8", "<p>Hi Alice</p>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, data := smtpServer(t)
+
+			s := SMTP{Addr: addr, From: "maybe@example.com"}
+			if err := s.Send(tt.msg); err != nil {
+				t.Fatal(err)
+			}
+
+			got := <-data
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("want %q in message; got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageBytesHeaders(t *testing.T) {
+	msg := Message{To: "bob@example.com", Subject: "Grüße", Text: "hi"}
+	b, err := msg.Bytes("maybe@example.com", time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(b))))
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Get("From"); got != "maybe@example.com" {
+		t.Errorf("want From maybe@example.com; got %q", got)
+	}
+	if got := h.Get("Subject"); !strings.HasPrefix(got, "=?utf-8?q?") {
+		t.Errorf("want encoded subject; got %q", got)
+	}
+}
+
+func TestFileSend(t *testing.T) {
+	dir := t.TempDir()
+
+	f := File{Dir: dir, From: "maybe@example.com"}
+	if err := f.Send(Message{To: "alice@example.com", Subject: "Hello", Text: "Hi Alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("want 1 file; got %d", len(files))
+	}
+	b, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "To: alice@example.com") {
+		t.Errorf("want recipient in file; got %q", b)
+	}
+}
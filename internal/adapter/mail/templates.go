@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Templates renders emails from pairs of templates in a directory: NAME.txt.tmpl
+// for the plain-text body and an optional NAME.html.tmpl for the HTML body.
+// The plain-text template defines the subject in a "subject" block.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// NewTemplates parses all email templates in a directory.
+func NewTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+
+	texts, err := filepath.Glob(filepath.Join(dir, "*.txt.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range texts {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt.tmpl")
+		tt, err := texttemplate.ParseFiles(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing email template %q", file)
+		}
+		t.text[name] = tt
+	}
+
+	htmls, err := filepath.Glob(filepath.Join(dir, "*.html.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range htmls {
+		name := strings.TrimSuffix(filepath.Base(file), ".html.tmpl")
+		ht, err := htmltemplate.ParseFiles(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing email template %q", file)
+		}
+		t.html[name] = ht
+	}
+
+	return t, nil
+}
+
+// Render renders the email with the given name for a recipient.
+func (t *Templates) Render(name string, to string, data interface{}) (Message, error) {
+	tt, ok := t.text[name]
+	if !ok {
+		return Message{}, errors.Errorf("the email template %s does not exist", name)
+	}
+
+	msg := Message{To: to}
+
+	var buf bytes.Buffer
+	if err := tt.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, errors.Wrapf(err, "rendering subject of email %s", name)
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tt.Execute(&buf, data); err != nil {
+		return Message{}, errors.Wrapf(err, "rendering email %s", name)
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	if ht, ok := t.html[name]; ok {
+		buf.Reset()
+		if err := ht.Execute(&buf, data); err != nil {
+			return Message{}, errors.Wrapf(err, "rendering HTML of email %s", name)
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
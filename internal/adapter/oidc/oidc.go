@@ -0,0 +1,95 @@
+// Package oidc logs users in with an OpenID Connect identity provider using
+// the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Config contains the settings of the identity provider. Name is shown on
+// the login button.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Claims are the claims of an ID token that identify a user.
+type Claims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Provider is an OpenID Connect identity provider.
+type Provider struct {
+	Name     string
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// New discovers the endpoints of the identity provider at the issuer URL.
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	p, err := gooidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "discovering OpenID provider %q", cfg.Issuer)
+	}
+
+	return &Provider{
+		Name: cfg.Name,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     p.Endpoint(),
+			Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: p.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// GenerateVerifier creates a random PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the URL of the identity provider to send the user to.
+// The state, nonce and code verifier have to be kept until the callback.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the code from the callback for an ID token and returns its
+// claims after checking the signature, audience, expiry and nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Claims{}, errors.Wrap(err, "exchanging code")
+	}
+
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return Claims{}, errors.New("no ID token in token response")
+	}
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return Claims{}, errors.Wrap(err, "verifying ID token")
+	}
+	if idToken.Nonce != nonce {
+		return Claims{}, errors.New("ID token nonce does not match")
+	}
+
+	var c Claims
+	if err := idToken.Claims(&c); err != nil {
+		return Claims{}, errors.Wrap(err, "reading ID token claims")
+	}
+	return c, nil
+}
@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// mockProvider is a local stand-in for an identity provider. It hands out one
+// code per authorization request and checks the PKCE code verifier.
+type mockProvider struct {
+	t      *testing.T
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{t: t, key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.srv.URL,
+			"authorization_endpoint":                m.srv.URL + "/authorize",
+			"token_endpoint":                        m.srv.URL + "/token",
+			"jwks_uri":                              m.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &m.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.idToken(),
+		})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+
+	return m
+}
+
+// authorize plays the login at the identity provider and remembers the
+// challenge and nonce of the request.
+func (m *mockProvider) authorize(authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("want S256 code challenge; got %q", q.Get("code_challenge_method"))
+	}
+	m.challenge = q.Get("code_challenge")
+	m.nonce = q.Get("nonce")
+}
+
+func (m *mockProvider) idToken() string {
+	claims := map[string]interface{}{
+		"iss":   m.srv.URL,
+		"aud":   "client",
+		"sub":   "alice-id",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": m.nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: m.key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return token
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		verifier func(v string) string
+		nonce    func(n string) string
+		want     Claims
+		wantErr  bool
+	}{
+		{
+			name:   "Valid",
+			claims: map[string]interface{}{"email": "alice@example.com", "email_verified": true, "name": "Alice"},
+			want:   Claims{Subject: "alice-id", Email: "alice@example.com", EmailVerified: true, Name: "Alice"},
+		},
+		{
+			name:     "Wrong code verifier",
+			verifier: func(v string) string { return GenerateVerifier() },
+			wantErr:  true,
+		},
+		{
+			name:    "Wrong nonce",
+			nonce:   func(n string) string { return "other" },
+			wantErr: true,
+		},
+		{
+			name:    "Other audience",
+			claims:  map[string]interface{}{"aud": "other-client"},
+			wantErr: true,
+		},
+		{
+			name:    "Expired",
+			claims:  map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			m.claims = tt.claims
+
+			ctx := context.Background()
+			p, err := New(ctx, Config{Issuer: m.srv.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "https://maybes.test/callback"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifier, nonce := GenerateVerifier(), "nonce"
+			m.authorize(p.AuthCodeURL("state", nonce, verifier))
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			if tt.nonce != nil {
+				nonce = tt.nonce(nonce)
+			}
+
+			got, err := p.Exchange(ctx, "code", verifier, nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error; got claims %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Issuer = m.srv.URL
+			if got != tt.want {
+				t.Errorf("want %+v; got %+v", tt.want, got)
+			}
+		})
+	}
+}
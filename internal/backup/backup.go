@@ -0,0 +1,176 @@
+// Package backup copies the SQLite database while the app runs and restores
+// these copies.
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+)
+
+// ErrNoBackup occurs when a directory holds no backups.
+var ErrNoBackup = errors.New("no backup found")
+
+// Backups in a directory are named after the time they were taken, so that
+// they sort by age and can be told apart from other files.
+const (
+	prefix     = "backup-"
+	suffix     = ".sqlite"
+	timeFormat = "20060102T150405Z"
+)
+
+// File is a backup in a directory.
+type File struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// Name returns the file name of a backup taken at a time.
+func Name(t time.Time) string {
+	return prefix + t.UTC().Format(timeFormat) + suffix
+}
+
+// Create copies the database to a new file at path. It uses VACUUM INTO, which
+// reads the database in a transaction of its own, so it is safe while the app
+// writes to it. The copy only shows up at path once it is complete.
+func Create(ctx context.Context, db *sqlx.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("backup %s already exists", path)
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if _, err := db.ExecContext(ctx, `VACUUM INTO $1`, tmp); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "copying database to %s", path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "moving backup to %s", path)
+	}
+	return nil
+}
+
+// List returns the backups in a directory, the newest first.
+func List(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading backup directory %s", dir)
+	}
+
+	var files []File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		t, err := time.Parse(timeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix))
+		if err != nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading backup %s", name)
+		}
+		files = append(files, File{Path: filepath.Join(dir, name), Time: t, Size: fi.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Time.After(files[j].Time) })
+	return files, nil
+}
+
+// Latest returns the newest backup in a directory.
+func Latest(dir string) (File, error) {
+	files, err := List(dir)
+	if err != nil {
+		return File{}, err
+	}
+	if len(files) == 0 {
+		return File{}, ErrNoBackup
+	}
+	return files[0], nil
+}
+
+// Check opens a backup read-only and runs SQLite's integrity check on it.
+func Check(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrapf(err, "opening backup %s", path)
+	}
+
+	db, err := sqlx.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.Wrapf(err, "opening backup %s", path)
+	}
+	defer db.Close()
+
+	var problems []string
+	if err := db.Select(&problems, `PRAGMA integrity_check`); err != nil {
+		return errors.Wrapf(err, "checking backup %s", path)
+	}
+	if len(problems) != 1 || problems[0] != "ok" {
+		return errors.Errorf("backup %s is damaged: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Restore replaces the database dbName with a backup after checking the
+// backup's integrity. The replaced database is kept next to it with the
+// suffix .before-restore. The app must not run while the database is restored.
+func Restore(path, dbName string) error {
+	if err := Check(path); err != nil {
+		return err
+	}
+
+	tmp := dbName + ".restore"
+	if err := copyFile(path, tmp); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "copying backup %s", path)
+	}
+
+	// the write-ahead log belongs to the replaced database, it moves along
+	// so that it isn't replayed into the backup
+	kept := dbName + ".before-restore"
+	for _, ext := range []string{"", "-wal", "-shm"} {
+		os.Remove(kept + ext)
+		if err := os.Rename(dbName+ext, kept+ext); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmp)
+			return errors.Wrapf(err, "keeping database %s%s", dbName, ext)
+		}
+	}
+
+	if err := os.Rename(tmp, dbName); err != nil {
+		return errors.Wrapf(err, "moving backup to %s", dbName)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+)
+
+func newDB(t *testing.T, path string) *sqlx.DB {
+	t.Helper()
+
+	db, err := database.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbName := filepath.Join(dir, "app.sqlite")
+	db := newDB(t, dbName)
+	if _, err := db.Exec(`CREATE TABLE notes (body TEXT); INSERT INTO notes VALUES ('before')`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "copy.sqlite")
+	if err := Create(context.Background(), db, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(context.Background(), db, path); err == nil {
+		t.Error("want error when the backup exists")
+	}
+	if _, err := db.Exec(`UPDATE notes SET body = 'after'`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := Restore(path, dbName); err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	if err := newDB(t, dbName).Get(&body, `SELECT body FROM notes`); err != nil {
+		t.Fatal(err)
+	}
+	if body != "before" {
+		t.Errorf("want restored note %q; got %q", "before", body)
+	}
+	if _, err := os.Stat(dbName + ".before-restore"); err != nil {
+		t.Errorf("want replaced database kept; got %v", err)
+	}
+}
+
+func TestRestoreDamaged(t *testing.T) {
+	dir := t.TempDir()
+	dbName := filepath.Join(dir, "app.sqlite")
+	if err := os.WriteFile(dbName, []byte("database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "damaged.sqlite")
+	if err := os.WriteFile(path, []byte("this is not a database, not even close to one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(path, dbName); err == nil {
+		t.Fatal("want error restoring a damaged backup")
+	}
+	if b, _ := os.ReadFile(dbName); string(b) != "database" {
+		t.Errorf("want database untouched; got %q", b)
+	}
+}
+
+func TestJobRun(t *testing.T) {
+	dir := t.TempDir()
+	job := Job{DB: newDB(t, filepath.Join(dir, "app.sqlite")), Dir: filepath.Join(dir, "backups"), Every: time.Hour, Keep: 2}
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if h := job.Health(now); h.Status != HealthMissing {
+		t.Errorf("want health %q; got %q", HealthMissing, h.Status)
+	}
+
+	for _, run := range []time.Duration{0, 30 * time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		if err := job.Run(context.Background(), now.Add(run)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := List(job.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !files[0].Time.Equal(now.Add(3*time.Hour)) || !files[1].Time.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("want the 2 newest backups kept; got %+v", files)
+	}
+	if err := Check(files[0].Path); err != nil {
+		t.Error(err)
+	}
+
+	if h := job.Health(now.Add(4 * time.Hour)); h.Status != HealthOK || !h.Last.Equal(files[0].Time) || h.File != Name(files[0].Time) {
+		t.Errorf("want healthy backup %s; got %+v", Name(files[0].Time), h)
+	}
+	if h := job.Health(now.Add(6 * time.Hour)); h.Status != HealthStale {
+		t.Errorf("want health %q; got %q", HealthStale, h.Status)
+	}
+}
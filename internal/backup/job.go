@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Job backs up the database into a directory every interval and keeps the
+// newest backups.
+type Job struct {
+	DB    *sqlx.DB
+	Dir   string
+	Every time.Duration
+	// Keep is how many backups are kept, all of them if it is zero.
+	Keep int
+}
+
+// Run takes a backup if the latest one in the directory is older than the
+// interval, then deletes the backups beyond the ones to keep. Backups taken
+// with the admin command count as well.
+func (j Job) Run(ctx context.Context, now time.Time) error {
+	if err := os.MkdirAll(j.Dir, 0700); err != nil {
+		return errors.Wrapf(err, "creating backup directory %s", j.Dir)
+	}
+
+	latest, err := Latest(j.Dir)
+	if err != nil && err != ErrNoBackup {
+		return err
+	}
+	if err == nil && now.Sub(latest.Time) < j.Every {
+		return nil
+	}
+
+	if err := Create(ctx, j.DB, filepath.Join(j.Dir, Name(now))); err != nil {
+		return err
+	}
+	return j.prune()
+}
+
+// prune deletes all but the newest backups to keep.
+func (j Job) prune() error {
+	if j.Keep <= 0 {
+		return nil
+	}
+
+	files, err := List(j.Dir)
+	if err != nil {
+		return err
+	}
+	for i := j.Keep; i < len(files); i++ {
+		if err := os.Remove(files[i].Path); err != nil {
+			return errors.Wrapf(err, "deleting old backup %s", files[i].Path)
+		}
+	}
+	return nil
+}
+
+// Health of the backups as reported by the debug endpoint.
+const (
+	HealthOK      = "ok"
+	HealthStale   = "stale"
+	HealthMissing = "missing"
+	HealthError   = "error"
+)
+
+// Health reports the last successful backup.
+type Health struct {
+	Status string     `json:"status"`
+	Last   *time.Time `json:"last,omitempty"`
+	File   string     `json:"file,omitempty"`
+	Size   int64      `json:"size,omitempty"`
+}
+
+// Health tells when the last backup was taken. Backups are stale once two
+// runs were missed.
+func (j Job) Health(now time.Time) Health {
+	latest, err := Latest(j.Dir)
+	switch {
+	case err == ErrNoBackup || os.IsNotExist(errors.Cause(err)):
+		return Health{Status: HealthMissing}
+	case err != nil:
+		return Health{Status: HealthError}
+	}
+
+	h := Health{Status: HealthOK, Last: &latest.Time, File: filepath.Base(latest.Path), Size: latest.Size}
+	if now.Sub(latest.Time) > 2*j.Every {
+		h.Status = HealthStale
+	}
+	return h
+}
@@ -0,0 +1,333 @@
+package comment
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific comment is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// CommentRepository defines the repository for the comment service.
+type CommentRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a pointer to a comment repo.
+func New(db *sqlx.DB) CommentRepository {
+	return CommentRepository{Db: db}
+}
+
+// canView checks if the user can read the maybe, either as the author of a
+// personal maybe or as a member of its workspace.
+func (cr CommentRepository) canView(maybeID string, userID string) error {
+	if _, err := uuid.Parse(maybeID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		(m.workspace_id IS NULL AND m.user_id = $2) OR wm.user_id IS NOT NULL
+	FROM maybes AS m
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = $2
+	WHERE
+		m.maybe_id = $1
+	`
+	var ok bool
+	if err := cr.Db.Get(&ok, q, maybeID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "checking access to maybe %q", maybeID)
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Query retrieves all comments of a maybe as threads: top-level comments in
+// the order they were written, each followed by its (nested) replies.
+func (cr CommentRepository) Query(maybeID string, userID string) (Infos, error) {
+	if err := cr.canView(maybeID, userID); err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		c.*,
+		u.name AS author_name,
+		u.handle AS author_handle,
+		c.user_id = $2 AS is_author
+	FROM comments AS c
+	JOIN
+		users AS u ON u.user_id = c.user_id
+	WHERE
+		c.maybe_id = $1
+	ORDER BY
+		c.created_at
+	`
+	var comments Infos
+	if err := cr.Db.Select(&comments, q, maybeID, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting comments for maybe %q", maybeID)
+	}
+
+	return thread(comments), nil
+}
+
+// QueryAuthored retrieves all comments a user wrote, on any maybe.
+func (cr CommentRepository) QueryAuthored(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		c.*,
+		u.name AS author_name,
+		TRUE AS is_author
+	FROM comments AS c
+	JOIN
+		users AS u ON u.user_id = c.user_id
+	WHERE
+		c.user_id = $1
+	ORDER BY
+		c.created_at
+	`
+	var comments Infos
+	if err := cr.Db.Select(&comments, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting comments of user %q", userID)
+	}
+	return comments, nil
+}
+
+// thread orders comments depth-first so that replies directly follow their
+// parent comment, and sets the nesting depth of each reply.
+func thread(comments Infos) Infos {
+	children := map[string]Infos{}
+	var roots Infos
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	threaded := make(Infos, 0, len(comments))
+	var walk func(c Info, depth int)
+	walk = func(c Info, depth int) {
+		c.Depth = depth
+		threaded = append(threaded, c)
+		for _, reply := range children[c.ID] {
+			walk(reply, depth+1)
+		}
+	}
+	for _, c := range roots {
+		walk(c, 0)
+	}
+	return threaded
+}
+
+// Create adds a new comment to a maybe the user can read.
+func (cr CommentRepository) Create(nc NewComment, userID string) (Info, error) {
+	if err := cr.canView(nc.MaybeID, userID); err != nil {
+		return Info{}, err
+	}
+
+	now := time.Now().UTC().String()
+	c := Info{
+		ID:          uuid.New().String(),
+		MaybeID:     nc.MaybeID,
+		UserID:      userID,
+		IsAuthor:    true,
+		Body:        nc.Body,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	// replies must belong to the same maybe as their parent
+	if nc.ParentID != "" {
+		if _, err := uuid.Parse(nc.ParentID); err != nil {
+			return Info{}, ErrInvalidID
+		}
+		var count int
+		const p = `SELECT COUNT(*) FROM comments WHERE comment_id = $1 AND maybe_id = $2`
+		if err := cr.Db.Get(&count, p, nc.ParentID, nc.MaybeID); err != nil {
+			return Info{}, errors.Wrapf(err, "selecting parent comment %q", nc.ParentID)
+		}
+		if count == 0 {
+			return Info{}, ErrNotFound
+		}
+		c.ParentID = &nc.ParentID
+	}
+
+	const q = `
+	INSERT INTO comments
+		(comment_id, maybe_id, user_id, parent_id, body, created_at, updated_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := cr.Db.Exec(q, c.ID, c.MaybeID, c.UserID, c.ParentID, c.Body, c.DateCreated, c.DateUpdated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting comment")
+	}
+
+	if err := cr.Db.Get(&c.AuthorName, `SELECT name FROM users WHERE user_id = $1`, userID); err != nil {
+		return Info{}, errors.Wrapf(err, "selecting author %q", userID)
+	}
+
+	return c, nil
+}
+
+// queryOwned retrieves a comment if the current user is its author.
+func (cr CommentRepository) queryOwned(commentID string, userID string) (Info, error) {
+	if _, err := uuid.Parse(commentID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		c.*,
+		u.name AS author_name,
+		c.user_id = $2 AS is_author
+	FROM comments AS c
+	JOIN
+		users AS u ON u.user_id = c.user_id
+	WHERE
+		c.comment_id = $1
+	`
+	var c Info
+	if err := cr.Db.Get(&c, q, commentID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting comment %q", commentID)
+	}
+	if !c.IsAuthor {
+		return Info{}, ErrForbidden
+	}
+	return c, nil
+}
+
+// Update changes the body of a comment. Only authors who can still read the
+// maybe can edit their comments.
+func (cr CommentRepository) Update(commentID string, body string, userID string) (Info, error) {
+	c, err := cr.queryOwned(commentID, userID)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := cr.canView(c.MaybeID, userID); err != nil {
+		return Info{}, err
+	}
+
+	c.Body = body
+	c.DateUpdated = time.Now().UTC().String()
+
+	const q = `
+	UPDATE comments
+	SET
+		body = $2,
+		updated_at = $3
+	WHERE
+		comment_id = $1
+	`
+	if _, err := cr.Db.Exec(q, commentID, c.Body, c.DateUpdated); err != nil {
+		return Info{}, errors.Wrapf(err, "updating comment %q", commentID)
+	}
+
+	return c, nil
+}
+
+// Delete removes a comment and its replies. Only authors who can still read
+// the maybe can delete their comments.
+func (cr CommentRepository) Delete(commentID string, userID string) (Info, error) {
+	c, err := cr.queryOwned(commentID, userID)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := cr.canView(c.MaybeID, userID); err != nil {
+		return Info{}, err
+	}
+
+	const q = `
+	DELETE FROM
+		comments
+	WHERE
+		comment_id = $1
+	`
+	if _, err := cr.Db.Exec(q, commentID); err != nil {
+		return Info{}, errors.Wrapf(err, "deleting comment %q", commentID)
+	}
+
+	return c, nil
+}
+
+// QueryParticipants returns all users who can read a maybe: the author of a
+// personal maybe or the members of its workspace.
+func (cr CommentRepository) QueryParticipants(maybeID string) (Participants, error) {
+	const q = `
+	SELECT
+		u.user_id, u.name, u.handle
+	FROM maybes AS m
+	JOIN
+		users AS u ON m.workspace_id IS NULL AND u.user_id = m.user_id
+	WHERE
+		m.maybe_id = $1
+	UNION
+	SELECT
+		u.user_id, u.name, u.handle
+	FROM maybes AS m
+	JOIN
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id
+	JOIN
+		users AS u ON u.user_id = wm.user_id
+	WHERE
+		m.maybe_id = $1
+	`
+	var participants Participants
+	if err := cr.Db.Select(&participants, q, maybeID); err != nil {
+		return nil, errors.Wrapf(err, "selecting participants of maybe %q", maybeID)
+	}
+	return participants, nil
+}
+
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([\p{L}\p{N}_.+-]+)`)
+
+// Mentions returns the unique, lower-cased handles mentioned with @handle in a comment body.
+func Mentions(body string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, m := range mentionRegex.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(m[1], ".-"))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+// Mentioned returns the participants whose handles are mentioned in a comment body.
+func (p Participants) Mentioned(body string) Participants {
+	var mentioned Participants
+	for _, handle := range Mentions(body) {
+		for _, participant := range p {
+			if participant.Handle == handle {
+				mentioned = append(mentioned, participant)
+			}
+		}
+	}
+	return mentioned
+}
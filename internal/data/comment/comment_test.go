@@ -0,0 +1,157 @@
+package comment
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "Single",
+			body: "What do you think, @Alice?",
+			want: []string{"alice"},
+		},
+		{
+			name: "Duplicates",
+			body: "@bob and @Bob, see @carol.",
+			want: []string{"bob", "carol"},
+		},
+		{
+			name: "Email",
+			body: "write to bob@example.com",
+			want: nil,
+		},
+		{
+			name: "Handle",
+			body: "@Ann.Lee, @bob+lists: thoughts?",
+			want: []string{"ann.lee", "bob+lists"},
+		},
+		{
+			name: "Markdown",
+			body: "**@dave** please check",
+			want: []string{"dave"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mentions(tt.body)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestThread(t *testing.T) {
+	parent := "1"
+	child := "2"
+	comments := Infos{
+		{ID: "1"},
+		{ID: "2", ParentID: &parent},
+		{ID: "3"},
+		{ID: "4", ParentID: &child},
+	}
+
+	got := thread(comments)
+
+	wantIDs := []string{"1", "2", "4", "3"}
+	wantDepths := []int{0, 1, 2, 0}
+	for i, c := range got {
+		if c.ID != wantIDs[i] || c.Depth != wantDepths[i] {
+			t.Errorf("position %d: want %q at depth %d; got %q at depth %d", i, wantIDs[i], wantDepths[i], c.ID, c.Depth)
+		}
+	}
+}
+
+func TestMentioned(t *testing.T) {
+	db := datatest.NewDB(t)
+	cr := New(db)
+	ann := datatest.UserWithEmail(t, db, "Ann Lee", "a.lee@example.com")
+	bob := datatest.UserWithEmail(t, db, "bob", "bob@example.com")
+	outsider := datatest.UserWithEmail(t, db, "carol", "carol@example.com")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{ann: "owner", bob: "editor"})
+	m := datatest.Maybe(t, db, "Shared", ann, ws)
+
+	participants, err := cr.QueryParticipants(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "Handle", body: "What do you think, @ann.lee?", want: []string{ann}},
+		{name: "FullName", body: "What do you think, @Ann Lee?", want: nil},
+		{name: "FirstName", body: "What do you think, @ann?", want: nil},
+		{name: "Email", body: "What do you think, @a.lee?", want: nil},
+		{name: "Several", body: "@bob and @Ann.Lee", want: []string{bob, ann}},
+		{name: "Outsider", body: "@carol should see this", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range participants.Mentioned(tt.body) {
+				got = append(got, p.ID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := cr.Create(NewComment{MaybeID: m, Body: "hi"}, outsider); errors.Cause(err) != ErrForbidden {
+		t.Errorf("comment by outsider: want %v; got %v", ErrForbidden, err)
+	}
+}
+
+func TestAuthorWithoutAccess(t *testing.T) {
+	db := datatest.NewDB(t)
+	cr := New(db)
+	ann := datatest.UserWithEmail(t, db, "Ann Lee", "ann.lee@example.com")
+	bob := datatest.UserWithEmail(t, db, "bob", "bob@example.com")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{ann: "owner", bob: "editor"})
+	m := datatest.Maybe(t, db, "Shared", ann, ws)
+
+	c, err := cr.Create(NewComment{MaybeID: m, Body: "first"}, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.Update(c.ID, "edited", ann); errors.Cause(err) != ErrForbidden {
+		t.Errorf("update by another member: want %v; got %v", ErrForbidden, err)
+	}
+	if _, err := cr.Update(c.ID, "edited", bob); err != nil {
+		t.Errorf("update by author: %v", err)
+	}
+
+	// the author leaves the workspace and can no longer change the comment
+	datatest.Exec(t, db, `DELETE FROM workspacemembers WHERE workspace_id = $1 AND user_id = $2`, ws, bob)
+
+	if _, err := cr.Update(c.ID, "edited again", bob); errors.Cause(err) != ErrForbidden {
+		t.Errorf("update after leaving: want %v; got %v", ErrForbidden, err)
+	}
+	if _, err := cr.Delete(c.ID, bob); errors.Cause(err) != ErrForbidden {
+		t.Errorf("delete after leaving: want %v; got %v", ErrForbidden, err)
+	}
+
+	comments, err := cr.Query(m, ann)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Body != "edited" || comments[0].AuthorHandle != "bob" {
+		t.Errorf("comments after leaving: got %+v", comments)
+	}
+}
@@ -0,0 +1,37 @@
+package comment
+
+// Info is the model for a comment on a maybe.
+// Depth is the nesting level of a reply when comments are queried as threads.
+type Info struct {
+	ID           string  `db:"comment_id"`
+	MaybeID      string  `db:"maybe_id"`
+	UserID       string  `db:"user_id"`
+	ParentID     *string `db:"parent_id"`
+	AuthorName   string  `db:"author_name"`
+	AuthorHandle string  `db:"author_handle"`
+	IsAuthor     bool    `db:"is_author"`
+	Body         string  `db:"body"`
+	DateCreated  string  `db:"created_at"`
+	DateUpdated  string  `db:"updated_at"`
+	Depth        int     `db:"-"`
+}
+
+type Infos []Info
+
+// NewComment is the data for creating a new comment.
+// A comment with a ParentID is a reply to another comment.
+type NewComment struct {
+	MaybeID  string
+	ParentID string
+	Body     string
+}
+
+// Participant is a user who can read and comment on a maybe.
+// Handle is unique, it mentions the user with @handle.
+type Participant struct {
+	ID     string `db:"user_id"`
+	Name   string `db:"name"`
+	Handle string `db:"handle"`
+}
+
+type Participants []Participant
@@ -0,0 +1,111 @@
+// Package datatest helps tests of the data packages to set up a migrated
+// database and to add users, workspaces and maybes to it.
+package datatest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+)
+
+// NewDB returns a migrated database in a temporary directory, closed when the
+// test ends.
+func NewDB(t testing.TB) *sqlx.DB {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Exec runs a statement and fails the test on errors.
+func Exec(t testing.TB, db *sqlx.DB, q string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(q, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Count runs a query for a single number and fails the test on errors.
+func Count(t testing.TB, db *sqlx.DB, q string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, q, args...); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// User adds an active user with the email address name@example.com, without
+// spaces, and returns its ID.
+func User(t testing.TB, db *sqlx.DB, name string) string {
+	t.Helper()
+	return UserWithEmail(t, db, name, strings.ReplaceAll(name, " ", "")+"@example.com")
+}
+
+// UserWithEmail adds an active user with an email address and returns its ID.
+// The handle is the lower-cased name with dots for spaces.
+func UserWithEmail(t testing.TB, db *sqlx.DB, name, email string) string {
+	t.Helper()
+	id := uuid.New().String()
+	Exec(t, db, `INSERT INTO users (user_id, name, email, handle, password_hash, active, created_at, updated_at) VALUES ($1, $2, $3, $4, '', TRUE, $5, $5)`,
+		id, name, email, strings.ToLower(strings.ReplaceAll(name, " ", ".")), time.Now().UTC().String())
+	return id
+}
+
+// Workspace adds a workspace with members by role and returns its ID.
+func Workspace(t testing.TB, db *sqlx.DB, name string, roles map[string]string) string {
+	t.Helper()
+	id := uuid.New().String()
+	now := time.Now().UTC().String()
+	Exec(t, db, `INSERT INTO workspaces (workspace_id, name, created_at, updated_at) VALUES ($1, $2, $3, $3)`, id, name, now)
+	for userID, role := range roles {
+		Exec(t, db, `INSERT INTO workspacemembers (workspace_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)`, id, userID, role, now)
+	}
+	return id
+}
+
+// Maybe adds a maybe by a user, in a workspace or in the personal space if
+// workspaceID is empty, and returns its ID.
+func Maybe(t testing.TB, db *sqlx.DB, title, userID, workspaceID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	var ws *string
+	if workspaceID != "" {
+		ws = &workspaceID
+	}
+	now := time.Now().UTC().String()
+	Exec(t, db, `INSERT INTO maybes (maybe_id, user_id, title, url, description, created_at, updated_at, workspace_id) VALUES ($1, $2, $3, '', '', $4, $4, $5)`,
+		id, userID, title, now, ws)
+	return id
+}
+
+// Tag adds a tag to a maybe of a user and returns its ID.
+func Tag(t testing.TB, db *sqlx.DB, name, maybeID, userID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	Exec(t, db, `INSERT INTO tags (tag_id, name) VALUES ($1, $2)`, id, name)
+	Exec(t, db, `INSERT INTO maybetags (tag_id, maybe_id, user_id) VALUES ($1, $2, $3)`, id, maybeID, userID)
+	return id
+}
+
+// WantErr reports an error if the cause of err is not want.
+func WantErr(t testing.TB, what string, err, want error) {
+	t.Helper()
+	if errors.Cause(err) != want {
+		t.Errorf("%s: got %v, want %v", what, err, want)
+	}
+}
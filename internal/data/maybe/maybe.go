@@ -33,27 +33,119 @@ func New(db *sqlx.DB) MaybeRepository {
 	return MaybeRepository{Db: db}
 }
 
-// Query retrieves all maybes from the database for the current user.
-func (mr MaybeRepository) Query(userID string) (Infos, error) {
-	const q = `
+// inScope restricts a query on maybes (aliased as m) to a space: with an empty
+// workspace ID ($2) the personal maybes of the user ($1), otherwise the maybes
+// of the workspace if the user is one of its members.
+const inScope = `
+	(
+		($2 = '' AND m.workspace_id IS NULL AND m.user_id = $1)
+		OR
+		(m.workspace_id = $2 AND EXISTS (
+			SELECT NULL FROM workspacemembers AS wm
+			WHERE
+				wm.workspace_id = m.workspace_id AND wm.user_id = $1
+		))
+	)
+`
+
+// SortTop orders lists of maybes by the score of their votes, best first.
+const SortTop = "top"
+
+// withScore selects the score of the votes of a maybe (aliased as m).
+const withScore = `
+	COALESCE((SELECT SUM(v.value) FROM votes AS v WHERE v.maybe_id = m.maybe_id), 0) AS score
+`
+
+// orderBy returns the ORDER BY clause for a sort option. Unknown options
+// fall back to the default order.
+func orderBy(sort string) string {
+	switch sort {
+	case SortTop:
+		return "ORDER BY score DESC, m.maybe_id"
+	default:
+		return "ORDER BY m.maybe_id"
+	}
+}
+
+// Query retrieves all maybes of the current space for the current user.
+// Snoozed maybes are left out until their snooze ends.
+func (mr MaybeRepository) Query(userID string, workspaceID string, sort string) (Infos, error) {
+	q := `
 	SELECT
 		m.*,
-		u.user_id AS user_id
+	` + withScore + `
 	FROM maybes as m
-	LEFT JOIN
-		users AS u ON m.user_id = u.user_id
 	WHERE
-		u.user_id = $1
-	ORDER BY
-		m.maybe_id
-	`
+		(m.snoozed_until IS NULL OR m.snoozed_until <= $3) AND
+	` + inScope + orderBy(sort)
 	var maybes Infos
-	if err := mr.Db.Select(&maybes, q, userID); err != nil {
+	if err := mr.Db.Select(&maybes, q, userID, workspaceID, time.Now().UTC()); err != nil {
 		return maybes, errors.Wrap(err, "selecting maybes")
 	}
 	return maybes, nil
 }
 
+// role returns the role of the user for a maybe: the author of a personal maybe
+// is its owner, for a maybe in a workspace it is the role of the membership.
+// Users without access get an empty role.
+func (mr MaybeRepository) role(maybeID string, userID string) (string, error) {
+	const q = `
+	SELECT
+		CASE
+			WHEN m.workspace_id IS NULL AND m.user_id = $2 THEN 'owner'
+			ELSE COALESCE(wm.role, '')
+		END
+	FROM maybes AS m
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = $2
+	WHERE
+		m.maybe_id = $1
+	`
+	var role string
+	if err := mr.Db.Get(&role, q, maybeID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrapf(err, "selecting role for maybe %q", maybeID)
+	}
+	return role, nil
+}
+
+// canEdit checks if the user may change or delete a maybe.
+func (mr MaybeRepository) canEdit(maybeID string, userID string) error {
+	role, err := mr.role(maybeID, userID)
+	if err != nil {
+		return err
+	}
+	if role != "owner" && role != "editor" {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// canEditWorkspace checks if the user may add maybes to a workspace.
+// An empty workspace ID stands for the personal space of the user.
+func (mr MaybeRepository) canEditWorkspace(workspaceID string, userID string) error {
+	if workspaceID == "" {
+		return nil
+	}
+	const q = `
+	SELECT
+		COUNT(*)
+	FROM workspacemembers
+	WHERE
+		workspace_id = $1 AND user_id = $2 AND role IN ('owner', 'editor')
+	`
+	var count int
+	if err := mr.Db.Get(&count, q, workspaceID, userID); err != nil {
+		return errors.Wrapf(err, "checking membership in workspace %q", workspaceID)
+	}
+	if count == 0 {
+		return ErrForbidden
+	}
+	return nil
+}
+
 // QuerybyID retrieves a book by ID from the database.
 func (r MaybeRepository) QueryByID(maybeID string, userID string) (Info, error) {
 	if _, err := uuid.Parse(maybeID); err != nil {
@@ -64,10 +156,9 @@ func (r MaybeRepository) QueryByID(maybeID string, userID string) (Info, error)
 	const q = `
 	SELECT
 		m.*,
-		u.user_id AS user_id
+		(SELECT r.remind_at FROM reminders AS r WHERE r.maybe_id = m.maybe_id AND r.user_id = $2) AS remind_at,
+	` + withScore + `
 	FROM maybes as m
-	LEFT JOIN
-		users AS u ON m.user_id = u.user_id
 	WHERE
 		m.maybe_id = $1
 	`
@@ -79,7 +170,11 @@ func (r MaybeRepository) QueryByID(maybeID string, userID string) (Info, error)
 		return maybe, errors.Wrapf(err, "selecting maybe with ID %q", maybeID)
 	}
 
-	if maybe.UserID != userID {
+	role, err := r.role(maybeID, userID)
+	if err != nil {
+		return Info{}, err
+	}
+	if role == "" {
 		return Info{}, ErrForbidden
 	}
 
@@ -109,30 +204,120 @@ func (r MaybeRepository) QueryByID(maybeID string, userID string) (Info, error)
 	return maybe, nil
 }
 
-// QueryByTag queries the database for all maybes of a certain tag for the current user.
-func (r MaybeRepository) QueryByTag(tagID string, userID string) (Infos, error) {
+// QuerySnoozed retrieves the snoozed maybes of the current space, the ones waking up first come first.
+func (mr MaybeRepository) QuerySnoozed(userID string, workspaceID string) (Infos, error) {
+	const q = `
+	SELECT
+		m.*,
+	` + withScore + `
+	FROM maybes as m
+	WHERE
+		m.snoozed_until > $3 AND
+	` + inScope + `
+	ORDER BY
+		m.snoozed_until
+	`
 	var maybes Infos
-	if _, err := uuid.Parse(tagID); err != nil {
-		return maybes, ErrInvalidTag
+	if err := mr.Db.Select(&maybes, q, userID, workspaceID, time.Now().UTC()); err != nil {
+		return maybes, errors.Wrap(err, "selecting snoozed maybes")
 	}
+	return maybes, nil
+}
 
+// QueryAllSpaces retrieves the maybes of the personal space and of all
+// workspaces of the user that are not snoozed at the given time.
+func (mr MaybeRepository) QueryAllSpaces(userID string, now time.Time) (Infos, error) {
 	const q = `
 	SELECT
 		m.*,
-		u.user_id AS user_id
+	` + withScore + `
 	FROM maybes as m
 	LEFT JOIN
-		users AS u ON m.user_id = u.user_id
-	LEFT JOIN
-		maybetags as mt ON m.maybe_id = mt.maybe_id
-	LEFT JOIN
-		tags AS t ON mt.tag_id = t.tag_id
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = $1
 	WHERE
-		t.tag_id = $1 AND mt.user_id = $2
+		(m.snoozed_until IS NULL OR m.snoozed_until <= $2) AND
+		((m.workspace_id IS NULL AND m.user_id = $1) OR wm.user_id IS NOT NULL)
 	ORDER BY
 		m.maybe_id
 	`
-	if err := r.Db.Select(&maybes, q, tagID, userID); err != nil {
+	var maybes Infos
+	if err := mr.Db.Select(&maybes, q, userID, now.UTC()); err != nil {
+		return maybes, errors.Wrap(err, "selecting maybes of all spaces")
+	}
+	return maybes, nil
+}
+
+// QueryAuthored retrieves all maybes the user created, in the personal space
+// and in workspaces, with their tags.
+func (mr MaybeRepository) QueryAuthored(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		m.*,
+	` + withScore + `
+	FROM maybes as m
+	WHERE
+		m.user_id = $1
+	ORDER BY
+		m.created_at
+	`
+	var maybes Infos
+	if err := mr.Db.Select(&maybes, q, userID); err != nil {
+		return maybes, errors.Wrapf(err, "selecting maybes of user %q", userID)
+	}
+
+	const t = `
+	SELECT
+		mt.maybe_id,
+		t.*
+	FROM
+		tags AS t
+	JOIN
+		maybetags AS mt ON mt.tag_id = t.tag_id
+	JOIN
+		maybes AS m ON m.maybe_id = mt.maybe_id
+	WHERE
+		m.user_id = $1
+	ORDER BY
+		t.name
+	`
+	var tags []struct {
+		MaybeID string `db:"maybe_id"`
+		Tag
+	}
+	if err := mr.Db.Select(&tags, t, userID); err != nil {
+		return maybes, errors.Wrapf(err, "selecting tags of user %q", userID)
+	}
+
+	byMaybe := map[string][]Tag{}
+	for _, tag := range tags {
+		byMaybe[tag.MaybeID] = append(byMaybe[tag.MaybeID], tag.Tag)
+	}
+	for i := range maybes {
+		maybes[i].Tags = byMaybe[maybes[i].ID]
+	}
+
+	return maybes, nil
+}
+
+// QueryByTag queries the database for all maybes of a certain tag in the current space.
+func (r MaybeRepository) QueryByTag(tagID string, userID string, workspaceID string, sort string) (Infos, error) {
+	var maybes Infos
+	if _, err := uuid.Parse(tagID); err != nil {
+		return maybes, ErrInvalidTag
+	}
+
+	q := `
+	SELECT
+		m.*,
+	` + withScore + `
+	FROM maybes as m
+	JOIN
+		maybetags as mt ON m.maybe_id = mt.maybe_id
+	WHERE
+		mt.tag_id = $3 AND
+		(m.snoozed_until IS NULL OR m.snoozed_until <= $4) AND
+	` + inScope + orderBy(sort)
+	if err := r.Db.Select(&maybes, q, userID, workspaceID, tagID, time.Now().UTC()); err != nil {
 		return maybes, errors.Wrapf(err, "selecting maybes by tag %q", tagID)
 	}
 
@@ -140,25 +325,37 @@ func (r MaybeRepository) QueryByTag(tagID string, userID string) (Infos, error)
 }
 
 // Create adds a new maybe to the database with pre-filled ID and date fields.
+// Maybes are created in a workspace if the update contains a workspace ID.
 func (r MaybeRepository) Create(nm NewOrUpdateMaybe, userID string) (Info, error) {
+	if err := r.canEditWorkspace(nm.WorkspaceID, userID); err != nil {
+		return Info{}, err
+	}
+
 	maybe := Info{
 		ID:          uuid.New().String(),
+		UserID:      userID,
 		Title:       nm.Title,
 		Url:         nm.Url,
 		Description: nm.Description,
+		Priority:    nm.Priority,
+		Effort:      nm.Effort,
+		DueDate:     nm.DueDate,
 		Tags:        nil,
 		DateCreated: time.Now().UTC().String(),
 		DateUpdated: time.Now().UTC().String(),
 	}
+	if nm.WorkspaceID != "" {
+		maybe.WorkspaceID = &nm.WorkspaceID
+	}
 
 	const q = `
 	INSERT INTO maybes
-		(maybe_id, user_id, title, url, description, created_at, updated_at)
+		(maybe_id, user_id, title, url, description, created_at, updated_at, workspace_id, priority, effort, due_date)
 	VALUES
-		($1, $2, $3, $4, $5, $6, $7)
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
-	if _, err := r.Db.Exec(q, maybe.ID, userID, maybe.Title, maybe.Url, maybe.Description, maybe.DateCreated, maybe.DateUpdated); err != nil {
+	if _, err := r.Db.Exec(q, maybe.ID, userID, maybe.Title, maybe.Url, maybe.Description, maybe.DateCreated, maybe.DateUpdated, maybe.WorkspaceID, maybe.Priority, maybe.Effort, maybe.DueDate); err != nil {
 		return Info{}, errors.Wrap(err, "inserting new maybe")
 	}
 
@@ -218,6 +415,11 @@ func (mr MaybeRepository) Update(um NewOrUpdateMaybe, maybeID string, userID str
 		}
 	}
 
+	// viewers of a workspace can read but not change maybes
+	if err := mr.canEdit(maybeID, userID); err != nil {
+		return err
+	}
+
 	if um.Title != "" {
 		maybe.Title = um.Title
 	}
@@ -237,11 +439,14 @@ func (mr MaybeRepository) Update(um NewOrUpdateMaybe, maybeID string, userID str
 		title = $2,
 		url = $3,
 		description = $4,
-		updated_at = $5
+		updated_at = $5,
+		priority = $6,
+		effort = $7,
+		due_date = $8
 	WHERE
 		maybe_id = $1
 	`
-	if _, err := mr.Db.Exec(q, maybeID, maybe.Title, maybe.Url, maybe.Description, time.Now().UTC().String()); err != nil {
+	if _, err := mr.Db.Exec(q, maybeID, maybe.Title, maybe.Url, maybe.Description, time.Now().UTC().String(), um.Priority, um.Effort, um.DueDate); err != nil {
 		return errors.Wrap(err, "updating product")
 	}
 
@@ -319,11 +524,15 @@ func (mr MaybeRepository) Update(um NewOrUpdateMaybe, maybeID string, userID str
 }
 
 // Delete removes a maybe with given ID and its tags from the database.
-func (mr MaybeRepository) Delete(maybeID string) error {
+func (mr MaybeRepository) Delete(maybeID string, userID string) error {
 	if _, err := uuid.Parse(maybeID); err != nil {
 		return ErrInvalidID
 	}
 
+	if err := mr.canEdit(maybeID, userID); err != nil {
+		return err
+	}
+
 	const q = `
 	DELETE FROM
 		maybes
@@ -353,23 +562,25 @@ func (mr MaybeRepository) Delete(maybeID string) error {
 	return nil
 }
 
-// QueryTags returns all (unique) tags for a given user.
-func (mr MaybeRepository) QueryTags(userID string) (Tags, error) {
+// QueryTags returns all (unique) tags in the current space of a given user.
+func (mr MaybeRepository) QueryTags(userID string, workspaceID string) (Tags, error) {
 	const q = `
 	SELECT DISTINCT
 		t.*
 	FROM
 		tags AS t
-	LEFT JOIN
+	JOIN
 		maybetags AS mt ON mt.tag_id = t.tag_id
+	JOIN
+		maybes AS m ON m.maybe_id = mt.maybe_id
 	WHERE
-		mt.user_id = $1
+	` + inScope + `
 	ORDER BY
 		t.tag_id
 	`
 
 	var tags Tags
-	if err := mr.Db.Select(&tags, q, userID); err != nil {
+	if err := mr.Db.Select(&tags, q, userID, workspaceID); err != nil {
 		if err == sql.ErrNoRows {
 			return tags, ErrNotFound
 		}
@@ -378,3 +589,142 @@ func (mr MaybeRepository) QueryTags(userID string) (Tags, error) {
 
 	return tags, nil
 }
+
+// QueryTagByID returns the tag with the given ID.
+func (mr MaybeRepository) QueryTagByID(tagID string) (Tag, error) {
+	if _, err := uuid.Parse(tagID); err != nil {
+		return Tag{}, ErrInvalidTag
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		tags
+	WHERE
+		tag_id = $1
+	`
+
+	var tag Tag
+	if err := mr.Db.Get(&tag, q, tagID); err != nil {
+		if err == sql.ErrNoRows {
+			return tag, ErrNotFound
+		}
+		return tag, errors.Wrapf(err, "selecting tag %q", tagID)
+	}
+
+	return tag, nil
+}
+
+// SetReminder sets the reminder of the current user for a maybe, a nil time removes it.
+// Everyone who can read a maybe can set a reminder for themselves.
+func (mr MaybeRepository) SetReminder(maybeID string, remindAt *time.Time, userID string) error {
+	if _, err := uuid.Parse(maybeID); err != nil {
+		return ErrInvalidID
+	}
+
+	role, err := mr.role(maybeID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return ErrForbidden
+	}
+
+	if remindAt == nil {
+		const d = `DELETE FROM reminders WHERE maybe_id = $1 AND user_id = $2`
+		if _, err := mr.Db.Exec(d, maybeID, userID); err != nil {
+			return errors.Wrapf(err, "deleting reminder for maybe %q", maybeID)
+		}
+		return nil
+	}
+
+	const q = `
+	INSERT INTO reminders
+		(maybe_id, user_id, remind_at, created_at)
+	VALUES
+		($1, $2, $3, $4)
+	ON CONFLICT(maybe_id, user_id) DO UPDATE SET
+		remind_at = excluded.remind_at
+	`
+	if _, err := mr.Db.Exec(q, maybeID, userID, remindAt.UTC(), time.Now().UTC().String()); err != nil {
+		return errors.Wrapf(err, "setting reminder for maybe %q", maybeID)
+	}
+	return nil
+}
+
+// Snooze hides a maybe from the lists until the given time and reminds the
+// current user when the snooze ends. A nil time wakes the maybe up again.
+func (mr MaybeRepository) Snooze(maybeID string, until *time.Time, userID string) error {
+	if _, err := uuid.Parse(maybeID); err != nil {
+		return ErrInvalidID
+	}
+
+	// viewers of a workspace can read but not hide maybes
+	if err := mr.canEdit(maybeID, userID); err != nil {
+		return err
+	}
+
+	var snoozedUntil interface{}
+	if until != nil {
+		snoozedUntil = until.UTC()
+	}
+
+	const q = `
+	UPDATE maybes
+	SET
+		snoozed_until = $2
+	WHERE
+		maybe_id = $1
+	`
+	if _, err := mr.Db.Exec(q, maybeID, snoozedUntil); err != nil {
+		return errors.Wrapf(err, "snoozing maybe %q", maybeID)
+	}
+
+	return mr.SetReminder(maybeID, until, userID)
+}
+
+// QueryDueReminders retrieves all reminders that are due at the given time.
+// Reminders of users who lost access to the maybe are left out.
+func (mr MaybeRepository) QueryDueReminders(now time.Time) (Reminders, error) {
+	const q = `
+	SELECT
+		r.maybe_id,
+		m.title,
+		r.user_id,
+		u.name,
+		u.email,
+		r.remind_at
+	FROM reminders AS r
+	JOIN
+		maybes AS m ON m.maybe_id = r.maybe_id
+	JOIN
+		users AS u ON u.user_id = r.user_id
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = r.user_id
+	WHERE
+		r.remind_at <= $1 AND
+		((m.workspace_id IS NULL AND m.user_id = r.user_id) OR wm.user_id IS NOT NULL)
+	ORDER BY
+		r.remind_at
+	`
+	var reminders Reminders
+	if err := mr.Db.Select(&reminders, q, now.UTC()); err != nil {
+		return reminders, errors.Wrap(err, "selecting due reminders")
+	}
+	return reminders, nil
+}
+
+// DeleteReminder removes a delivered reminder. A reminder that has been moved
+// to a later time in the meantime is kept.
+func (mr MaybeRepository) DeleteReminder(rm Reminder) error {
+	const q = `
+	DELETE FROM reminders
+	WHERE
+		maybe_id = $1 AND user_id = $2 AND remind_at <= $3
+	`
+	if _, err := mr.Db.Exec(q, rm.MaybeID, rm.UserID, rm.RemindAt.UTC()); err != nil {
+		return errors.Wrapf(err, "deleting reminder for maybe %q", rm.MaybeID)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package maybe
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func ids(maybes Infos) []string {
+	var ids []string
+	for _, m := range maybes {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestWorkspaceAccess(t *testing.T) {
+	db := datatest.NewDB(t)
+	mr := New(db)
+	owner, editor, viewer, outsider := datatest.User(t, db, "owner"), datatest.User(t, db, "editor"), datatest.User(t, db, "viewer"), datatest.User(t, db, "outsider")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{owner: "owner", editor: "editor", viewer: "viewer"})
+
+	m, err := mr.Create(NewOrUpdateMaybe{Title: "Shared", Url: "https://example.com", Tags: []string{"team"}, WorkspaceID: ws}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.Create(NewOrUpdateMaybe{Title: "By editor", WorkspaceID: ws}, editor); err != nil {
+		t.Errorf("editor creating: %v", err)
+	}
+	for who, userID := range map[string]string{"viewer": viewer, "non-member": outsider} {
+		if _, err := mr.Create(NewOrUpdateMaybe{Title: "Nope", WorkspaceID: ws}, userID); errors.Cause(err) != ErrForbidden {
+			t.Errorf("%s creating: got %v, want %v", who, err, ErrForbidden)
+		}
+	}
+
+	// members read, non-members don't
+	for who, userID := range map[string]string{"owner": owner, "editor": editor, "viewer": viewer} {
+		if _, err := mr.QueryByID(m.ID, userID); err != nil {
+			t.Errorf("%s reading: %v", who, err)
+		}
+		maybes, err := mr.Query(userID, ws, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(maybes) != 2 {
+			t.Errorf("%s listing: got %v, want both maybes of the workspace", who, ids(maybes))
+		}
+	}
+	if _, err := mr.QueryByID(m.ID, outsider); errors.Cause(err) != ErrForbidden {
+		t.Errorf("non-member reading: got %v, want %v", err, ErrForbidden)
+	}
+	if maybes, err := mr.Query(outsider, ws, ""); err != nil || len(maybes) != 0 {
+		t.Errorf("non-member listing: got %v, %v, want none", ids(maybes), err)
+	}
+	if tags, err := mr.QueryTags(outsider, ws); err != nil || len(tags) != 0 {
+		t.Errorf("non-member listing tags: got %v, %v, want none", tags, err)
+	}
+	if _, err := mr.QueryByID(uuid.New().String(), owner); errors.Cause(err) != ErrNotFound {
+		t.Errorf("unknown maybe: got %v, want %v", err, ErrNotFound)
+	}
+
+	// viewers and non-members can't change maybes, editors and owners can
+	for who, userID := range map[string]string{"viewer": viewer, "non-member": outsider} {
+		if err := mr.Update(NewOrUpdateMaybe{Title: "Changed"}, m.ID, userID); errors.Cause(err) != ErrForbidden {
+			t.Errorf("%s updating: got %v, want %v", who, err, ErrForbidden)
+		}
+		if err := mr.Delete(m.ID, userID); errors.Cause(err) != ErrForbidden {
+			t.Errorf("%s deleting: got %v, want %v", who, err, ErrForbidden)
+		}
+	}
+	if err := mr.Update(NewOrUpdateMaybe{Title: "By editor"}, m.ID, editor); err != nil {
+		t.Errorf("editor updating: %v", err)
+	}
+	if err := mr.Update(NewOrUpdateMaybe{Title: "By owner"}, m.ID, owner); err != nil {
+		t.Errorf("owner updating: %v", err)
+	}
+	got, err := mr.QueryByID(m.ID, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "By owner" {
+		t.Errorf("got title %q, want the one of the last update", got.Title)
+	}
+	if err := mr.Delete(m.ID, editor); err != nil {
+		t.Errorf("editor deleting: %v", err)
+	}
+	if _, err := mr.QueryByID(m.ID, owner); errors.Cause(err) != ErrNotFound {
+		t.Errorf("deleted maybe: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestPersonalSpace(t *testing.T) {
+	db := datatest.NewDB(t)
+	mr := New(db)
+	alice, bob := datatest.User(t, db, "alice"), datatest.User(t, db, "bob")
+	// a shared workspace doesn't give access to personal maybes
+	ws := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner", bob: "owner"})
+
+	m, err := mr.Create(NewOrUpdateMaybe{Title: "Mine", Tags: []string{"private"}}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := mr.Create(NewOrUpdateMaybe{Title: "Ours", WorkspaceID: ws}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maybes, err := mr.Query(alice, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(maybes); len(got) != 1 || got[0] != m.ID {
+		t.Errorf("personal space of the author: got %v, want only %s", got, m.ID)
+	}
+	maybes, err = mr.Query(bob, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maybes) != 0 {
+		t.Errorf("personal space of another user: got %v, want none", ids(maybes))
+	}
+	if tags, err := mr.QueryTags(bob, ""); err != nil || len(tags) != 0 {
+		t.Errorf("tags of another user: got %v, %v, want none", tags, err)
+	}
+
+	if _, err := mr.QueryByID(m.ID, bob); errors.Cause(err) != ErrForbidden {
+		t.Errorf("other user reading: got %v, want %v", err, ErrForbidden)
+	}
+	if err := mr.Update(NewOrUpdateMaybe{Title: "Changed"}, m.ID, bob); errors.Cause(err) != ErrForbidden {
+		t.Errorf("other user updating: got %v, want %v", err, ErrForbidden)
+	}
+	if err := mr.Delete(m.ID, bob); errors.Cause(err) != ErrForbidden {
+		t.Errorf("other user deleting: got %v, want %v", err, ErrForbidden)
+	}
+	if _, err := mr.QueryByID(shared.ID, bob); err != nil {
+		t.Errorf("co-owner reading the workspace maybe: %v", err)
+	}
+	if err := mr.Delete(m.ID, alice); err != nil {
+		t.Errorf("author deleting: %v", err)
+	}
+}
@@ -1,5 +1,14 @@
 package maybe
 
+import "time"
+
+// Priorities of a maybe. Maybes without a priority have an empty priority.
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 // Tag is the model for a tag.
 type Tag struct {
 	ID   string `db:"tag_id"`
@@ -10,14 +19,21 @@ type Tags []Tag
 
 // Info is the model for maybes.
 type Info struct {
-	ID          string `db:"maybe_id"`
-	UserID      string `db:"user_id"`
-	Title       string `db:"title"`
-	Url         string `db:"url"`
-	Description string `db:"description"`
-	Tags        []Tag  `db:"tags"`
-	DateCreated string `db:"created_at"`
-	DateUpdated string `db:"updated_at"`
+	ID           string     `db:"maybe_id"`
+	UserID       string     `db:"user_id"`
+	WorkspaceID  *string    `db:"workspace_id"`
+	Title        string     `db:"title"`
+	Url          string     `db:"url"`
+	Description  string     `db:"description"`
+	Priority     string     `db:"priority"`
+	Effort       int        `db:"effort"`
+	DueDate      *time.Time `db:"due_date"`
+	RemindAt     *time.Time `db:"remind_at"`
+	SnoozedUntil *time.Time `db:"snoozed_until"`
+	Tags         []Tag      `db:"tags"`
+	DateCreated  string     `db:"created_at"`
+	DateUpdated  string     `db:"updated_at"`
+	Score        int        `db:"score"`
 }
 
 type Infos []Info
@@ -25,13 +41,33 @@ type Infos []Info
 // NewOrUpdateMaybe is the data for creating a new maybe
 // or updating an existing maybe.
 // Adding Tags is optional.
+// WorkspaceID is only used when creating a maybe, an empty ID
+// creates the maybe in the personal space of the user.
+// Priority, Effort (in hours) and DueDate are optional and always
+// overwrite the previous values on update, so they can be cleared.
 type NewOrUpdateMaybe struct {
 	Title       string
 	Url         string
 	Description string
+	Priority    string
+	Effort      int
+	DueDate     *time.Time
 	Tags        []string
+	WorkspaceID string
 }
 
+// Reminder is the model for a due reminder of a user for a maybe.
+type Reminder struct {
+	MaybeID  string    `db:"maybe_id"`
+	Title    string    `db:"title"`
+	UserID   string    `db:"user_id"`
+	Name     string    `db:"name"`
+	Email    string    `db:"email"`
+	RemindAt time.Time `db:"remind_at"`
+}
+
+type Reminders []Reminder
+
 // NewTag is the data for creating a new tag.
 type NewTag struct {
 	Name string `db:"name"`
@@ -0,0 +1,46 @@
+package maybe
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// priorityWeights maps priorities to their weight in the urgency score.
+var priorityWeights = map[string]float64{
+	PriorityLow:    1,
+	PriorityMedium: 2,
+	PriorityHigh:   3,
+}
+
+// Urgency combines priority, due date and age of a maybe into a score,
+// the higher the score the sooner the maybe should be done.
+// A priority adds 10 points per level. A due date adds up to 30 points the
+// closer it gets, overdue maybes get all 30. Older maybes get one point per
+// week of age, at most 20, so that nothing is forgotten forever.
+func (m Info) Urgency(now time.Time) float64 {
+	score := 10 * priorityWeights[m.Priority]
+
+	if m.DueDate != nil {
+		days := m.DueDate.Sub(now).Hours() / 24
+		score += math.Min(30, math.Max(0, 30-days))
+	}
+
+	if created, err := time.Parse(time.RFC3339Nano, m.DateCreated); err == nil {
+		weeks := now.Sub(created).Hours() / (24 * 7)
+		score += math.Min(20, math.Max(0, weeks))
+	}
+
+	return score
+}
+
+// UpNext sorts maybes by urgency, the most urgent first.
+// Maybes with the same urgency keep their order.
+func UpNext(maybes Infos, now time.Time) Infos {
+	sorted := make(Infos, len(maybes))
+	copy(sorted, maybes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Urgency(now) > sorted[j].Urgency(now)
+	})
+	return sorted
+}
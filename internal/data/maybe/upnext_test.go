@@ -0,0 +1,92 @@
+package maybe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUrgency(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	due := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	created := func(d time.Duration) string {
+		return now.Add(-d).Format(time.RFC3339Nano)
+	}
+
+	tests := []struct {
+		name  string
+		maybe Info
+		want  float64
+	}{
+		{
+			name:  "Empty",
+			maybe: Info{DateCreated: created(0)},
+			want:  0,
+		},
+		{
+			name:  "High priority",
+			maybe: Info{Priority: PriorityHigh, DateCreated: created(0)},
+			want:  30,
+		},
+		{
+			name:  "Due in ten days",
+			maybe: Info{DueDate: due(10 * 24 * time.Hour), DateCreated: created(0)},
+			want:  20,
+		},
+		{
+			name:  "Overdue",
+			maybe: Info{DueDate: due(-48 * time.Hour), DateCreated: created(0)},
+			want:  30,
+		},
+		{
+			name:  "Due far in the future",
+			maybe: Info{DueDate: due(90 * 24 * time.Hour), DateCreated: created(0)},
+			want:  0,
+		},
+		{
+			name:  "Two weeks old",
+			maybe: Info{Priority: PriorityLow, DateCreated: created(14 * 24 * time.Hour)},
+			want:  12,
+		},
+		{
+			name:  "Age is capped",
+			maybe: Info{DateCreated: created(365 * 24 * time.Hour)},
+			want:  20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.maybe.Urgency(now); got != tt.want {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUpNext(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tomorrow := now.Add(24 * time.Hour)
+	created := now.Format(time.RFC3339Nano)
+
+	maybes := Infos{
+		{ID: "low", Priority: PriorityLow, DateCreated: created},
+		{ID: "none", DateCreated: created},
+		{ID: "due", Priority: PriorityMedium, DueDate: &tomorrow, DateCreated: created},
+		{ID: "high", Priority: PriorityHigh, DateCreated: created},
+	}
+
+	got := UpNext(maybes, now)
+
+	want := []string{"due", "high", "low", "none"}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Fatalf("want %v at position %d; got %v", id, i, got[i].ID)
+		}
+	}
+	if maybes[0].ID != "low" {
+		t.Errorf("UpNext must not reorder its input")
+	}
+}
@@ -0,0 +1,22 @@
+package notification
+
+import "time"
+
+// Info is the model for a notification in the in-app inbox.
+type Info struct {
+	ID          string     `db:"notification_id"`
+	UserID      string     `db:"user_id"`
+	Message     string     `db:"message"`
+	Link        string     `db:"link"`
+	ReadAt      *time.Time `db:"read_at"`
+	DateCreated string     `db:"created_at"`
+}
+
+type Infos []Info
+
+// NewNotification is the data for creating a new notification.
+type NewNotification struct {
+	UserID  string
+	Message string
+	Link    string
+}
@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific notification is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+)
+
+// NotificationRepository defines the repository for the notification service.
+type NotificationRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a pointer to a notification repo.
+func New(db *sqlx.DB) NotificationRepository {
+	return NotificationRepository{Db: db}
+}
+
+// Create adds a new unread notification for a user.
+func (nr NotificationRepository) Create(nn NewNotification) (Info, error) {
+	n := Info{
+		ID:          uuid.New().String(),
+		UserID:      nn.UserID,
+		Message:     nn.Message,
+		Link:        nn.Link,
+		DateCreated: time.Now().UTC().String(),
+	}
+
+	const q = `
+	INSERT INTO notifications
+		(notification_id, user_id, message, link, created_at)
+	VALUES
+		($1, $2, $3, $4, $5)
+	`
+	if _, err := nr.Db.Exec(q, n.ID, n.UserID, n.Message, n.Link, n.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting notification")
+	}
+
+	return n, nil
+}
+
+// Query retrieves the latest notifications of the current user.
+func (nr NotificationRepository) Query(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		*
+	FROM notifications
+	WHERE
+		user_id = $1
+	ORDER BY
+		created_at DESC
+	LIMIT 100
+	`
+	var notifications Infos
+	if err := nr.Db.Select(&notifications, q, userID); err != nil {
+		return notifications, errors.Wrap(err, "selecting notifications")
+	}
+	return notifications, nil
+}
+
+// QueryAll retrieves all notifications of a user, the oldest first.
+func (nr NotificationRepository) QueryAll(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		*
+	FROM notifications
+	WHERE
+		user_id = $1
+	ORDER BY
+		created_at
+	`
+	var notifications Infos
+	if err := nr.Db.Select(&notifications, q, userID); err != nil {
+		return notifications, errors.Wrapf(err, "selecting notifications of user %q", userID)
+	}
+	return notifications, nil
+}
+
+// MarkRead marks a notification of the current user as read and returns it.
+func (nr NotificationRepository) MarkRead(notificationID string, userID string) (Info, error) {
+	if _, err := uuid.Parse(notificationID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM notifications
+	WHERE
+		notification_id = $1 AND user_id = $2
+	`
+	var n Info
+	if err := nr.Db.Get(&n, q, notificationID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting notification %q", notificationID)
+	}
+
+	const u = `
+	UPDATE notifications
+	SET
+		read_at = $2
+	WHERE
+		notification_id = $1 AND read_at IS NULL
+	`
+	if _, err := nr.Db.Exec(u, notificationID, time.Now().UTC()); err != nil {
+		return Info{}, errors.Wrapf(err, "marking notification %q as read", notificationID)
+	}
+
+	return n, nil
+}
+
+// MarkAllRead marks all notifications of the current user as read.
+func (nr NotificationRepository) MarkAllRead(userID string) error {
+	const q = `
+	UPDATE notifications
+	SET
+		read_at = $2
+	WHERE
+		user_id = $1 AND read_at IS NULL
+	`
+	if _, err := nr.Db.Exec(q, userID, time.Now().UTC()); err != nil {
+		return errors.Wrap(err, "marking notifications as read")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Violation is a row whose foreign key points to a row that doesn't exist.
+type Violation struct {
+	Table  string `db:"table"`
+	RowID  int64  `db:"rowid"`
+	Parent string `db:"parent"`
+	FKID   int    `db:"fkid"`
+}
+
+// Report tells what Maintain found and changed, or would change in a dry run.
+type Report struct {
+	DryRun     bool
+	Violations []Violation
+	// DanglingMaybeTags are tag links to maybes, tags or users that are gone.
+	DanglingMaybeTags int64
+	// OrphanedTags are tags no maybe uses anymore.
+	OrphanedTags int64
+	Analyzed     bool
+	Vacuumed     bool
+	SizeBefore   int64
+	SizeAfter    int64
+}
+
+// Maintain checks the integrity and the foreign keys of the database, removes
+// dangling tag links and orphaned tags, and then updates the statistics of the
+// query planner and compacts the database. A dry run only reports what would
+// be removed. A damaged database is left alone.
+func Maintain(db *sqlx.DB, dryRun bool) (Report, error) {
+	r := Report{DryRun: dryRun}
+
+	var problems []string
+	if err := db.Select(&problems, `PRAGMA integrity_check`); err != nil {
+		return r, errors.Wrap(err, "checking integrity")
+	}
+	if len(problems) != 1 || problems[0] != "ok" {
+		return r, errors.Errorf("database is damaged, restore a backup: %s", strings.Join(problems, "; "))
+	}
+
+	if err := db.Select(&r.Violations, `SELECT "table", rowid, parent, fkid FROM pragma_foreign_key_check`); err != nil {
+		return r, errors.Wrap(err, "checking foreign keys")
+	}
+
+	size, err := databaseSize(db)
+	if err != nil {
+		return r, err
+	}
+	r.SizeBefore, r.SizeAfter = size, size
+
+	if err := repair(db, &r); err != nil {
+		return r, err
+	}
+	if dryRun {
+		return r, nil
+	}
+
+	if _, err := db.Exec(`ANALYZE`); err != nil {
+		return r, errors.Wrap(err, "analyzing database")
+	}
+	r.Analyzed = true
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return r, errors.Wrap(err, "vacuuming database")
+	}
+	r.Vacuumed = true
+
+	r.SizeAfter, err = databaseSize(db)
+	return r, err
+}
+
+// repair removes dangling tag links and then the tags no maybe uses anymore.
+// In a dry run, the changes are counted and rolled back.
+func repair(db *sqlx.DB, r *Report) error {
+	tx, err := db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const mt = `
+	DELETE FROM
+		maybetags
+	WHERE
+		maybe_id NOT IN (SELECT maybe_id FROM maybes) OR
+		tag_id NOT IN (SELECT tag_id FROM tags) OR
+		user_id NOT IN (SELECT user_id FROM users)
+	`
+	if r.DanglingMaybeTags, err = affectedRows(tx, mt); err != nil {
+		return errors.Wrap(err, "deleting dangling tag links")
+	}
+
+	const t = `
+	DELETE FROM
+		tags AS t
+	WHERE NOT EXISTS (
+		SELECT NULL FROM maybetags AS mt
+		WHERE
+			t.tag_id = mt.tag_id
+	)
+	`
+	if r.OrphanedTags, err = affectedRows(tx, t); err != nil {
+		return errors.Wrap(err, "deleting orphaned tags")
+	}
+
+	if r.DryRun {
+		return nil
+	}
+	return tx.Commit()
+}
+
+func affectedRows(tx *sqlx.Tx, q string) (int64, error) {
+	res, err := tx.Exec(q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// databaseSize returns the size of the database in bytes.
+func databaseSize(db *sqlx.DB) (int64, error) {
+	var size int64
+	if err := db.Get(&size, `SELECT page_count * page_size FROM pragma_page_count, pragma_page_size`); err != nil {
+		return 0, errors.Wrap(err, "getting database size")
+	}
+	return size, nil
+}
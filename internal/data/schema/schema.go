@@ -7,11 +7,33 @@ import (
 )
 
 // Migrate attempts to bring the schema for db up to date with the migrations
-// defined in this package.
+// defined in this package. It fails without changing anything if a migration
+// that was applied has been edited since.
 func Migrate(db *sqlx.DB) error {
-	driver := darwin.NewGenericDriver(db.DB, darwin.SqliteDialect{})
-	d := darwin.New(driver, migrations)
-	return d.Migrate()
+	d := darwin.New(newDriver(db), darwinMigrations())
+	return checked(d.Migrate())
+}
+
+// migration is a darwin migration with a Down script that undoes it.
+type migration struct {
+	Version     int
+	Description string
+	Script      string
+	Down        string
+}
+
+// darwinMigrations returns the migrations for darwin, which only knows how to
+// migrate up.
+func darwinMigrations() []darwin.Migration {
+	ms := make([]darwin.Migration, 0, len(migrations))
+	for _, m := range migrations {
+		ms = append(ms, darwin.Migration{Version: m.Version, Description: m.Description, Script: m.Script})
+	}
+	return ms
+}
+
+func newDriver(db *sqlx.DB) *darwin.GenericDriver {
+	return darwin.NewGenericDriver(db.DB, darwin.SqliteDialect{})
 }
 
 // migrations contains the queries needed to construct the database schema.
@@ -22,7 +44,10 @@ func Migrate(db *sqlx.DB) error {
 // directory. It has the downside that it lacks syntax highlighting and may be
 // harder to read for some cases compared to using .sql files. You may also
 // consider a combined approach using a tool like packr or go-bindata.
-var migrations = []darwin.Migration{
+//
+// Every migration needs a Down script that restores the schema of the version
+// before, so that it can be rolled back.
+var migrations = []migration{
 	{
 		Version:     1,
 		Description: "Create table users, maybes, tag",
@@ -67,6 +92,498 @@ FOREIGN KEY(maybe_id) REFERENCES maybes(maybe_id) ON DELETE CASCADE,
 FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
 UNIQUE(tag_id, maybe_id)
 );
+`,
+		Down: `
+DROP TABLE maybetags;
+DROP TABLE tags;
+DROP TABLE maybes;
+DROP TABLE users;
+`,
+	},
+	{
+		Version:     2,
+		Description: "Create table shares",
+		Script: `
+-- Public read-only links for a maybe or a tag view
+CREATE TABLE shares (
+	share_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	kind           TEXT NOT NULL,
+	target_id      UUID NOT NULL,
+	token          TEXT NOT NULL UNIQUE,
+	views          INTEGER NOT NULL DEFAULT 0,
+	expires_at     TIMESTAMP,
+	created_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(share_id),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE shares;
+`,
+	},
+	{
+		Version:     3,
+		Description: "Create table workspaces, workspacemembers, workspaceinvites",
+		Script: `
+-- Create workspaces
+CREATE TABLE workspaces (
+	workspace_id   UUID NOT NULL,
+	name           TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	updated_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(workspace_id)
+);
+-- Linking table for the membership of users in workspaces
+CREATE TABLE workspacemembers (
+	workspace_id   UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	role           TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+UNIQUE(workspace_id, user_id)
+);
+-- Pending invitations by email
+CREATE TABLE workspaceinvites (
+	invite_id      UUID NOT NULL,
+	workspace_id   UUID NOT NULL,
+	email          TEXT NOT NULL,
+	role           TEXT NOT NULL,
+	invited_by     UUID NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(invite_id),
+FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+FOREIGN KEY(invited_by) REFERENCES users(user_id) ON DELETE CASCADE,
+UNIQUE(workspace_id, email)
+);
+-- Maybes without a workspace belong to the personal space of their user
+ALTER TABLE maybes ADD COLUMN workspace_id UUID REFERENCES workspaces(workspace_id) ON DELETE CASCADE;
+`,
+		Down: `
+-- Columns with a foreign key can't be dropped, so maybes are copied to a table without it
+CREATE TABLE maybes_v2 (
+	maybe_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	title          TEXT NOT NULL,
+	url            TEXT NOT NULL,
+	description    TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	updated_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(maybe_id),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+INSERT INTO maybes_v2 SELECT maybe_id, user_id, title, url, description, created_at, updated_at FROM maybes;
+DROP TABLE maybes;
+ALTER TABLE maybes_v2 RENAME TO maybes;
+DROP TABLE workspaceinvites;
+DROP TABLE workspacemembers;
+DROP TABLE workspaces;
+`,
+	},
+	{
+		Version:     4,
+		Description: "Create table comments, notifications",
+		Script: `
+-- Create comments, replies reference their parent comment
+CREATE TABLE comments (
+	comment_id     UUID NOT NULL,
+	maybe_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	parent_id      UUID,
+	body           TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	updated_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(comment_id),
+FOREIGN KEY(maybe_id) REFERENCES maybes(maybe_id) ON DELETE CASCADE,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+FOREIGN KEY(parent_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
+-- Create notifications for the in-app inbox
+CREATE TABLE notifications (
+	notification_id UUID NOT NULL,
+	user_id         UUID NOT NULL,
+	message         TEXT NOT NULL,
+	link            TEXT NOT NULL,
+	read_at         TIMESTAMP,
+	created_at      TIMESTAMP NOT NULL,
+PRIMARY KEY(notification_id),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE notifications;
+DROP TABLE comments;
+`,
+	},
+	{
+		Version:     5,
+		Description: "Create table votes, votingrounds, roundvotes",
+		Script: `
+-- Up- and downvotes, one per user and maybe
+CREATE TABLE votes (
+	maybe_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	value          INTEGER NOT NULL CHECK(value IN (-1, 1)),
+	created_at     TIMESTAMP NOT NULL,
+FOREIGN KEY(maybe_id) REFERENCES maybes(maybe_id) ON DELETE CASCADE,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+UNIQUE(maybe_id, user_id)
+);
+-- Dot-voting rounds of a workspace with a budget of dots per member
+CREATE TABLE votingrounds (
+	round_id       UUID NOT NULL,
+	workspace_id   UUID NOT NULL,
+	name           TEXT NOT NULL,
+	budget         INTEGER NOT NULL CHECK(budget > 0),
+	closes_at      TIMESTAMP NOT NULL,
+	created_by     UUID NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(round_id),
+FOREIGN KEY(workspace_id) REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE CASCADE
+);
+-- Dots a member spent on a maybe in a voting round
+CREATE TABLE roundvotes (
+	round_id       UUID NOT NULL,
+	maybe_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	dots           INTEGER NOT NULL CHECK(dots > 0),
+FOREIGN KEY(round_id) REFERENCES votingrounds(round_id) ON DELETE CASCADE,
+FOREIGN KEY(maybe_id) REFERENCES maybes(maybe_id) ON DELETE CASCADE,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+UNIQUE(round_id, maybe_id, user_id)
+);
+`,
+		Down: `
+DROP TABLE roundvotes;
+DROP TABLE votingrounds;
+DROP TABLE votes;
+`,
+	},
+	{
+		Version:     6,
+		Description: "Add priority, effort and due date to maybes",
+		Script: `
+-- Priority is one of low, medium, high or empty, effort is estimated in hours
+ALTER TABLE maybes ADD COLUMN priority TEXT NOT NULL DEFAULT '';
+ALTER TABLE maybes ADD COLUMN effort INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE maybes ADD COLUMN due_date TIMESTAMP;
+`,
+		Down: `
+ALTER TABLE maybes DROP COLUMN due_date;
+ALTER TABLE maybes DROP COLUMN effort;
+ALTER TABLE maybes DROP COLUMN priority;
+`,
+	},
+	{
+		Version:     7,
+		Description: "Create table reminders, add snooze to maybes",
+		Script: `
+-- Reminders of a user for a maybe, removed once they have been delivered
+CREATE TABLE reminders (
+	maybe_id       UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	remind_at      TIMESTAMP NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+FOREIGN KEY(maybe_id) REFERENCES maybes(maybe_id) ON DELETE CASCADE,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+UNIQUE(maybe_id, user_id)
+);
+-- Snoozed maybes are hidden from the lists until the snooze ends
+ALTER TABLE maybes ADD COLUMN snoozed_until TIMESTAMP;
+`,
+		Down: `
+ALTER TABLE maybes DROP COLUMN snoozed_until;
+DROP TABLE reminders;
+`,
+	},
+	{
+		Version:     8,
+		Description: "Add digest settings to users",
+		Script: `
+-- Weekly digest by email, sent on a weekday at an hour in UTC
+ALTER TABLE users ADD COLUMN digest_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN digest_weekday INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN digest_hour INTEGER NOT NULL DEFAULT 8;
+ALTER TABLE users ADD COLUMN digest_sent_at TIMESTAMP;
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN digest_sent_at;
+ALTER TABLE users DROP COLUMN digest_hour;
+ALTER TABLE users DROP COLUMN digest_weekday;
+ALTER TABLE users DROP COLUMN digest_enabled;
+`,
+	},
+	{
+		Version:     9,
+		Description: "Add email verification to users",
+		Script: `
+-- Users who signed up before email verification count as verified
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+UPDATE users SET verified_at = CURRENT_TIMESTAMP;
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN verified_at;
+`,
+	},
+	{
+		Version:     10,
+		Description: "Create table password_resets, add session version to users",
+		Script: `
+-- Password reset tokens, only a hash of the token is stored
+CREATE TABLE password_resets (
+	token_hash     TEXT NOT NULL,
+	user_id        UUID NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	expires_at     TIMESTAMP NOT NULL,
+	used_at        TIMESTAMP,
+PRIMARY KEY(token_hash),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+-- Sessions remember the version they were created with, bumping it logs out everywhere
+ALTER TABLE users ADD COLUMN session_version INTEGER NOT NULL DEFAULT 0;
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN session_version;
+DROP TABLE password_resets;
+`,
+	},
+	{
+		Version:     11,
+		Description: "Add two-factor authentication to users, create table recovery_codes",
+		Script: `
+-- The TOTP secret is pending until totp_enabled_at is set, the last step keeps codes from being reused
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+-- One-time recovery codes for two-factor authentication, only a hash of the code is stored
+CREATE TABLE recovery_codes (
+	code_hash      TEXT NOT NULL,
+	user_id        UUID NOT NULL,
+	used_at        TIMESTAMP,
+PRIMARY KEY(code_hash),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
+`,
+	},
+	{
+		Version:     12,
+		Description: "Create table login_attempts, add lockout to users",
+		Script: `
+-- Audit records of failed logins, user_id is set if the email belongs to a user
+CREATE TABLE login_attempts (
+	email          TEXT NOT NULL,
+	user_id        UUID,
+	ip             TEXT NOT NULL,
+	reason         TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+-- Failed logins since the last successful one, logins wait until locked_until
+ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP;
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN failed_logins;
+DROP TABLE login_attempts;
+`,
+	},
+	{
+		Version:     13,
+		Description: "Create table user_sessions",
+		Script: `
+-- Logged in sessions of a user, deleting a row logs the session out
+CREATE TABLE user_sessions (
+	session_id     UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	ip             TEXT NOT NULL,
+	user_agent     TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	last_seen_at   TIMESTAMP NOT NULL,
+PRIMARY KEY(session_id),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE user_sessions;
+`,
+	},
+	{
+		Version:     14,
+		Description: "Create table sessions",
+		Script: `
+-- Session data of the session manager, expired sessions are cleaned up regularly
+CREATE TABLE sessions (
+	token          TEXT NOT NULL,
+	data           BLOB NOT NULL,
+	expiry         TIMESTAMP NOT NULL,
+PRIMARY KEY(token)
+);
+`,
+		Down: `
+DROP TABLE sessions;
+`,
+	},
+	{
+		Version:     15,
+		Description: "Create table user_identities",
+		Script: `
+-- Identities of users at OpenID Connect providers
+CREATE TABLE user_identities (
+	issuer         TEXT NOT NULL,
+	subject        TEXT NOT NULL,
+	user_id        UUID NOT NULL,
+	email          TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(issuer, subject),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE user_identities;
+`,
+	},
+	{
+		Version:     16,
+		Description: "Add account deletion to users, create table exports",
+		Script: `
+-- Accounts are deleted for good once delete_at has passed
+ALTER TABLE users ADD COLUMN delete_at TIMESTAMP;
+-- Archives of the personal data of a user, built in the background
+CREATE TABLE exports (
+	export_id      UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	status         TEXT NOT NULL,
+	archive        BLOB,
+	created_at     TIMESTAMP NOT NULL,
+	finished_at    TIMESTAMP,
+PRIMARY KEY(export_id),
+FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+		Down: `
+DROP TABLE exports;
+ALTER TABLE users DROP COLUMN delete_at;
+`,
+	},
+	{
+		Version:     17,
+		Description: "Add role to users",
+		Script: `
+-- Admins can manage the app, everyone else is a user
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN role;
+`,
+	},
+	{
+		Version:     18,
+		Description: "Create table settings",
+		Script: `
+-- Instance settings changed by admins, missing ones have their default
+CREATE TABLE settings (
+	name           TEXT NOT NULL,
+	value          TEXT NOT NULL,
+	updated_at     TIMESTAMP NOT NULL,
+PRIMARY KEY(name)
+);
+`,
+		Down: `
+DROP TABLE settings;
+`,
+	},
+	{
+		Version:     19,
+		Description: "Add registration modes and create table invites",
+		Script: `
+-- The open registration switch became a registration mode
+INSERT INTO settings (name, value, updated_at)
+SELECT 'registration', CASE value WHEN 'true' THEN 'open' ELSE 'closed' END, updated_at
+FROM settings WHERE name = 'open_registration';
+DELETE FROM settings WHERE name = 'open_registration';
+-- Single-use codes to sign up while registration is invite-only
+CREATE TABLE invites (
+	code           TEXT NOT NULL,
+	created_by     UUID NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	used_by        UUID,
+	used_at        TIMESTAMP,
+PRIMARY KEY(code),
+FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE CASCADE,
+FOREIGN KEY(used_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+CREATE INDEX invites_created_by_idx ON invites(created_by);
+`,
+		Down: `
+DROP TABLE invites;
+-- Only open stays open, invite-only and domain registration close
+INSERT INTO settings (name, value, updated_at)
+SELECT 'open_registration', CASE value WHEN 'open' THEN 'true' ELSE 'false' END, updated_at
+FROM settings WHERE name = 'registration';
+DELETE FROM settings WHERE name IN ('registration', 'allowed_domains', 'invite_quota');
+`,
+	},
+	{
+		Version:     20,
+		Description: "Add password_set to users",
+		Script: `
+-- Users who signed up with OpenID Connect got a random password they never saw
+ALTER TABLE users ADD COLUMN password_set BOOLEAN NOT NULL DEFAULT TRUE;
+-- Which users with an identity chose a password is not known, they may confirm
+-- changes at the identity provider as well
+UPDATE users SET password_set = FALSE WHERE user_id IN (SELECT user_id FROM user_identities);
+`,
+		Down: `
+ALTER TABLE users DROP COLUMN password_set;
+`,
+	},
+	{
+		Version:     21,
+		Description: "Add handle to users",
+		Script: `
+-- Handles used to be the local part of the email address, which showed it to
+-- everyone in a workspace. Derive them from the name instead; users with the
+-- same name get a number in the order they joined.
+ALTER TABLE users ADD COLUMN handle TEXT;
+UPDATE users SET handle = (
+	SELECT CASE WHEN n > 1 THEN base || '_' || n ELSE base END
+	FROM (
+		SELECT user_id, base, ROW_NUMBER() OVER (PARTITION BY base ORDER BY created_at, user_id) AS n
+		FROM (
+			SELECT user_id, created_at, COALESCE(NULLIF(TRIM(
+				REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(
+					LOWER(TRIM(name)), ' ', '.'), '_', ''), '@', ''), '''', ''), '"', ''), ',', ''), '(', ''), ')', ''),
+				'.-'), ''), 'user') AS base
+			FROM users
+		)
+	) AS handles
+	WHERE handles.user_id = users.user_id
+);
+CREATE UNIQUE INDEX users_handle_idx ON users(handle);
+`,
+		Down: `
+DROP INDEX users_handle_idx;
+ALTER TABLE users DROP COLUMN handle;
+`,
+	},
+	{
+		Version:     22,
+		Description: "Fix active of seeded users",
+		Script: `
+-- Seeded users were active as the string 'true', which active = TRUE never matched
+UPDATE users SET active = TRUE WHERE active = 'true';
+`,
+		Down: `
+-- Users stay active as TRUE, which works with every version
 `,
 	},
 }
@@ -0,0 +1,167 @@
+package schema
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+)
+
+func newDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "schema.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRollback(t *testing.T) {
+	db := newDB(t)
+	latest := migrations[len(migrations)-1].Version
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// rolling back to the first version keeps the maybes and their tags
+	versions, err := Rollback(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != latest-1 || versions[0] != latest || versions[len(versions)-1] != 2 {
+		t.Errorf("want versions %d to 2 rolled back; got %v", latest, versions)
+	}
+	var maybes, maybetags int
+	db.Get(&maybes, `SELECT COUNT(*) FROM maybes`)
+	db.Get(&maybetags, `SELECT COUNT(*) FROM maybetags`)
+	if maybes != 2 || maybetags != 3 {
+		t.Errorf("want 2 maybes and 3 tag links; got %d and %d", maybes, maybetags)
+	}
+
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != latest-1 || pending[0].Version != 2 || pending[0].Script == "" {
+		t.Errorf("want versions 2 to %d pending; got %d pending", latest, len(pending))
+	}
+
+	// migrating up again works on the rolled back schema
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Rollback(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range status {
+		if !v.Applied || v.Modified || v.Removed {
+			t.Errorf("want version %d applied; got %+v", v.Version, v)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	db := newDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	script := migrations[1].Script
+	migrations[1].Script += "-- edited\n"
+	defer func() { migrations[1].Script = script }()
+
+	status, err := Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status[1].Modified || status[0].Modified {
+		t.Errorf("want only version 2 modified; got %+v", status[:2])
+	}
+
+	for name, fn := range map[string]func() error{
+		"Migrate":  func() error { return Migrate(db) },
+		"Validate": func() error { return Validate(db) },
+		"Rollback": func() error { _, err := Rollback(db, 0); return err },
+	} {
+		if err := fn(); err == nil || !strings.Contains(err.Error(), "migration 2 was edited") {
+			t.Errorf("%s: want checksum error; got %v", name, err)
+		}
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	db := newDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// older versions left orphaned tags and links to deleted maybes behind
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const garbage = `
+	PRAGMA foreign_keys = OFF;
+	INSERT INTO tags (tag_id, name) VALUES ('0b4bb5a4-3b2b-4bd5-9d1c-6f3a4f6f1b01', 'orphan');
+	DELETE FROM maybes WHERE maybe_id = '45b5fbd3-755f-4379-8f07-a58d4a30fa2f';
+	PRAGMA foreign_keys = ON;
+	`
+	if _, err := conn.ExecContext(context.Background(), garbage); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	r, err := Maintain(db, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the watchlist tag was only linked to the deleted maybe
+	if len(r.Violations) != 1 || r.Violations[0].Table != "maybetags" || r.DanglingMaybeTags != 1 || r.OrphanedTags != 2 || r.Vacuumed {
+		t.Errorf("want 1 dangling link and 2 orphaned tags found; got %+v", r)
+	}
+	var tags int
+	db.Get(&tags, `SELECT COUNT(*) FROM tags`)
+	if tags != 4 {
+		t.Errorf("want tags kept in a dry run; got %d tags", tags)
+	}
+
+	r, err = Maintain(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.DanglingMaybeTags != 1 || r.OrphanedTags != 2 || !r.Analyzed || !r.Vacuumed {
+		t.Errorf("want 1 dangling link and 2 orphaned tags removed; got %+v", r)
+	}
+	db.Get(&tags, `SELECT COUNT(*) FROM tags`)
+	if tags != 2 {
+		t.Errorf("want 2 tags left; got %d", tags)
+	}
+
+	r, err = Maintain(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Violations) != 0 || r.DanglingMaybeTags != 0 || r.OrphanedTags != 0 {
+		t.Errorf("want nothing left to repair; got %+v", r)
+	}
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dimiro1/darwin"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Version is the state of a migration in a database. Migrations that were
+// applied and then removed from this package are Removed, the ones whose
+// script changed after they were applied are Modified.
+type Version struct {
+	Version     int
+	Description string
+	Script      string
+	Applied     bool
+	AppliedAt   time.Time
+	Modified    bool
+	Removed     bool
+}
+
+// Status returns the state of all migrations, the oldest first.
+func Status(db *sqlx.DB) ([]Version, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, m := range darwinMigrations() {
+		v := Version{Version: m.Version, Description: m.Description, Script: m.Script}
+		if rec, ok := applied[m.Version]; ok {
+			v.Applied = true
+			v.AppliedAt = rec.AppliedAt
+			v.Modified = rec.Checksum != m.Checksum()
+			delete(applied, m.Version)
+		}
+		versions = append(versions, v)
+	}
+	for _, rec := range applied {
+		versions = append(versions, Version{Version: rec.Version, Description: rec.Description, Applied: true, AppliedAt: rec.AppliedAt, Removed: true})
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// Pending returns the migrations Migrate would apply, the oldest first.
+func Pending(db *sqlx.DB) ([]Version, error) {
+	if err := Validate(db); err != nil {
+		return nil, err
+	}
+
+	versions, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// like darwin, only migrations newer than the latest applied one are run
+	latest := 0
+	for _, v := range versions {
+		if v.Applied && v.Version > latest {
+			latest = v.Version
+		}
+	}
+
+	var pending []Version
+	for _, v := range versions {
+		if v.Version > latest {
+			pending = append(pending, v)
+		}
+	}
+	return pending, nil
+}
+
+// Validate checks that no applied migration was edited or removed since.
+func Validate(db *sqlx.DB) error {
+	driver := newDriver(db)
+	if err := driver.Create(); err != nil {
+		return errors.Wrap(err, "creating migrations table")
+	}
+	return checked(darwin.Validate(driver, darwinMigrations()))
+}
+
+// Rollback undoes all migrations newer than version with their Down scripts,
+// the newest first, and returns the versions it rolled back. Either all of
+// them are rolled back or none.
+func Rollback(db *sqlx.DB, version int) ([]int, error) {
+	if err := Validate(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok || m.Version <= version {
+			continue
+		}
+		if m.Down == "" {
+			return nil, errors.Errorf("migration %d can't be rolled back", m.Version)
+		}
+		undo = append(undo, m)
+	}
+	if len(undo) == 0 {
+		return nil, nil
+	}
+
+	// Down scripts copy tables, which must not cascade to the rows referencing
+	// them. Foreign keys can only be turned off outside of a transaction, so
+	// the rollback gets a connection of its own.
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting connection")
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return nil, errors.Wrap(err, "turning off foreign keys")
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	var versions []int
+	for _, m := range undo {
+		if _, err := tx.Exec(m.Down); err != nil {
+			return nil, errors.Wrapf(err, "rolling back migration %d", m.Version)
+		}
+		if _, err := tx.Exec(`DELETE FROM darwin_migrations WHERE version = $1`, m.Version); err != nil {
+			return nil, errors.Wrapf(err, "removing migration %d", m.Version)
+		}
+		versions = append(versions, m.Version)
+	}
+
+	var violations int
+	if err := tx.Get(&violations, `SELECT COUNT(*) FROM pragma_foreign_key_check`); err != nil {
+		return nil, errors.Wrap(err, "checking foreign keys")
+	}
+	if violations > 0 {
+		return nil, errors.Errorf("rollback would break %d foreign keys", violations)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing rollback")
+	}
+	return versions, nil
+}
+
+// appliedMigrations returns the records of the applied migrations by version.
+func appliedMigrations(db *sqlx.DB) (map[int]darwin.MigrationRecord, error) {
+	driver := newDriver(db)
+	if err := driver.Create(); err != nil {
+		return nil, errors.Wrap(err, "creating migrations table")
+	}
+	records, err := driver.All()
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting applied migrations")
+	}
+
+	applied := make(map[int]darwin.MigrationRecord, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// checked turns the validation errors of darwin into errors that say what to do.
+func checked(err error) error {
+	switch e := err.(type) {
+	case darwin.InvalidChecksumError:
+		return errors.Errorf("migration %d was edited after it was applied, restore its script and add a new migration instead", e.Version)
+	case darwin.RemovedMigrationError:
+		return errors.Errorf("migration %d was applied but is missing", e.Version)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,83 @@
+package setting
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Registration modes say who can sign up.
+const (
+	// RegistrationOpen lets anyone sign up.
+	RegistrationOpen = "open"
+	// RegistrationInvite needs an unused invite code.
+	RegistrationInvite = "invite"
+	// RegistrationDomain needs an email address at one of the allowed domains.
+	RegistrationDomain = "domain"
+	// RegistrationClosed lets nobody sign up.
+	RegistrationClosed = "closed"
+)
+
+// RegistrationModes are all registration modes.
+var RegistrationModes = []string{RegistrationOpen, RegistrationInvite, RegistrationDomain, RegistrationClosed}
+
+// Settings are the instance settings admins can change.
+type Settings struct {
+	// Registration is one of the registration modes.
+	Registration string
+	// AllowedDomains can sign up in RegistrationDomain mode.
+	AllowedDomains []string
+	// InviteQuota is how many invite codes a user can create. Admins have no limit.
+	InviteQuota int
+}
+
+// Defaults are the settings of a new instance.
+var Defaults = Settings{
+	Registration: RegistrationOpen,
+	InviteQuota:  5,
+}
+
+// Closed returns true if nobody can sign up, also for an unknown mode.
+func (s Settings) Closed() bool {
+	switch s.Registration {
+	case RegistrationOpen, RegistrationInvite, RegistrationDomain:
+		return false
+	}
+	return true
+}
+
+// InviteOnly returns true if signing up needs an invite code.
+func (s Settings) InviteOnly() bool {
+	return s.Registration == RegistrationInvite
+}
+
+// DomainOnly returns true if signing up needs an email address at an allowed domain.
+func (s Settings) DomainOnly() bool {
+	return s.Registration == RegistrationDomain
+}
+
+// AllowsDomain returns true if the domain of an email address is allowed.
+func (s Settings) AllowsDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range s.AllowedDomains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDomains splits a list of domains separated by commas or whitespace.
+// Leading @ are dropped, so "@example.com" works as well.
+func ParseDomains(s string) []string {
+	var domains []string
+	for _, d := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if d = strings.TrimPrefix(d, "@"); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
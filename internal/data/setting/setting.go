@@ -0,0 +1,83 @@
+// Package setting keeps the instance settings in the database.
+package setting
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Names of the settings in the database.
+const (
+	registration   = "registration"
+	allowedDomains = "allowed_domains"
+	inviteQuota    = "invite_quota"
+)
+
+// SettingRepository defines the repository for the instance settings.
+type SettingRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a setting repo.
+func New(db *sqlx.DB) SettingRepository {
+	return SettingRepository{Db: db}
+}
+
+// Query returns the settings, the defaults for the ones that were never changed.
+func (sr SettingRepository) Query() (Settings, error) {
+	var rows []struct {
+		Name  string `db:"name"`
+		Value string `db:"value"`
+	}
+	if err := sr.Db.Select(&rows, `SELECT name, value FROM settings`); err != nil {
+		return Settings{}, errors.Wrap(err, "selecting settings")
+	}
+
+	s := Defaults
+	for _, row := range rows {
+		switch row.Name {
+		case registration:
+			s.Registration = row.Value
+		case allowedDomains:
+			s.AllowedDomains = ParseDomains(row.Value)
+		case inviteQuota:
+			s.InviteQuota, _ = strconv.Atoi(row.Value)
+		}
+	}
+	return s, nil
+}
+
+// Update saves all settings.
+func (sr SettingRepository) Update(s Settings, now time.Time) error {
+	tx, err := sr.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO settings
+		(name, value, updated_at)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT(name) DO UPDATE SET
+		value = excluded.value,
+		updated_at = excluded.updated_at
+	`
+	values := map[string]string{
+		registration:   s.Registration,
+		allowedDomains: strings.Join(s.AllowedDomains, ","),
+		inviteQuota:    strconv.Itoa(s.InviteQuota),
+	}
+	for name, value := range values {
+		if _, err := tx.Exec(q, name, value, now.UTC()); err != nil {
+			return errors.Wrapf(err, "updating setting %q", name)
+		}
+	}
+
+	return tx.Commit()
+}
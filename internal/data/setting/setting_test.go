@@ -0,0 +1,76 @@
+package setting
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestSettings(t *testing.T) {
+	db := datatest.NewDB(t)
+
+	sr := New(db)
+	s, err := sr.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, Defaults) {
+		t.Errorf("got %+v before any update, want the defaults %+v", s, Defaults)
+	}
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, want := range []Settings{
+		{Registration: RegistrationDomain, AllowedDomains: []string{"example.com", "example.org"}, InviteQuota: 0},
+		{Registration: RegistrationInvite, InviteQuota: 3},
+	} {
+		if err := sr.Update(want, now); err != nil {
+			t.Fatal(err)
+		}
+		got, err := sr.Query()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestAllowsDomain(t *testing.T) {
+	s := Settings{Registration: RegistrationDomain, AllowedDomains: ParseDomains("Example.com, @example.org\nmail.example.net")}
+	if want := []string{"example.com", "example.org", "mail.example.net"}; !reflect.DeepEqual(s.AllowedDomains, want) {
+		t.Fatalf("got domains %q, want %q", s.AllowedDomains, want)
+	}
+
+	tests := map[string]bool{
+		"jane@example.com":         true,
+		"Jane@EXAMPLE.ORG":         true,
+		"jane@mail.example.net":    true,
+		"jane@example.net":         false,
+		"jane@sub.example.com":     false,
+		"jane@example.com.evil.io": false,
+		"example.com":              false,
+	}
+	for email, want := range tests {
+		if got := s.AllowsDomain(email); got != want {
+			t.Errorf("AllowsDomain(%q) = %t, want %t", email, got, want)
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	for mode, want := range map[string]bool{
+		RegistrationOpen:   false,
+		RegistrationInvite: false,
+		RegistrationDomain: false,
+		RegistrationClosed: true,
+		"":                 true,
+		"unknown":          true,
+	} {
+		if got := (Settings{Registration: mode}).Closed(); got != want {
+			t.Errorf("Closed() with mode %q = %t, want %t", mode, got, want)
+		}
+	}
+}
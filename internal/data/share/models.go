@@ -0,0 +1,34 @@
+package share
+
+import "time"
+
+// Kinds of content that can be shared: a single maybe, the maybes with a tag
+// or the collection of maybes in a workspace.
+const (
+	KindMaybe     = "maybe"
+	KindTag       = "tag"
+	KindWorkspace = "workspace"
+)
+
+// Info is the model for a public read-only link.
+type Info struct {
+	ID          string     `db:"share_id"`
+	UserID      string     `db:"user_id"`
+	Kind        string     `db:"kind"`
+	TargetID    string     `db:"target_id"`
+	Title       string     `db:"title"`
+	Token       string     `db:"token"`
+	Views       int        `db:"views"`
+	ExpiresAt   *time.Time `db:"expires_at"`
+	DateCreated string     `db:"created_at"`
+}
+
+type Infos []Info
+
+// NewShare is the data for creating a new public link.
+// A nil ExpiresAt creates a link that never expires.
+type NewShare struct {
+	Kind      string
+	TargetID  string
+	ExpiresAt *time.Time
+}
@@ -0,0 +1,225 @@
+package share
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific share is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidKind occurs when the shared content is not a maybe, a tag or a workspace.
+	ErrInvalidKind = errors.New("kind of share is not supported")
+
+	// ErrExpired occurs when a share is requested after its expiry date.
+	ErrExpired = errors.New("share has expired")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// ShareRepository defines the repository for the share service.
+type ShareRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a pointer to a share repo.
+func New(db *sqlx.DB) ShareRepository {
+	return ShareRepository{Db: db}
+}
+
+// newToken returns a random, URL-safe token for a public link.
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// canShare returns ErrForbidden unless the user owns the content: a personal
+// maybe of the user, a tag the user uses for at least one personal maybe, or
+// a workspace or a maybe in a workspace the user is an owner of. Tag links
+// show personal maybes only, so tags on maybes in workspaces don't count.
+func (sr ShareRepository) canShare(kind, targetID, userID string) error {
+	var q string
+	switch kind {
+	case KindMaybe:
+		q = `
+		SELECT
+			COUNT(*)
+		FROM maybes AS m
+		LEFT JOIN
+			workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = $2
+		WHERE
+			m.maybe_id = $1 AND (
+				(m.workspace_id IS NULL AND m.user_id = $2) OR wm.role = 'owner'
+			)
+		`
+	case KindTag:
+		q = `
+		SELECT
+			COUNT(*)
+		FROM maybetags AS mt
+		JOIN
+			maybes AS m ON m.maybe_id = mt.maybe_id
+		WHERE
+			mt.tag_id = $1 AND m.user_id = $2 AND m.workspace_id IS NULL
+		`
+	case KindWorkspace:
+		q = `SELECT COUNT(*) FROM workspacemembers WHERE workspace_id = $1 AND user_id = $2 AND role = 'owner'`
+	default:
+		return ErrInvalidKind
+	}
+
+	var count int
+	if err := sr.Db.Get(&count, q, targetID, userID); err != nil {
+		return errors.Wrapf(err, "checking ownership of %s %q", kind, targetID)
+	}
+	if count == 0 {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Create adds a new public link for content the user owns, see canShare.
+func (sr ShareRepository) Create(ns NewShare, userID string) (Info, error) {
+	if _, err := uuid.Parse(ns.TargetID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	if err := sr.canShare(ns.Kind, ns.TargetID, userID); err != nil {
+		return Info{}, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Info{}, errors.Wrap(err, "generating share token")
+	}
+
+	sh := Info{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Kind:        ns.Kind,
+		TargetID:    ns.TargetID,
+		Token:       token,
+		ExpiresAt:   ns.ExpiresAt,
+		DateCreated: time.Now().UTC().String(),
+	}
+
+	const i = `
+	INSERT INTO shares
+		(share_id, user_id, kind, target_id, token, expires_at, created_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := sr.Db.Exec(i, sh.ID, sh.UserID, sh.Kind, sh.TargetID, sh.Token, sh.ExpiresAt, sh.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting share")
+	}
+
+	return sh, nil
+}
+
+// Query retrieves all public links of the current user.
+func (sr ShareRepository) Query(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		s.*,
+		COALESCE(m.title, t.name, w.name, '') AS title
+	FROM shares AS s
+	LEFT JOIN
+		maybes AS m ON s.kind = 'maybe' AND m.maybe_id = s.target_id
+	LEFT JOIN
+		tags AS t ON s.kind = 'tag' AND t.tag_id = s.target_id
+	LEFT JOIN
+		workspaces AS w ON s.kind = 'workspace' AND w.workspace_id = s.target_id
+	WHERE
+		s.user_id = $1
+	ORDER BY
+		s.created_at DESC
+	`
+	var shares Infos
+	if err := sr.Db.Select(&shares, q, userID); err != nil {
+		return shares, errors.Wrap(err, "selecting shares")
+	}
+	return shares, nil
+}
+
+// QueryByToken retrieves a public link by its token and counts the view.
+// Expired links return ErrExpired. Links stop working when the user who
+// created them no longer owns the content, e.g. after losing the owner role
+// of a workspace.
+func (sr ShareRepository) QueryByToken(token string) (Info, error) {
+	const q = `
+	SELECT
+		s.*,
+		'' AS title
+	FROM shares AS s
+	WHERE
+		s.token = $1
+	`
+	var sh Info
+	if err := sr.Db.Get(&sh, q, token); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrap(err, "selecting share by token")
+	}
+
+	if sh.ExpiresAt != nil && sh.ExpiresAt.Before(time.Now().UTC()) {
+		return Info{}, ErrExpired
+	}
+	if err := sr.canShare(sh.Kind, sh.TargetID, sh.UserID); err != nil {
+		if errors.Cause(err) == ErrForbidden {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, err
+	}
+
+	const u = `
+	UPDATE shares
+	SET
+		views = views + 1
+	WHERE
+		share_id = $1
+	`
+	if _, err := sr.Db.Exec(u, sh.ID); err != nil {
+		return Info{}, errors.Wrapf(err, "counting view for share %q", sh.ID)
+	}
+	sh.Views++
+
+	return sh, nil
+}
+
+// Delete revokes a public link of the current user.
+func (sr ShareRepository) Delete(shareID string, userID string) error {
+	if _, err := uuid.Parse(shareID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		shares
+	WHERE
+		share_id = $1 AND user_id = $2
+	`
+	res, err := sr.Db.Exec(q, shareID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting share %q", shareID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package share
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestCreate(t *testing.T) {
+	db := datatest.NewDB(t)
+	sr := New(db)
+	alice, bob := datatest.User(t, db, "alice"), datatest.User(t, db, "bob")
+	m := datatest.Maybe(t, db, "Book", alice, "")
+	tag := datatest.Tag(t, db, "reading", m, alice)
+	ws := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner", bob: "editor"})
+	// bob uses the tag only on a maybe in the workspace, which a tag link doesn't show
+	teamBook := datatest.Maybe(t, db, "Team book", bob, ws)
+	datatest.Exec(t, db, `INSERT INTO maybetags (tag_id, maybe_id, user_id) VALUES ($1, $2, $3)`, tag, teamBook, bob)
+
+	for _, c := range []struct {
+		what   string
+		ns     NewShare
+		userID string
+		want   error
+	}{
+		{"own maybe", NewShare{Kind: KindMaybe, TargetID: m}, alice, nil},
+		{"maybe of another user", NewShare{Kind: KindMaybe, TargetID: m}, bob, ErrForbidden},
+		{"own tag", NewShare{Kind: KindTag, TargetID: tag}, alice, nil},
+		{"tag only used in a workspace", NewShare{Kind: KindTag, TargetID: tag}, bob, ErrForbidden},
+		{"workspace maybe as editor and author", NewShare{Kind: KindMaybe, TargetID: teamBook}, bob, ErrForbidden},
+		{"workspace maybe as owner", NewShare{Kind: KindMaybe, TargetID: teamBook}, alice, nil},
+		{"workspace as owner", NewShare{Kind: KindWorkspace, TargetID: ws}, alice, nil},
+		{"workspace as editor", NewShare{Kind: KindWorkspace, TargetID: ws}, bob, ErrForbidden},
+		{"unknown kind", NewShare{Kind: "user", TargetID: m}, alice, ErrInvalidKind},
+		{"invalid ID", NewShare{Kind: KindMaybe, TargetID: "nope"}, alice, ErrInvalidID},
+	} {
+		sh, err := sr.Create(c.ns, c.userID)
+		datatest.WantErr(t, c.what, err, c.want)
+		if err == nil && (sh.Token == "" || sh.UserID != c.userID) {
+			t.Errorf("%s: got share %+v", c.what, sh)
+		}
+	}
+
+	shares, err := sr.Query(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]bool{}
+	for _, sh := range shares {
+		titles[sh.Kind+": "+sh.Title] = true
+	}
+	for _, want := range []string{"maybe: Book", "maybe: Team book", "tag: reading", "workspace: Team"} {
+		if !titles[want] {
+			t.Errorf("no share %q in %v", want, titles)
+		}
+	}
+	if shares, err := sr.Query(bob); err != nil || len(shares) != 0 {
+		t.Errorf("shares of bob: got %d, %v", len(shares), err)
+	}
+}
+
+func TestQueryByToken(t *testing.T) {
+	db := datatest.NewDB(t)
+	sr := New(db)
+	alice := datatest.User(t, db, "alice")
+	m := datatest.Maybe(t, db, "Book", alice, "")
+
+	sh, err := sr.Create(NewShare{Kind: KindMaybe, TargetID: m}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		got, err := sr.QueryByToken(sh.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != sh.ID || got.Views != i {
+			t.Errorf("view %d: got share %q with %d views", i, got.ID, got.Views)
+		}
+	}
+	shares, err := sr.Query(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 || shares[0].Views != 3 {
+		t.Errorf("shares after 3 views: got %+v", shares)
+	}
+
+	_, err = sr.QueryByToken("unknown")
+	datatest.WantErr(t, "unknown token", err, ErrNotFound)
+
+	past := time.Now().UTC().Add(-time.Minute)
+	expired, err := sr.Create(NewShare{Kind: KindMaybe, TargetID: m, ExpiresAt: &past}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sr.QueryByToken(expired.Token)
+	datatest.WantErr(t, "expired link", err, ErrExpired)
+
+	var views int
+	if err := db.Get(&views, `SELECT views FROM shares WHERE share_id = $1`, expired.ID); err != nil {
+		t.Fatal(err)
+	}
+	if views != 0 {
+		t.Errorf("views of expired link: got %d, want 0", views)
+	}
+
+	future := time.Now().UTC().AddDate(0, 0, 1)
+	valid, err := sr.Create(NewShare{Kind: KindMaybe, TargetID: m, ExpiresAt: &future}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sr.QueryByToken(valid.Token); err != nil {
+		t.Errorf("link expiring tomorrow: %v", err)
+	}
+}
+
+func TestQueryByTokenOwnerLeft(t *testing.T) {
+	db := datatest.NewDB(t)
+	sr := New(db)
+	alice, bob := datatest.User(t, db, "alice"), datatest.User(t, db, "bob")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner", bob: "owner"})
+	m := datatest.Maybe(t, db, "Team book", bob, ws)
+
+	maybeShare, err := sr.Create(NewShare{Kind: KindMaybe, TargetID: m}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsShare, err := sr.Create(NewShare{Kind: KindWorkspace, TargetID: ws}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datatest.Exec(t, db, `UPDATE workspacemembers SET role = 'editor' WHERE workspace_id = $1 AND user_id = $2`, ws, alice)
+
+	_, err = sr.QueryByToken(maybeShare.Token)
+	datatest.WantErr(t, "maybe link after losing the owner role", err, ErrNotFound)
+	_, err = sr.QueryByToken(wsShare.Token)
+	datatest.WantErr(t, "workspace link after losing the owner role", err, ErrNotFound)
+}
+
+func TestDelete(t *testing.T) {
+	db := datatest.NewDB(t)
+	sr := New(db)
+	alice, bob := datatest.User(t, db, "alice"), datatest.User(t, db, "bob")
+	m := datatest.Maybe(t, db, "Book", alice, "")
+
+	sh, err := sr.Create(NewShare{Kind: KindMaybe, TargetID: m}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datatest.WantErr(t, "delete by another user", sr.Delete(sh.ID, bob), ErrNotFound)
+	if _, err := sr.QueryByToken(sh.Token); err != nil {
+		t.Errorf("link after delete by another user: %v", err)
+	}
+
+	datatest.WantErr(t, "delete with invalid ID", sr.Delete("nope", alice), ErrInvalidID)
+
+	if err := sr.Delete(sh.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	_, err = sr.QueryByToken(sh.Token)
+	datatest.WantErr(t, "revoked link", err, ErrNotFound)
+	datatest.WantErr(t, "delete twice", sr.Delete(sh.ID, alice), ErrNotFound)
+}
@@ -0,0 +1,44 @@
+package stats
+
+import "time"
+
+// Info is the usage of the instance.
+type Info struct {
+	Users         Users   `json:"users"`
+	Totals        Totals  `json:"totals"`
+	ActiveUsers   []Week  `json:"active_users"`
+	MaybesCreated []Week  `json:"maybes_created"`
+	TopTags       []Count `json:"top_tags"`
+	TopDomains    []Count `json:"top_domains"`
+	DatabaseSize  int64   `json:"database_size"`
+}
+
+// Users counts the accounts by their state.
+type Users struct {
+	Total           int `db:"total" json:"total"`
+	Active          int `db:"active" json:"active"`
+	Verified        int `db:"verified" json:"verified"`
+	TwoFactor       int `db:"two_factor" json:"two_factor"`
+	Admins          int `db:"admins" json:"admins"`
+	PendingDeletion int `db:"pending_deletion" json:"pending_deletion"`
+}
+
+// Totals counts the content of all users.
+type Totals struct {
+	Maybes     int `db:"maybes" json:"maybes"`
+	Tags       int `db:"tags" json:"tags"`
+	Comments   int `db:"comments" json:"comments"`
+	Workspaces int `db:"workspaces" json:"workspaces"`
+}
+
+// Week is a count for the week starting on Monday Start.
+type Week struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// Count is how often a tag or domain is used.
+type Count struct {
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count"`
+}
@@ -0,0 +1,203 @@
+// Package stats computes how the instance is used from the existing tables.
+package stats
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// StatsRepository defines the repository for the usage statistics.
+type StatsRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a stats repo.
+func New(db *sqlx.DB) StatsRepository {
+	return StatsRepository{Db: db}
+}
+
+// week is the Monday of the week of the timestamp ts as YYYY-MM-DD.
+// Timestamps are written in different formats, but all start with the date.
+const week = `date(substr(ts, 1, 10), '-6 days', 'weekday 1')`
+
+// Query computes the statistics with the weekly counts of the given number of
+// weeks up to the one of now, and top lists of limit entries.
+func (sr StatsRepository) Query(now time.Time, weeks, limit int) (Info, error) {
+	var info Info
+	var err error
+
+	if info.Users, err = sr.queryUsers(); err != nil {
+		return info, err
+	}
+	if info.Totals, err = sr.queryTotals(); err != nil {
+		return info, err
+	}
+
+	monday := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -(int(now.UTC().Weekday())+6)%7)
+	since := monday.AddDate(0, 0, -7*(weeks-1))
+
+	// users count as active in a week if they added, changed or commented on
+	// maybes or used the app while logged in
+	const a = `
+	SELECT
+		` + week + ` AS week,
+		COUNT(DISTINCT user_id) AS count
+	FROM (
+		SELECT user_id, created_at AS ts FROM maybes
+		UNION ALL
+		SELECT user_id, updated_at FROM maybes
+		UNION ALL
+		SELECT user_id, created_at FROM comments
+		UNION ALL
+		SELECT user_id, last_seen_at FROM user_sessions
+	)
+	WHERE
+		substr(ts, 1, 10) >= $1
+	GROUP BY
+		week
+	`
+	if info.ActiveUsers, err = sr.queryWeeks(a, since, weeks); err != nil {
+		return info, errors.Wrap(err, "counting active users")
+	}
+
+	const m = `
+	SELECT
+		` + week + ` AS week,
+		COUNT(*) AS count
+	FROM
+		(SELECT created_at AS ts FROM maybes)
+	WHERE
+		substr(ts, 1, 10) >= $1
+	GROUP BY
+		week
+	`
+	if info.MaybesCreated, err = sr.queryWeeks(m, since, weeks); err != nil {
+		return info, errors.Wrap(err, "counting created maybes")
+	}
+
+	const t = `
+	SELECT
+		t.name AS name,
+		COUNT(*) AS count
+	FROM
+		maybetags AS mt
+	JOIN
+		tags AS t ON t.tag_id = mt.tag_id
+	GROUP BY
+		mt.tag_id
+	ORDER BY
+		count DESC, name
+	LIMIT $1
+	`
+	if err := sr.Db.Select(&info.TopTags, t, limit); err != nil {
+		return info, errors.Wrap(err, "selecting top tags")
+	}
+
+	// the domain is what comes between the scheme and the path of the URL
+	const d = `
+	WITH hosts AS (
+		SELECT
+			lower(substr(url, instr(url, '://') + 3)) AS rest
+		FROM
+			maybes
+		WHERE
+			instr(url, '://') > 0
+	), domains AS (
+		SELECT
+			CASE WHEN instr(rest, '/') > 0 THEN substr(rest, 1, instr(rest, '/') - 1) ELSE rest END AS domain
+		FROM
+			hosts
+	)
+	SELECT
+		CASE WHEN domain LIKE 'www.%' THEN substr(domain, 5) ELSE domain END AS name,
+		COUNT(*) AS count
+	FROM
+		domains
+	GROUP BY
+		name
+	ORDER BY
+		count DESC, name
+	LIMIT $1
+	`
+	if err := sr.Db.Select(&info.TopDomains, d, limit); err != nil {
+		return info, errors.Wrap(err, "selecting top domains")
+	}
+
+	const s = `SELECT page_count * page_size FROM pragma_page_count, pragma_page_size`
+	if err := sr.Db.Get(&info.DatabaseSize, s); err != nil {
+		return info, errors.Wrap(err, "getting database size")
+	}
+
+	return info, nil
+}
+
+// QueryCounts counts the users and their content without the weekly and top
+// lists, which is cheap enough to do on every scrape of the metrics.
+func (sr StatsRepository) QueryCounts() (Users, Totals, error) {
+	u, err := sr.queryUsers()
+	if err != nil {
+		return u, Totals{}, err
+	}
+	t, err := sr.queryTotals()
+	return u, t, err
+}
+
+func (sr StatsRepository) queryUsers() (Users, error) {
+	const q = `
+	SELECT
+		COUNT(*) AS total,
+		COUNT(*) FILTER (WHERE active = TRUE) AS active,
+		COUNT(verified_at) AS verified,
+		COUNT(totp_enabled_at) AS two_factor,
+		COUNT(*) FILTER (WHERE role = 'admin') AS admins,
+		COUNT(delete_at) AS pending_deletion
+	FROM
+		users
+	`
+	var u Users
+	if err := sr.Db.Get(&u, q); err != nil {
+		return u, errors.Wrap(err, "counting users")
+	}
+	return u, nil
+}
+
+func (sr StatsRepository) queryTotals() (Totals, error) {
+	const q = `
+	SELECT
+		(SELECT COUNT(*) FROM maybes) AS maybes,
+		(SELECT COUNT(*) FROM tags) AS tags,
+		(SELECT COUNT(*) FROM comments) AS comments,
+		(SELECT COUNT(*) FROM workspaces) AS workspaces
+	`
+	var t Totals
+	if err := sr.Db.Get(&t, q); err != nil {
+		return t, errors.Wrap(err, "counting maybes, tags, comments and workspaces")
+	}
+	return t, nil
+}
+
+// queryWeeks runs a query for counts by week since a Monday and returns the
+// counts of all weeks, also the ones without any.
+func (sr StatsRepository) queryWeeks(q string, since time.Time, weeks int) ([]Week, error) {
+	var rows []struct {
+		Week  string `db:"week"`
+		Count int    `db:"count"`
+	}
+	if err := sr.Db.Select(&rows, q, since.Format("2006-01-02")); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.Week] = r.Count
+	}
+
+	result := make([]Week, weeks)
+	for i := range result {
+		start := since.AddDate(0, 0, 7*i)
+		result[i] = Week{Start: start, Count: counts[start.Format("2006-01-02")]}
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+)
+
+func TestQuery(t *testing.T) {
+	db := datatest.NewDB(t)
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	const user1, user2 = "bbc79841-7feb-4944-9971-07404558dfdd", "6ae4a9bf-0bff-40d5-9dbc-ce93819f4208"
+	// Wednesday, so the current week started on Monday, March 1st
+	now := time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC)
+	maybes := []struct {
+		id, userID, url string
+		created         time.Time
+	}{
+		{"00000000-0000-0000-0000-000000000001", user1, "https://www.youtube.com/watch?v=1", now},
+		{"00000000-0000-0000-0000-000000000002", user1, "https://WWW.YOUTUBE.COM/watch?v=2", now.AddDate(0, 0, -2)},
+		{"00000000-0000-0000-0000-000000000003", user2, "https://go.dev", now.AddDate(0, 0, -3)},
+		{"00000000-0000-0000-0000-000000000004", user2, "not a link", now.AddDate(0, 0, -14)},
+	}
+	for _, m := range maybes {
+		const q = `
+		INSERT INTO maybes (maybe_id, user_id, title, url, description, created_at, updated_at)
+		VALUES ($1, $2, 'title', $3, '', $4, $4)
+		`
+		if _, err := db.Exec(q, m.id, m.userID, m.url, m.created.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE users SET role = 'admin', delete_at = $2 WHERE user_id = $1`, user1, now); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := New(db).Query(now, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Users{Total: 2, Active: 2, Verified: 2, Admins: 1, PendingDeletion: 1}); info.Users != want {
+		t.Errorf("want users %+v; got %+v", want, info.Users)
+	}
+	if want := (Totals{Maybes: 6, Tags: 3}); info.Totals != want {
+		t.Errorf("want totals %+v; got %+v", want, info.Totals)
+	}
+
+	wantWeeks := []struct {
+		start           string
+		created, active int
+	}{
+		{"2021-02-15", 1, 1},
+		{"2021-02-22", 1, 1},
+		{"2021-03-01", 2, 1},
+	}
+	if len(info.MaybesCreated) != 3 || len(info.ActiveUsers) != 3 {
+		t.Fatalf("want 3 weeks; got %d and %d", len(info.MaybesCreated), len(info.ActiveUsers))
+	}
+	for i, w := range wantWeeks {
+		if got := info.MaybesCreated[i]; got.Start.Format("2006-01-02") != w.start || got.Count != w.created {
+			t.Errorf("want %d maybes created in week of %s; got %+v", w.created, w.start, got)
+		}
+		if got := info.ActiveUsers[i]; got.Start.Format("2006-01-02") != w.start || got.Count != w.active {
+			t.Errorf("want %d active users in week of %s; got %+v", w.active, w.start, got)
+		}
+	}
+
+	if len(info.TopDomains) != 2 || info.TopDomains[0] != (Count{Name: "youtube.com", Count: 3}) || info.TopDomains[1] != (Count{Name: "go.dev", Count: 1}) {
+		t.Errorf("want youtube.com and go.dev as top domains; got %+v", info.TopDomains)
+	}
+	if len(info.TopTags) != 2 || info.TopTags[0].Count != 1 {
+		t.Errorf("want 2 top tags; got %+v", info.TopTags)
+	}
+	if info.DatabaseSize == 0 {
+		t.Error("want database size")
+	}
+}
@@ -1,21 +1,61 @@
 package data
 
 import (
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/setting"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/share"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/stats"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/vote"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
 )
 
 // TemplateData is all data needed for Golang HTML templates.
 type TemplateData struct {
-	Maybe           *maybe.Info
-	Maybes          maybe.Infos
-	Tag             *maybe.Tag
-	Tags            maybe.Tags
-	User            *user.Info
-	Form            *forms.Form
-	Flash           string
-	CurrentYear     int
-	IsAuthenticated bool
-	CSRFToken       string
+	Maybe            *maybe.Info
+	Maybes           maybe.Infos
+	Tag              *maybe.Tag
+	Tags             maybe.Tags
+	Sort             string
+	Tally            *vote.Tally
+	Round            *vote.Round
+	Rounds           vote.Rounds
+	Ballots          vote.Ballots
+	Results          vote.Results
+	Shares           share.Infos
+	Comments         comment.Infos
+	Notifications    notification.Infos
+	User             *user.Info
+	TwoFactor        *user.TwoFactor
+	Sessions         user.Sessions
+	Exports          user.Exports
+	Stats            *stats.Info
+	Settings         *setting.Settings
+	Users            user.Infos
+	LoginAttempts    []user.LoginAttempt
+	Query            string
+	PrevPage         int
+	NextPage         int
+	Email            *mail.Message
+	Workspace        *workspace.Info
+	Workspaces       workspace.Infos
+	CurrentWorkspace *workspace.Info
+	Members          workspace.Members
+	Invites          workspace.Invites
+	SignupInvites    user.Invites
+	InvitesLeft      int
+	BaseURL          string
+	Form             *forms.Form
+	SSOName          string
+	Confirmed        bool
+	RedirectPath     string
+	Flash            string
+	CurrentYear      int
+	IsAuthenticated  bool
+	IsAdmin          bool
+	CSRFToken        string
 }
@@ -0,0 +1,170 @@
+package user
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidRole occurs when a user is given a role that does not exist.
+var ErrInvalidRole = errors.New("role is not valid")
+
+// QueryAll retrieves all users including inactive ones, the oldest first.
+func (ur UserRepository) QueryAll() (Infos, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	ORDER BY
+		created_at
+	`
+	var users Infos
+	if err := ur.Db.Select(&users, q); err != nil {
+		return users, errors.Wrap(err, "selecting users")
+	}
+	return users, nil
+}
+
+// QueryRecent retrieves the users who signed up last, the newest first.
+func (ur UserRepository) QueryRecent(limit int) (Infos, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	ORDER BY
+		created_at DESC
+	LIMIT $1
+	`
+	var users Infos
+	if err := ur.Db.Select(&users, q, limit); err != nil {
+		return users, errors.Wrap(err, "selecting recent users")
+	}
+	return users, nil
+}
+
+// Search retrieves a page of the users whose name or email address contains
+// the query, all users if it is empty, ordered by email address.
+func (ur UserRepository) Search(query string, limit, offset int) (Infos, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
+		$1 = '' OR instr(lower(name), lower($1)) > 0 OR instr(lower(email), lower($1)) > 0
+	ORDER BY
+		email
+	LIMIT $2 OFFSET $3
+	`
+	var users Infos
+	if err := ur.Db.Select(&users, q, query, limit, offset); err != nil {
+		return users, errors.Wrapf(err, "searching users for %q", query)
+	}
+	return users, nil
+}
+
+// QueryAccount retrieves the user with an email address, unlike QueryByEmail
+// also if the user is not active.
+func (ur UserRepository) QueryAccount(email string) (Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
+		email = $1`
+
+	var usr Info
+	if err := ur.Db.Get(&usr, q, email); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting user by email %q", email)
+	}
+	return usr, nil
+}
+
+// SetActive activates or deactivates a user. Inactive users can't log in and
+// are logged out of all sessions.
+func (ur UserRepository) SetActive(userID string, active bool) error {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if err := updateUser(tx, `active = $2`, userID, active); err != nil {
+		return err
+	}
+	if !active {
+		if err := logOut(tx, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetPassword replaces the password of a user without knowing the current one,
+// logs out all sessions and lifts a lockout.
+func (ur UserRepository) SetPassword(userID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if err := updateUser(tx, `password_hash = $2, password_set = TRUE, failed_logins = 0, locked_until = NULL`, userID, hash); err != nil {
+		return err
+	}
+	if err := logOut(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetRole gives a user one of the roles RoleUser or RoleAdmin.
+func (ur UserRepository) SetRole(userID, role string) error {
+	if role != RoleUser && role != RoleAdmin {
+		return ErrInvalidRole
+	}
+
+	return updateUser(ur.Db, `role = $2`, userID, role)
+}
+
+// updateUser sets columns of a user, the user ID is $1 and the value $2.
+func updateUser(tx execer, set, userID string, value interface{}) error {
+	res, err := tx.Exec(`UPDATE users SET `+set+` WHERE user_id = $1`, userID, value)
+	if err != nil {
+		return errors.Wrapf(err, "updating user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// logOut ends all sessions of a user.
+func logOut(tx execer, userID string) error {
+	if _, err := tx.Exec(`UPDATE users SET session_version = session_version + 1 WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrapf(err, "updating session version of user %q", userID)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_sessions WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrapf(err, "deleting sessions of user %q", userID)
+	}
+	return nil
+}
+
+// execer runs statements in a transaction or on the database.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
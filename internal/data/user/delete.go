@@ -0,0 +1,146 @@
+package user
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeletionGrace is how long users can change their mind after they asked to
+// delete their account.
+const DeletionGrace = 14 * 24 * time.Hour
+
+// ScheduleDeletion marks an account for deletion and logs out all its
+// sessions. It returns when the account will be deleted. Callers confirm that
+// the user asked for it, e.g. with CheckPassword.
+func (ur UserRepository) ScheduleDeletion(userID string, now time.Time) (time.Time, error) {
+	deleteAt := now.Add(DeletionGrace).UTC()
+
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		users
+	SET
+		delete_at = $2,
+		session_version = session_version + 1
+	WHERE
+		user_id = $1
+	`
+	res, err := tx.Exec(q, userID, deleteAt)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "scheduling deletion of user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return time.Time{}, ErrNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM user_sessions WHERE user_id = $1`, userID); err != nil {
+		return time.Time{}, errors.Wrapf(err, "deleting sessions of user %q", userID)
+	}
+
+	return deleteAt, tx.Commit()
+}
+
+// CancelDeletion keeps an account that was marked for deletion.
+func (ur UserRepository) CancelDeletion(userID string) error {
+	if _, err := ur.Db.Exec(`UPDATE users SET delete_at = NULL WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrapf(err, "cancelling deletion of user %q", userID)
+	}
+	return nil
+}
+
+// DeleteDue deletes the accounts whose grace period ended and returns how many
+// there were.
+func (ur UserRepository) DeleteDue(now time.Time) (int, error) {
+	return ur.deleteWhere(`delete_at <= $1`, now.UTC())
+}
+
+// Delete deletes an account right away.
+func (ur UserRepository) Delete(userID string) error {
+	n, err := ur.deleteWhere(`user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// deleteWhere deletes the accounts matching a condition and returns how many
+// there were. Maybes, tag links, tokens, sessions and everything else of the
+// users go with the accounts, as do tags and workspaces nobody uses anymore.
+// Workspaces that lose their last owner get the longest-standing member as
+// their new owner.
+func (ur UserRepository) deleteWhere(cond string, args ...interface{}) (int, error) {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM users WHERE `+cond, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting users")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "counting deleted users")
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	const t = `
+	DELETE FROM
+		tags AS t
+	WHERE NOT EXISTS (
+		SELECT NULL FROM maybetags AS mt
+		WHERE
+			t.tag_id = mt.tag_id
+	)
+	`
+	if _, err := tx.Exec(t); err != nil {
+		return 0, errors.Wrap(err, "deleting orphaned tags")
+	}
+
+	const o = `
+	UPDATE
+		workspacemembers AS wm
+	SET
+		role = 'owner'
+	WHERE NOT EXISTS (
+		SELECT NULL FROM workspacemembers AS o
+		WHERE
+			o.workspace_id = wm.workspace_id AND o.role = 'owner'
+	) AND wm.user_id = (
+		SELECT m.user_id FROM workspacemembers AS m
+		WHERE
+			m.workspace_id = wm.workspace_id
+		ORDER BY m.created_at, m.user_id
+		LIMIT 1
+	)
+	`
+	if _, err := tx.Exec(o); err != nil {
+		return 0, errors.Wrap(err, "promoting new workspace owners")
+	}
+
+	const w = `
+	DELETE FROM
+		workspaces AS w
+	WHERE NOT EXISTS (
+		SELECT NULL FROM workspacemembers AS wm
+		WHERE
+			w.workspace_id = wm.workspace_id
+	)
+	`
+	if _, err := tx.Exec(w); err != nil {
+		return 0, errors.Wrap(err, "deleting orphaned workspaces")
+	}
+
+	return int(n), tx.Commit()
+}
@@ -0,0 +1,123 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestDeleteDue(t *testing.T) {
+	db := datatest.NewDB(t)
+	ur := New(db)
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	gone, err := ur.Create(NewUser{Name: "gone", Email: "gone@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stay, err := ur.Create(NewUser{Name: "stay", Email: "stay@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	late := datatest.User(t, db, "late")
+
+	// a workspace of the user alone and one shared with the user who stays and
+	// a user who joined later
+	solo, shared := uuid.New().String(), uuid.New().String()
+	datatest.Exec(t, db, `INSERT INTO workspaces (workspace_id, name, created_at, updated_at) VALUES ($1, 'Solo', $3, $3), ($2, 'Shared', $3, $3)`, solo, shared, now)
+	datatest.Exec(t, db, `INSERT INTO workspacemembers (workspace_id, user_id, role, created_at) VALUES ($1, $3, 'owner', $5), ($2, $3, 'owner', $5), ($2, $4, 'editor', $5)`,
+		solo, shared, gone.ID, stay.ID, now)
+	datatest.Exec(t, db, `INSERT INTO workspacemembers (workspace_id, user_id, role, created_at) VALUES ($1, $2, 'editor', $3)`, shared, late, now.Add(time.Hour))
+
+	// maybes of both users, one tag is only used by the user who goes
+	mine, theirs := uuid.New().String(), uuid.New().String()
+	datatest.Exec(t, db, `INSERT INTO maybes (maybe_id, user_id, title, url, description, created_at, updated_at, workspace_id) VALUES ($1, $2, 'Mine', '', '', $5, $5, $6), ($3, $4, 'Theirs', '', '', $5, $5, $6)`,
+		mine, gone.ID, theirs, stay.ID, now, shared)
+	orphan, common := uuid.New().String(), uuid.New().String()
+	datatest.Exec(t, db, `INSERT INTO tags (tag_id, name) VALUES ($1, 'orphan'), ($2, 'common')`, orphan, common)
+	datatest.Exec(t, db, `INSERT INTO maybetags (tag_id, maybe_id, user_id) VALUES ($1, $3, $4), ($2, $3, $4), ($2, $5, $6)`,
+		orphan, common, mine, gone.ID, theirs, stay.ID)
+
+	// comments and votes of the user on the maybe of the user who stays
+	datatest.Exec(t, db, `INSERT INTO comments (comment_id, maybe_id, user_id, body, created_at, updated_at) VALUES ($1, $2, $3, 'bye', $5, $5), ($4, $2, $6, 'hi', $5, $5)`,
+		uuid.New().String(), theirs, gone.ID, uuid.New().String(), now, stay.ID)
+	datatest.Exec(t, db, `INSERT INTO votes (maybe_id, user_id, value, created_at) VALUES ($1, $2, 1, $3)`, theirs, gone.ID, now)
+
+	if _, err := ur.CreateSession(gone.ID, "10.0.0.1", "test", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ur.RecordFailedLogin(gone.Email, "10.0.0.1", LoginWrongPassword, now); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAt, err := ur.ScheduleDeletion(gone.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a session from a login during the grace period
+	if _, err := ur.CreateSession(gone.ID, "10.0.0.1", "test", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ur.DeleteDue(deleteAt.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("deleting before the grace period ended: got %d, %v", n, err)
+	}
+	if n, err := ur.DeleteDue(deleteAt); err != nil || n != 1 {
+		t.Fatalf("deleting after the grace period: got %d, %v", n, err)
+	}
+
+	for _, c := range []struct {
+		what  string
+		query string
+		args  []interface{}
+		want  int
+	}{
+		{"user", `SELECT COUNT(*) FROM users WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"maybes", `SELECT COUNT(*) FROM maybes WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"maybetags", `SELECT COUNT(*) FROM maybetags WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"comments", `SELECT COUNT(*) FROM comments WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"votes", `SELECT COUNT(*) FROM votes WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"sessions", `SELECT COUNT(*) FROM user_sessions WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"memberships", `SELECT COUNT(*) FROM workspacemembers WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"orphaned tag", `SELECT COUNT(*) FROM tags WHERE tag_id = $1`, []interface{}{orphan}, 0},
+		{"orphaned workspace", `SELECT COUNT(*) FROM workspaces WHERE workspace_id = $1`, []interface{}{solo}, 0},
+		{"login attempts of the user", `SELECT COUNT(*) FROM login_attempts WHERE user_id = $1`, []interface{}{gone.ID}, 0},
+		{"anonymized login attempts", `SELECT COUNT(*) FROM login_attempts WHERE email = $1 AND user_id IS NULL`, []interface{}{gone.Email}, 1},
+		{"other user", `SELECT COUNT(*) FROM users WHERE user_id = $1`, []interface{}{stay.ID}, 1},
+		{"other maybe", `SELECT COUNT(*) FROM maybes WHERE maybe_id = $1`, []interface{}{theirs}, 1},
+		{"other comment", `SELECT COUNT(*) FROM comments WHERE user_id = $1`, []interface{}{stay.ID}, 1},
+		{"common tag", `SELECT COUNT(*) FROM tags WHERE tag_id = $1`, []interface{}{common}, 1},
+		{"shared workspace", `SELECT COUNT(*) FROM workspaces WHERE workspace_id = $1`, []interface{}{shared}, 1},
+		{"new owner", `SELECT COUNT(*) FROM workspacemembers WHERE workspace_id = $1 AND user_id = $2 AND role = 'owner'`, []interface{}{shared, stay.ID}, 1},
+		{"later member", `SELECT COUNT(*) FROM workspacemembers WHERE workspace_id = $1 AND user_id = $2 AND role = 'editor'`, []interface{}{shared, late}, 1},
+	} {
+		if got := datatest.Count(t, db, c.query, c.args...); got != c.want {
+			t.Errorf("%s: got %d rows, want %d", c.what, got, c.want)
+		}
+	}
+}
+
+func TestCancelDeletion(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	usr, err := ur.Create(NewUser{Name: "undecided", Email: "undecided@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAt, err := ur.ScheduleDeletion(usr.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ur.CancelDeletion(usr.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ur.DeleteDue(deleteAt); err != nil || n != 0 {
+		t.Errorf("deleting a kept account: got %d, %v", n, err)
+	}
+	if _, err := ur.QueryByID(usr.ID); err != nil {
+		t.Errorf("querying a kept account: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrExportPending occurs when a user asks for a data export while another one is being built.
+var ErrExportPending = errors.New("an export is already being built")
+
+// ExportTTL is how long a finished data export can be downloaded.
+const ExportTTL = 7 * 24 * time.Hour
+
+// RequestExport asks for an archive of the personal data of a user, which is
+// built in the background.
+func (ur UserRepository) RequestExport(userID string, now time.Time) (Export, error) {
+	var pending int
+	const c = `SELECT COUNT(*) FROM exports WHERE user_id = $1 AND status = $2`
+	if err := ur.Db.Get(&pending, c, userID, ExportPending); err != nil {
+		return Export{}, errors.Wrapf(err, "counting pending exports of user %q", userID)
+	}
+	if pending > 0 {
+		return Export{}, ErrExportPending
+	}
+
+	ex := Export{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    ExportPending,
+		CreatedAt: now.UTC(),
+	}
+	const q = `
+	INSERT INTO exports
+		(export_id, user_id, status, created_at)
+	VALUES
+		($1, $2, $3, $4)`
+	if _, err := ur.Db.Exec(q, ex.ID, ex.UserID, ex.Status, ex.CreatedAt); err != nil {
+		return Export{}, errors.Wrapf(err, "inserting export for user %q", userID)
+	}
+	return ex, nil
+}
+
+// QueryExports retrieves the exports of a user, the latest first.
+func (ur UserRepository) QueryExports(userID string) (Exports, error) {
+	const q = `
+	SELECT
+		export_id, user_id, status, created_at, finished_at
+	FROM exports
+	WHERE
+		user_id = $1
+	ORDER BY
+		created_at DESC
+	`
+	var exports Exports
+	if err := ur.Db.Select(&exports, q, userID); err != nil {
+		return exports, errors.Wrapf(err, "selecting exports of user %q", userID)
+	}
+	return exports, nil
+}
+
+// QueryExportArchive retrieves the archive of a finished export of a user.
+func (ur UserRepository) QueryExportArchive(exportID, userID string) ([]byte, error) {
+	if _, err := uuid.Parse(exportID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		archive
+	FROM exports
+	WHERE
+		export_id = $1 AND user_id = $2 AND status = $3
+	`
+	var archive []byte
+	if err := ur.Db.Get(&archive, q, exportID, userID, ExportReady); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "selecting archive of export %q", exportID)
+	}
+	return archive, nil
+}
+
+// QueryPendingExports retrieves the exports that still have to be built, the oldest first.
+func (ur UserRepository) QueryPendingExports() (Exports, error) {
+	const q = `
+	SELECT
+		export_id, user_id, status, created_at, finished_at
+	FROM exports
+	WHERE
+		status = $1
+	ORDER BY
+		created_at
+	`
+	var exports Exports
+	if err := ur.Db.Select(&exports, q, ExportPending); err != nil {
+		return exports, errors.Wrap(err, "selecting pending exports")
+	}
+	return exports, nil
+}
+
+// FinishExport stores the archive of an export. Exports without an archive
+// are marked as failed.
+func (ur UserRepository) FinishExport(exportID string, archive []byte, now time.Time) error {
+	status := ExportReady
+	if archive == nil {
+		status = ExportFailed
+	}
+
+	const q = `
+	UPDATE
+		exports
+	SET
+		status = $2,
+		archive = $3,
+		finished_at = $4
+	WHERE
+		export_id = $1
+	`
+	if _, err := ur.Db.Exec(q, exportID, status, archive, now.UTC()); err != nil {
+		return errors.Wrapf(err, "finishing export %q", exportID)
+	}
+	return nil
+}
+
+// DeleteExpiredExports removes exports that finished before a time.
+func (ur UserRepository) DeleteExpiredExports(before time.Time) error {
+	if _, err := ur.Db.Exec(`DELETE FROM exports WHERE finished_at < $1`, before.UTC()); err != nil {
+		return errors.Wrap(err, "deleting expired exports")
+	}
+	return nil
+}
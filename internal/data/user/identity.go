@@ -0,0 +1,94 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrEmailNotVerified occurs when an identity provider has not verified the email address of a new identity.
+var ErrEmailNotVerified = errors.New("email address is not verified by the identity provider")
+
+// QueryByIdentity returns the user an identity at an OpenID Connect provider
+// is linked to.
+func (ur UserRepository) QueryByIdentity(issuer, subject string) (Info, error) {
+	const q = `
+	SELECT
+		u.*
+	FROM
+		users AS u
+	JOIN
+		user_identities AS i ON i.user_id = u.user_id
+	WHERE
+		i.issuer = $1 AND i.subject = $2
+	`
+	var usr Info
+	if err := ur.Db.Get(&usr, q, issuer, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting identity %q at %q", subject, issuer)
+	}
+	return usr, nil
+}
+
+// LinkIdentity returns the user of an identity at an OpenID Connect provider.
+// An unknown identity is linked to the user with the same email address, or
+// a new user without a usable password is created for it if signup allows the
+// email address. Both need an email address the provider has verified.
+func (ur UserRepository) LinkIdentity(id Identity, now time.Time, signup func(email string) error) (Info, error) {
+	usr, err := ur.QueryByIdentity(id.Issuer, id.Subject)
+	if errors.Cause(err) != ErrNotFound {
+		return usr, err
+	}
+
+	if !id.EmailVerified || id.Email == "" {
+		return Info{}, ErrEmailNotVerified
+	}
+
+	usr, err = ur.QueryByEmail(id.Email)
+	switch errors.Cause(err) {
+	case nil:
+	case ErrNotFound:
+		if err := signup(id.Email); err != nil {
+			return Info{}, err
+		}
+		name := id.Name
+		if name == "" {
+			name = id.Email
+		}
+		// the random password can only be replaced with a password reset
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Info{}, errors.Wrap(err, "generating password")
+		}
+		usr, err = ur.Create(NewUser{Name: name, Email: id.Email, Password: base64.RawURLEncoding.EncodeToString(b)})
+		if err != nil {
+			return Info{}, err
+		}
+		if err := updateUser(ur.Db, `password_set = $2`, usr.ID, false); err != nil {
+			return Info{}, err
+		}
+	default:
+		return Info{}, err
+	}
+
+	// the provider verified the address, so the user does not have to
+	if err := ur.Verify(usr.ID); err != nil {
+		return Info{}, err
+	}
+
+	const i = `
+	INSERT INTO user_identities
+		(issuer, subject, user_id, email, created_at)
+	VALUES
+		($1, $2, $3, $4, $5)`
+	if _, err := ur.Db.Exec(i, id.Issuer, id.Subject, usr.ID, id.Email, now.UTC()); err != nil {
+		return Info{}, errors.Wrapf(err, "inserting identity for user %q", usr.ID)
+	}
+
+	return ur.QueryByID(usr.ID)
+}
@@ -0,0 +1,50 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestLinkIdentity(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	signup := func(string) error { return nil }
+
+	existing, err := ur.Create(NewUser{Name: "existing", Email: "existing@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := ur.LinkIdentity(Identity{Issuer: "idp", Subject: "1", Email: existing.Email, EmailVerified: true}, now, signup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.ID != existing.ID || !linked.PasswordSet {
+		t.Errorf("linking an existing user: got %q with password set %t", linked.ID, linked.PasswordSet)
+	}
+
+	created, err := ur.LinkIdentity(Identity{Issuer: "idp", Subject: "2", Email: "new@example.com", EmailVerified: true}, now, signup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.PasswordSet {
+		t.Error("new user of an identity has a password set")
+	}
+
+	got, err := ur.QueryByIdentity("idp", "2")
+	if err != nil || got.ID != created.ID {
+		t.Errorf("querying by identity: got %q, %v, want %q", got.ID, err, created.ID)
+	}
+	if _, err := ur.QueryByIdentity("other", "2"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("querying an unknown identity: got %v, want %v", err, ErrNotFound)
+	}
+
+	if err := ur.SetPassword(created.ID, "chosen password"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ur.QueryByID(created.ID); err != nil || !got.PasswordSet {
+		t.Errorf("after setting a password: got password set %t, %v", got.PasswordSet, err)
+	}
+}
@@ -0,0 +1,150 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidInvite occurs when an invite code does not exist, was already
+	// used or belongs to a deactivated user.
+	ErrInvalidInvite = errors.New("invite code is not valid")
+
+	// ErrInviteQuota occurs when a user has created all the invites they may.
+	ErrInviteQuota = errors.New("no invites left")
+)
+
+// inviteGroup is the length of the groups of characters in an invite code.
+const inviteGroup = 4
+
+// CreateInvite creates an invite code for a user. The quota is how many
+// invites the user can have, used ones included; a negative quota has no limit.
+func (ur UserRepository) CreateInvite(userID string, quota int, now time.Time) (Invite, error) {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return Invite{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if quota >= 0 {
+		var count int
+		if err := tx.Get(&count, `SELECT COUNT(*) FROM invites WHERE created_by = $1`, userID); err != nil {
+			return Invite{}, errors.Wrapf(err, "counting invites of user %q", userID)
+		}
+		if count >= quota {
+			return Invite{}, ErrInviteQuota
+		}
+	}
+
+	// 10 random bytes are 16 characters in base32, easy to read out and type
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return Invite{}, errors.Wrap(err, "generating invite code")
+	}
+	inv := Invite{
+		Code:      NormalizeInvite(base32.StdEncoding.EncodeToString(b)),
+		CreatedBy: userID,
+		CreatedAt: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO invites
+		(code, created_by, created_at)
+	VALUES
+		($1, $2, $3)`
+	if _, err := tx.Exec(q, inv.Code, inv.CreatedBy, inv.CreatedAt); err != nil {
+		return Invite{}, errors.Wrapf(err, "inserting invite of user %q", userID)
+	}
+
+	return inv, tx.Commit()
+}
+
+// QueryInvites returns the invites a user created, the newest first.
+func (ur UserRepository) QueryInvites(userID string) (Invites, error) {
+	const q = `
+	SELECT
+		i.code, i.created_by, i.created_at, i.used_by, i.used_at, u.email AS used_by_email
+	FROM
+		invites AS i
+	LEFT JOIN
+		users AS u ON u.user_id = i.used_by
+	WHERE
+		i.created_by = $1
+	ORDER BY
+		i.created_at DESC, i.code`
+
+	var invites Invites
+	if err := ur.Db.Select(&invites, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting invites of user %q", userID)
+	}
+	return invites, nil
+}
+
+// DeleteInvite deletes an unused invite of a user, which gives it back to
+// their quota.
+func (ur UserRepository) DeleteInvite(code, userID string) error {
+	res, err := ur.Db.Exec(`DELETE FROM invites WHERE code = $1 AND created_by = $2 AND used_at IS NULL`, NormalizeInvite(code), userID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting invite of user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateInvited inserts a new user who signed up with an invite code. The
+// code can't be used again.
+func (ur UserRepository) CreateInvited(user NewUser, code string, now time.Time) (Info, error) {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return Info{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	usr, err := insertUser(tx, user)
+	if err != nil {
+		return Info{}, err
+	}
+
+	const q = `
+	UPDATE
+		invites
+	SET
+		used_by = $2,
+		used_at = $3
+	WHERE
+		code = $1 AND used_at IS NULL AND
+		created_by IN (SELECT user_id FROM users WHERE active = TRUE)`
+	res, err := tx.Exec(q, NormalizeInvite(code), usr.ID, now.UTC())
+	if err != nil {
+		return Info{}, errors.Wrap(err, "using invite")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Info{}, ErrInvalidInvite
+	}
+
+	return usr, tx.Commit()
+}
+
+// NormalizeInvite formats an invite code the way it is stored, so that it can
+// be typed in lowercase and with or without spaces and dashes.
+func NormalizeInvite(code string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range strings.ToUpper(code) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		if n > 0 && n%inviteGroup == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestNormalizeInvite(t *testing.T) {
+	tests := map[string]string{
+		"ABCDEFGHIJKLMNOP":    "ABCD-EFGH-IJKL-MNOP",
+		"abcd-efgh-ijkl-mnop": "ABCD-EFGH-IJKL-MNOP",
+		" abcd efgh ijklmnop": "ABCD-EFGH-IJKL-MNOP",
+		"ABC":                 "ABC",
+		"":                    "",
+	}
+	for code, want := range tests {
+		if got := NormalizeInvite(code); got != want {
+			t.Errorf("NormalizeInvite(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestInvites(t *testing.T) {
+	db := datatest.NewDB(t)
+
+	ur := New(db)
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	inviter, err := ur.Create(NewUser{Name: "inviter", Email: "inviter@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ur.CreateInvite(inviter.ID, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ur.CreateInvite(inviter.ID, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ur.CreateInvite(inviter.ID, 2, now); errors.Cause(err) != ErrInviteQuota {
+		t.Fatalf("third invite with a quota of 2: got %v, want %v", err, ErrInviteQuota)
+	}
+	if _, err := ur.CreateInvite(inviter.ID, -1, now); err != nil {
+		t.Fatalf("invite without a limit: %v", err)
+	}
+
+	invited, err := ur.CreateInvited(NewUser{Name: "invited", Email: "invited@example.com", Password: "password"}, first.Code, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ur.CreateInvited(NewUser{Name: "again", Email: "again@example.com", Password: "password"}, first.Code, now); errors.Cause(err) != ErrInvalidInvite {
+		t.Errorf("used invite: got %v, want %v", err, ErrInvalidInvite)
+	}
+	if _, err := ur.QueryByEmail("again@example.com"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("user with a used invite was created: %v", err)
+	}
+	if err := ur.DeleteInvite(first.Code, inviter.ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("deleting a used invite: got %v, want %v", err, ErrNotFound)
+	}
+
+	invites, err := ur.QueryInvites(inviter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var used int
+	for _, inv := range invites {
+		if inv.UsedAt != nil {
+			used++
+			if inv.Code != first.Code || inv.UsedByEmail == nil || *inv.UsedByEmail != invited.Email {
+				t.Errorf("got used invite %+v, want %s used by %s", inv, first.Code, invited.Email)
+			}
+		}
+	}
+	if len(invites) != 3 || used != 1 {
+		t.Errorf("got %d invites, %d used; want 3, 1 used", len(invites), used)
+	}
+
+	// invites of deactivated users can't be used
+	if err := ur.SetActive(inviter.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ur.CreateInvited(NewUser{Name: "late", Email: "late@example.com", Password: "password"}, second.Code, now); errors.Cause(err) != ErrInvalidInvite {
+		t.Errorf("invite of a deactivated user: got %v, want %v", err, ErrInvalidInvite)
+	}
+}
@@ -0,0 +1,218 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// freeLogins is how many failed logins are allowed before backing off.
+	freeLogins = 3
+
+	// maxBackoff caps how long a client has to wait between failed logins.
+	maxBackoff = 15 * time.Minute
+
+	// LockoutAfter is after how many failed logins in a row an account is locked.
+	LockoutAfter = 10
+
+	// LockoutDuration is how long a locked account stays locked unless an admin unlocks it.
+	LockoutDuration = time.Hour
+
+	// ipWindow is how far back failed logins from an IP address count.
+	ipWindow = time.Hour
+)
+
+// Backoff returns how long to wait after a number of failed logins. The first
+// few are free, then the wait doubles with every failure up to a maximum.
+func Backoff(failures int) time.Duration {
+	if failures < freeLogins {
+		return 0
+	}
+	d := time.Second
+	for i := freeLogins; i < failures; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// CheckLogin returns until when logins for the email address or from the IP
+// address have to wait. A zero time means the login can go ahead.
+func (ur UserRepository) CheckLogin(email, ip string, now time.Time) (time.Time, error) {
+	var wait time.Time
+
+	var lockedUntil *time.Time
+	const a = `SELECT locked_until FROM users WHERE email = $1`
+	if err := ur.Db.Get(&lockedUntil, a, email); err != nil && err != sql.ErrNoRows {
+		return time.Time{}, errors.Wrapf(err, "selecting lockout of %q", email)
+	}
+	if lockedUntil != nil && lockedUntil.After(now) {
+		wait = *lockedUntil
+	}
+
+	var failures []time.Time
+	const q = `
+	SELECT
+		created_at
+	FROM
+		login_attempts
+	WHERE
+		ip = $1 AND reason IN ($2, $3) AND created_at > $4
+	ORDER BY
+		created_at DESC
+	LIMIT 100
+	`
+	if err := ur.Db.Select(&failures, q, ip, LoginWrongPassword, LoginWrongCode, now.Add(-ipWindow).UTC()); err != nil {
+		return time.Time{}, errors.Wrapf(err, "selecting failed logins from %q", ip)
+	}
+	if len(failures) > 0 {
+		if until := failures[0].Add(Backoff(len(failures))); until.After(now) && until.After(wait) {
+			wait = until
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordFailedLogin adds an audit record of a failed login. Wrong passwords
+// and wrong two-factor codes for an existing user count towards the backoff
+// and lockout of the account.
+// It returns the user, if there is one, and whether this failure locked the account.
+func (ur UserRepository) RecordFailedLogin(email, ip, reason string, now time.Time) (Info, bool, error) {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return Info{}, false, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	var usr Info
+	var userID *string
+	const u = `SELECT * FROM users WHERE email = $1`
+	switch err := tx.Get(&usr, u, email); err {
+	case nil:
+		userID = &usr.ID
+	case sql.ErrNoRows:
+	default:
+		return Info{}, false, errors.Wrapf(err, "selecting user by email %q", email)
+	}
+
+	const q = `
+	INSERT INTO login_attempts
+		(email, user_id, ip, reason, created_at)
+	VALUES
+		($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(q, email, userID, ip, reason, now.UTC()); err != nil {
+		return Info{}, false, errors.Wrap(err, "inserting login attempt")
+	}
+
+	locked := false
+	if userID != nil && (reason == LoginWrongPassword || reason == LoginWrongCode) {
+		usr.FailedLogins++
+		until := now.Add(Backoff(usr.FailedLogins))
+		if usr.FailedLogins >= LockoutAfter {
+			until = now.Add(LockoutDuration)
+			locked = usr.FailedLogins == LockoutAfter
+		}
+		usr.LockedUntil = &until
+
+		const l = `
+		UPDATE
+			users
+		SET
+			failed_logins = $2,
+			locked_until = $3
+		WHERE
+			user_id = $1
+		`
+		if _, err := tx.Exec(l, usr.ID, usr.FailedLogins, until.UTC()); err != nil {
+			return Info{}, false, errors.Wrapf(err, "updating failed logins of user %q", usr.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, false, err
+	}
+	return usr, locked, nil
+}
+
+// ResetFailedLogins clears the failed logins of a user after a successful login,
+// which for users with two-factor authentication includes the code.
+func (ur UserRepository) ResetFailedLogins(userID string) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		failed_logins = 0,
+		locked_until = NULL
+	WHERE
+		user_id = $1 AND (failed_logins > 0 OR locked_until IS NOT NULL)
+	`
+	if _, err := ur.Db.Exec(q, userID); err != nil {
+		return errors.Wrapf(err, "resetting failed logins of user %q", userID)
+	}
+	return nil
+}
+
+// Unlock lifts the lockout of the user with the email address.
+func (ur UserRepository) Unlock(email string) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		failed_logins = 0,
+		locked_until = NULL
+	WHERE
+		email = $1
+	`
+	res, err := ur.Db.Exec(q, email)
+	if err != nil {
+		return errors.Wrapf(err, "unlocking user %q", email)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// QueryFailedLogins retrieves the latest audit records of failed logins for an email address.
+func (ur UserRepository) QueryFailedLogins(email string, limit int) ([]LoginAttempt, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		login_attempts
+	WHERE
+		email = $1
+	ORDER BY
+		created_at DESC
+	LIMIT $2
+	`
+	var attempts []LoginAttempt
+	if err := ur.Db.Select(&attempts, q, email, limit); err != nil {
+		return nil, errors.Wrapf(err, "selecting failed logins of %q", email)
+	}
+	return attempts, nil
+}
+
+// QueryRecentFailedLogins retrieves the latest audit records of failed logins
+// for all email addresses.
+func (ur UserRepository) QueryRecentFailedLogins(limit int) ([]LoginAttempt, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		login_attempts
+	ORDER BY
+		created_at DESC
+	LIMIT $1
+	`
+	var attempts []LoginAttempt
+	if err := ur.Db.Select(&attempts, q, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting failed logins")
+	}
+	return attempts, nil
+}
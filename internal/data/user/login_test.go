@@ -0,0 +1,153 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{12, 512 * time.Second},
+		{13, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.failures); got != tt.want {
+			t.Errorf("%d failures: want %s; got %s", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestCheckLoginByIP(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// failures before the window don't count
+	for i := 0; i < freeLogins+2; i++ {
+		if _, _, err := ur.RecordFailedLogin("old@example.com", "10.0.0.1", LoginWrongPassword, now.Add(-ipWindow-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := ur.CheckLogin("someone@example.com", "10.0.0.1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wait.IsZero() {
+		t.Errorf("failures outside the window: got wait until %v, want none", wait)
+	}
+
+	// failures inside the window count, also for other email addresses and wrong codes
+	for i, reason := range []string{LoginWrongPassword, LoginWrongCode, LoginWrongPassword} {
+		if _, _, err := ur.RecordFailedLogin("new@example.com", "10.0.0.1", reason, now.Add(time.Duration(i-2)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err = ur.CheckLogin("someone@example.com", "10.0.0.1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(Backoff(freeLogins)); !wait.Equal(want) {
+		t.Errorf("failures inside the window: got wait until %v, want %v", wait, want)
+	}
+
+	// throttled logins and other IP addresses don't count
+	if _, _, err := ur.RecordFailedLogin("new@example.com", "10.0.0.2", LoginThrottled, now); err != nil {
+		t.Fatal(err)
+	}
+	wait, err = ur.CheckLogin("someone@example.com", "10.0.0.2", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wait.IsZero() {
+		t.Errorf("other IP address: got wait until %v, want none", wait)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	usr, err := ur.Create(NewUser{Name: "locked", Email: "locked@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= LockoutAfter+1; i++ {
+		// wrong codes of two-factor logins count like wrong passwords
+		reason := LoginWrongPassword
+		if i%2 == 0 {
+			reason = LoginWrongCode
+		}
+		_, locked, err := ur.RecordFailedLogin(usr.Email, "10.0.0.1", reason, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i == LockoutAfter; locked != want {
+			t.Errorf("failure %d: got locked %t, want %t", i, locked, want)
+		}
+	}
+
+	wait, err := ur.CheckLogin(usr.Email, "10.0.0.2", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(LockoutDuration); !wait.Equal(want) {
+		t.Errorf("got wait until %v, want the lockout until %v", wait, want)
+	}
+	attempts, err := ur.QueryFailedLogins(usr.Email, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != LockoutAfter+1 || attempts[0].UserID == nil || *attempts[0].UserID != usr.ID {
+		t.Errorf("got %d audit records %+v, want %d of the user", len(attempts), attempts, LockoutAfter+1)
+	}
+
+	if err := ur.ResetFailedLogins(usr.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ur.QueryByID(usr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FailedLogins != 0 || got.LockedUntil != nil {
+		t.Errorf("after the reset: got %d failed logins, locked until %v", got.FailedLogins, got.LockedUntil)
+	}
+	if wait, err := ur.CheckLogin(usr.Email, "10.0.0.2", now); err != nil || !wait.IsZero() {
+		t.Errorf("after the reset: got wait until %v, %v", wait, err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	usr, err := ur.Create(NewUser{Name: "locked", Email: "locked@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < LockoutAfter; i++ {
+		if _, _, err := ur.RecordFailedLogin(usr.Email, "10.0.0.1", LoginWrongPassword, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ur.Unlock(usr.Email); err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := ur.CheckLogin(usr.Email, "10.0.0.2", now); err != nil || !wait.IsZero() {
+		t.Errorf("after unlocking: got wait until %v, %v", wait, err)
+	}
+	if err := ur.Unlock("nobody@example.com"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("unknown email: got %v, want %v", err, ErrNotFound)
+	}
+}
@@ -1,16 +1,126 @@
 package user
 
+import "time"
+
 // Info is the model for a user.
+// The digest is sent weekly on DigestWeekday (0 is Sunday) at DigestHour in UTC.
+// Handle is unique and mentions the user in comments with @handle.
+// Users without VerifiedAt have not confirmed their email address yet.
+// Users without PasswordSet signed up with OpenID Connect and never chose a password.
+// Sessions with an older SessionVersion than the user are logged out.
+// Two-factor authentication is on once TOTPEnabledAt is set.
+// After failed logins, the user can't log in until LockedUntil.
+// Accounts with DeleteAt are deleted at that time unless the user cancels.
+// Role is RoleUser or RoleAdmin.
 type Info struct {
-	ID           string `db:"user_id"`
-	Name         string `db:"name"`
-	Email        string `db:"email"`
-	PasswordHash []byte `db:"password_hash"`
-	Active       bool   `db:"active"`
-	DateCreated  string `db:"created_at"`
-	DateUpdated  string `db:"updated_at"`
+	ID             string     `db:"user_id"`
+	Name           string     `db:"name"`
+	Email          string     `db:"email"`
+	Handle         string     `db:"handle"`
+	PasswordHash   []byte     `db:"password_hash"`
+	PasswordSet    bool       `db:"password_set"`
+	Active         bool       `db:"active"`
+	DateCreated    string     `db:"created_at"`
+	DateUpdated    string     `db:"updated_at"`
+	DigestEnabled  bool       `db:"digest_enabled"`
+	DigestWeekday  int        `db:"digest_weekday"`
+	DigestHour     int        `db:"digest_hour"`
+	DigestSentAt   *time.Time `db:"digest_sent_at"`
+	VerifiedAt     *time.Time `db:"verified_at"`
+	SessionVersion int        `db:"session_version"`
+	TOTPSecret     string     `db:"totp_secret"`
+	TOTPEnabledAt  *time.Time `db:"totp_enabled_at"`
+	TOTPLastStep   int64      `db:"totp_last_step"`
+	FailedLogins   int        `db:"failed_logins"`
+	LockedUntil    *time.Time `db:"locked_until"`
+	DeleteAt       *time.Time `db:"delete_at"`
+	Role           string     `db:"role"`
+}
+
+// Infos is a list of users.
+type Infos []Info
+
+// Roles of users.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Reasons for failed logins in the audit records.
+const (
+	LoginWrongPassword = "wrong password"
+	LoginWrongCode     = "wrong code"
+	LoginThrottled     = "throttled"
+)
+
+// LoginAttempt is the audit record of a failed login.
+type LoginAttempt struct {
+	Email     string    `db:"email"`
+	UserID    *string   `db:"user_id"`
+	IP        string    `db:"ip"`
+	Reason    string    `db:"reason"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Invite is a single-use code to sign up while registration is invite-only.
+// UsedAt is set once someone signed up with it, UsedByEmail is the address of
+// that account unless it was deleted.
+type Invite struct {
+	Code        string     `db:"code"`
+	CreatedBy   string     `db:"created_by"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UsedBy      *string    `db:"used_by"`
+	UsedByEmail *string    `db:"used_by_email"`
+	UsedAt      *time.Time `db:"used_at"`
+}
+
+// Invites is a list of invites.
+type Invites []Invite
+
+// Session is a logged in session of a user. Current marks the session of
+// the request it was loaded in.
+type Session struct {
+	ID         string    `db:"session_id"`
+	UserID     string    `db:"user_id"`
+	IP         string    `db:"ip"`
+	UserAgent  string    `db:"user_agent"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	Current    bool      `db:"-"`
 }
 
+// Sessions is a list of sessions.
+type Sessions []Session
+
+// Identity is a user as known by an OpenID Connect provider.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// States of a data export.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// Export is an archive of the personal data of a user. The archive itself is
+// only loaded for downloads.
+type Export struct {
+	ID         string     `db:"export_id"`
+	UserID     string     `db:"user_id"`
+	Status     string     `db:"status"`
+	CreatedAt  time.Time  `db:"created_at"`
+	FinishedAt *time.Time `db:"finished_at"`
+}
+
+// Exports is a list of exports.
+type Exports []Export
+
 // NewUser contains information needed to create a new user.
 type NewUser struct {
 	Name            string
@@ -18,3 +128,19 @@ type NewUser struct {
 	Password        string
 	PasswordConfirm string
 }
+
+// DigestSettings contains the settings of the weekly digest of a user.
+type DigestSettings struct {
+	Enabled bool
+	Weekday int
+	Hour    int
+}
+
+// TwoFactor contains what a user needs to set up two-factor authentication:
+// the secret, the otpauth URI for the QR code and, once enabled, the recovery codes.
+type TwoFactor struct {
+	Secret            string
+	URI               string
+	RecoveryCodes     []string
+	RecoveryCodesLeft int
+}
@@ -0,0 +1,164 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidReset occurs when a reset token does not exist, has expired or was already used.
+	ErrInvalidReset = errors.New("password reset is not valid")
+
+	// ErrTooManyResets occurs when a user requested too many password resets in a short time.
+	ErrTooManyResets = errors.New("too many password resets")
+)
+
+const (
+	// ResetTTL is how long a password reset token stays valid.
+	ResetTTL = time.Hour
+
+	// maxResets is how many resets a user can request within ResetTTL.
+	maxResets = 3
+)
+
+// CreateReset creates a single-use password reset token for the active user
+// with the email address. The token is returned to be sent to the user, the
+// database only keeps its hash. The user is also returned with ErrTooManyResets.
+func (ur UserRepository) CreateReset(email string, now time.Time) (string, Info, error) {
+	usr, err := ur.QueryByEmail(email)
+	if err != nil {
+		return "", Info{}, err
+	}
+
+	var count int
+	const c = `
+	SELECT
+		COUNT(*)
+	FROM
+		password_resets
+	WHERE
+		user_id = $1 AND created_at > $2
+	`
+	if err := ur.Db.Get(&count, c, usr.ID, now.Add(-ResetTTL).UTC()); err != nil {
+		return "", Info{}, errors.Wrapf(err, "counting password resets of user %q", usr.ID)
+	}
+	if count >= maxResets {
+		return "", usr, ErrTooManyResets
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Info{}, errors.Wrap(err, "generating reset token")
+	}
+	tok := base64.RawURLEncoding.EncodeToString(b)
+
+	const q = `
+	INSERT INTO password_resets
+		(token_hash, user_id, created_at, expires_at)
+	VALUES
+		($1, $2, $3, $4)`
+	if _, err := ur.Db.Exec(q, hashToken(tok), usr.ID, now.UTC(), now.Add(ResetTTL).UTC()); err != nil {
+		return "", Info{}, errors.Wrapf(err, "inserting password reset for user %q", usr.ID)
+	}
+
+	return tok, usr, nil
+}
+
+// CheckReset makes sure a reset token can still be used.
+func (ur UserRepository) CheckReset(tok string, now time.Time) error {
+	var userID string
+	const q = `
+	SELECT
+		user_id
+	FROM
+		password_resets
+	WHERE
+		token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`
+	if err := ur.Db.Get(&userID, q, hashToken(tok), now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidReset
+		}
+		return errors.Wrap(err, "selecting password reset")
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and uses up all reset
+// tokens of the user. All sessions of the user are logged out. As the token
+// was sent by email, the email address counts as verified afterwards.
+func (ur UserRepository) ResetPassword(tok, newPassword string, now time.Time) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	var userID string
+	const q = `
+	SELECT
+		user_id
+	FROM
+		password_resets
+	WHERE
+		token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`
+	if err := tx.Get(&userID, q, hashToken(tok), now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidReset
+		}
+		return errors.Wrap(err, "selecting password reset")
+	}
+
+	const u = `
+	UPDATE
+		users
+	SET
+		password_hash = $2,
+		password_set = TRUE,
+		session_version = session_version + 1,
+		verified_at = COALESCE(verified_at, $3)
+	WHERE
+		user_id = $1
+	`
+	if _, err := tx.Exec(u, userID, hash, now.UTC()); err != nil {
+		return errors.Wrapf(err, "resetting password for user %q", userID)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_sessions WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrapf(err, "deleting sessions of user %q", userID)
+	}
+
+	const d = `
+	UPDATE
+		password_resets
+	SET
+		used_at = $2
+	WHERE
+		user_id = $1 AND used_at IS NULL
+	`
+	if _, err := tx.Exec(d, userID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "using up password resets for user %q", userID)
+	}
+
+	return tx.Commit()
+}
+
+// hashToken hashes a reset token for storage. The tokens are long and random,
+// so a fast hash is enough.
+func hashToken(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestReset(t *testing.T) {
+	db := datatest.NewDB(t)
+	ur := New(db)
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	usr, err := ur.Create(NewUser{Name: "forgetful", Email: "forgetful@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ur.CreateSession(usr.ID, "10.0.0.1", "test", now); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, got, err := ur.CreateReset(usr.Email, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != usr.ID {
+		t.Errorf("got user %q, want %q", got.ID, usr.ID)
+	}
+	other, _, err := ur.CreateReset(usr.Email, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ur.CheckReset(tok, now.Add(ResetTTL-time.Second)); err != nil {
+		t.Errorf("checking a valid token: %v", err)
+	}
+	if err := ur.CheckReset(tok, now.Add(ResetTTL)); errors.Cause(err) != ErrInvalidReset {
+		t.Errorf("checking an expired token: got %v, want %v", err, ErrInvalidReset)
+	}
+	if err := ur.ResetPassword(tok, "new password", now.Add(ResetTTL)); errors.Cause(err) != ErrInvalidReset {
+		t.Errorf("resetting with an expired token: got %v, want %v", err, ErrInvalidReset)
+	}
+	if err := ur.CheckReset("unknown", now); errors.Cause(err) != ErrInvalidReset {
+		t.Errorf("checking an unknown token: got %v, want %v", err, ErrInvalidReset)
+	}
+	if err := ur.ResetPassword("unknown", "new password", now); errors.Cause(err) != ErrInvalidReset {
+		t.Errorf("resetting with an unknown token: got %v, want %v", err, ErrInvalidReset)
+	}
+
+	if err := ur.ResetPassword(tok, "new password", now); err != nil {
+		t.Fatal(err)
+	}
+	// the token works once and uses up the other tokens of the user
+	for _, tok := range []string{tok, other} {
+		if err := ur.ResetPassword(tok, "another password", now); errors.Cause(err) != ErrInvalidReset {
+			t.Errorf("resetting with a used token: got %v, want %v", err, ErrInvalidReset)
+		}
+	}
+
+	got, err = ur.QueryByID(usr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SessionVersion != usr.SessionVersion+1 {
+		t.Errorf("got session version %d, want %d", got.SessionVersion, usr.SessionVersion+1)
+	}
+	var sessions int
+	if err := db.Get(&sessions, `SELECT COUNT(*) FROM user_sessions WHERE user_id = $1`, usr.ID); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != 0 {
+		t.Errorf("got %d sessions after the reset, want none", sessions)
+	}
+	// the email address counts as verified, so the new password works
+	if _, err := ur.Authenticate(usr.Email, "new password"); err != nil {
+		t.Errorf("logging in with the new password: %v", err)
+	}
+}
+
+func TestTooManyResets(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	usr, err := ur.Create(NewUser{Name: "forgetful", Email: "forgetful@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxResets; i++ {
+		if _, _, err := ur.CreateReset(usr.Email, now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, got, err := ur.CreateReset(usr.Email, now.Add(maxResets*time.Minute))
+	if errors.Cause(err) != ErrTooManyResets {
+		t.Fatalf("reset %d: got %v, want %v", maxResets+1, err, ErrTooManyResets)
+	}
+	if got.ID != usr.ID {
+		t.Errorf("got user %q with the error, want %q", got.ID, usr.ID)
+	}
+	// once the first reset is older than ResetTTL, there is room for one more
+	if _, _, err := ur.CreateReset(usr.Email, now.Add(ResetTTL+time.Second)); err != nil {
+		t.Errorf("reset after the first one expired: %v", err)
+	}
+
+	if _, _, err := ur.CreateReset("nobody@example.com", now); errors.Cause(err) != ErrNotFound {
+		t.Errorf("unknown email: got %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,117 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// touchInterval is how often the last seen time of a session is updated.
+const touchInterval = time.Minute
+
+// CreateSession records a new logged in session of a user.
+func (ur UserRepository) CreateSession(userID, ip, userAgent string, now time.Time) (Session, error) {
+	s := Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now.UTC(),
+		LastSeenAt: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO user_sessions
+		(session_id, user_id, ip, user_agent, created_at, last_seen_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+	if _, err := ur.Db.Exec(q, s.ID, s.UserID, s.IP, s.UserAgent, s.CreatedAt, s.LastSeenAt); err != nil {
+		return Session{}, errors.Wrapf(err, "inserting session for user %q", userID)
+	}
+
+	return s, nil
+}
+
+// TouchSession checks that a session of a user was not revoked and updates
+// when it was last seen. Revoked sessions return ErrNotFound.
+func (ur UserRepository) TouchSession(sessionID, userID, ip string, now time.Time) error {
+	var lastSeen time.Time
+	const q = `SELECT last_seen_at FROM user_sessions WHERE session_id = $1 AND user_id = $2`
+	if err := ur.Db.Get(&lastSeen, q, sessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "selecting session %q", sessionID)
+	}
+
+	if now.Sub(lastSeen) < touchInterval {
+		return nil
+	}
+
+	const u = `
+	UPDATE
+		user_sessions
+	SET
+		last_seen_at = $2,
+		ip = $3
+	WHERE
+		session_id = $1
+	`
+	if _, err := ur.Db.Exec(u, sessionID, now.UTC(), ip); err != nil {
+		return errors.Wrapf(err, "updating session %q", sessionID)
+	}
+	return nil
+}
+
+// QuerySessions retrieves the sessions of a user, the most recently seen first.
+func (ur UserRepository) QuerySessions(userID string) (Sessions, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		user_sessions
+	WHERE
+		user_id = $1
+	ORDER BY
+		last_seen_at DESC
+	`
+	var sessions Sessions
+	if err := ur.Db.Select(&sessions, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting sessions of user %q", userID)
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out one session of a user.
+func (ur UserRepository) RevokeSession(sessionID, userID string) error {
+	const q = `DELETE FROM user_sessions WHERE session_id = $1 AND user_id = $2`
+	res, err := ur.Db.Exec(q, sessionID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting session %q", sessionID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeSessions logs out all sessions of a user except the one with the
+// given ID, which may be empty to log out everywhere.
+func (ur UserRepository) RevokeSessions(userID, exceptID string) error {
+	const q = `DELETE FROM user_sessions WHERE user_id = $1 AND session_id != $2`
+	if _, err := ur.Db.Exec(q, userID, exceptID); err != nil {
+		return errors.Wrapf(err, "deleting sessions of user %q", userID)
+	}
+	return nil
+}
+
+// DeleteStaleSessions removes sessions created before a time, they have expired
+// in the session manager already.
+func (ur UserRepository) DeleteStaleSessions(before time.Time) error {
+	if _, err := ur.Db.Exec(`DELETE FROM user_sessions WHERE created_at < $1`, before.UTC()); err != nil {
+		return errors.Wrap(err, "deleting stale sessions")
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTOTPEnabled occurs when a user who already uses two-factor authentication tries to set it up again.
+	ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+	// ErrInvalidCode occurs when a one-time code or recovery code is wrong or was already used.
+	ErrInvalidCode = errors.New("code is not valid")
+)
+
+// recoveryCodes is how many recovery codes a user gets when enabling two-factor authentication.
+const recoveryCodes = 10
+
+// SetTOTPSecret stores a new secret for a user who is setting up two-factor
+// authentication. It is not used for logins until EnableTOTP is called.
+func (ur UserRepository) SetTOTPSecret(userID, secret string) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		totp_secret = $2
+	WHERE
+		user_id = $1 AND totp_enabled_at IS NULL
+	`
+	res, err := ur.Db.Exec(q, userID, secret)
+	if err != nil {
+		return errors.Wrapf(err, "setting TOTP secret for user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTOTPEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication with the pending secret once
+// the user confirmed a code of the given step. It returns new recovery codes,
+// which are only stored hashed and can't be shown again.
+func (ur UserRepository) EnableTOTP(userID string, step int64, now time.Time) ([]string, error) {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		users
+	SET
+		totp_enabled_at = $2,
+		totp_last_step = $3
+	WHERE
+		user_id = $1 AND totp_enabled_at IS NULL AND totp_secret != ''
+	`
+	res, err := tx.Exec(q, userID, now.UTC(), step)
+	if err != nil {
+		return nil, errors.Wrapf(err, "enabling TOTP for user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrTOTPEnabled
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, errors.Wrapf(err, "deleting recovery codes of user %q", userID)
+	}
+
+	codes := make([]string, recoveryCodes)
+	const c = `
+	INSERT INTO recovery_codes
+		(code_hash, user_id)
+	VALUES
+		($1, $2)`
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.Wrap(err, "generating recovery code")
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		if _, err := tx.Exec(c, hashToken(normalizeCode(code)), userID); err != nil {
+			return nil, errors.Wrapf(err, "inserting recovery code for user %q", userID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseTOTPStep records that the code of a step was used to log in. A step can
+// be used only once, so a code that was seen by someone else can't be replayed.
+func (ur UserRepository) UseTOTPStep(userID string, step int64) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		totp_last_step = $2
+	WHERE
+		user_id = $1 AND totp_last_step < $2
+	`
+	res, err := ur.Db.Exec(q, userID, step)
+	if err != nil {
+		return errors.Wrapf(err, "using TOTP step for user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// UseRecoveryCode uses up one of the recovery codes of a user.
+func (ur UserRepository) UseRecoveryCode(userID, code string, now time.Time) error {
+	const q = `
+	UPDATE
+		recovery_codes
+	SET
+		used_at = $3
+	WHERE
+		code_hash = $1 AND user_id = $2 AND used_at IS NULL
+	`
+	res, err := ur.Db.Exec(q, hashToken(normalizeCode(code)), userID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "using recovery code for user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// QueryRecoveryCodesLeft counts the unused recovery codes of a user.
+func (ur UserRepository) QueryRecoveryCodesLeft(userID string) (int, error) {
+	var count int
+	const q = `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := ur.Db.Get(&count, q, userID); err != nil {
+		return 0, errors.Wrapf(err, "counting recovery codes of user %q", userID)
+	}
+	return count, nil
+}
+
+// DisableTOTP turns off two-factor authentication and removes the secret and
+// all recovery codes. Callers confirm that the user asked for it, e.g. with
+// CheckPassword.
+func (ur UserRepository) DisableTOTP(userID string) error {
+	tx, err := ur.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		users
+	SET
+		totp_secret = '',
+		totp_enabled_at = NULL,
+		totp_last_step = 0
+	WHERE
+		user_id = $1
+	`
+	if _, err := tx.Exec(q, userID); err != nil {
+		return errors.Wrapf(err, "disabling TOTP for user %q", userID)
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrapf(err, "deleting recovery codes of user %q", userID)
+	}
+
+	return tx.Commit()
+}
+
+// normalizeCode makes recovery codes match no matter how they were typed.
+func normalizeCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
@@ -2,6 +2,8 @@ package user
 
 import (
 	"database/sql"
+	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -27,6 +29,21 @@ var (
 	// anything goes wrong.
 	ErrAuthenticationFailure = errors.New("authentication failed")
 
+	// ErrNotVerified occurs when a user with a correct password has not verified the email address yet.
+	ErrNotVerified = errors.New("email address is not verified")
+
+	// ErrInvalidDigest occurs when the weekday or hour of a digest schedule is out of range.
+	ErrInvalidDigest = errors.New("digest schedule is not valid")
+
+	// ErrRegistrationClosed occurs when someone tries to sign up while new accounts are not allowed.
+	ErrRegistrationClosed = errors.New("registration is closed")
+
+	// ErrInviteRequired occurs when someone tries to sign up without an invite code while registration is invite-only.
+	ErrInviteRequired = errors.New("registration needs an invite code")
+
+	// ErrDomainNotAllowed occurs when someone tries to sign up with an email address at a domain that is not allowed.
+	ErrDomainNotAllowed = errors.New("email domain is not allowed")
+
 	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
 )
@@ -68,6 +85,10 @@ func (ur UserRepository) QueryByID(userID string) (Info, error) {
 
 // Create inserts a new user into the database.
 func (ur UserRepository) Create(user NewUser) (Info, error) {
+	return insertUser(ur.Db, user)
+}
+
+func insertUser(tx execer, user NewUser) (Info, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return Info{}, errors.Wrap(err, "generating password hash")
@@ -85,30 +106,57 @@ func (ur UserRepository) Create(user NewUser) (Info, error) {
 
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, active, created_at, updated_at)
+		(user_id, name, email, handle, password_hash, active, created_at, updated_at)
 	VALUES
-		($1, $2, $3, $4, $5, $6, $7)`
+		($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	if _, err = ur.Db.Exec(q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Active, usr.DateCreated, usr.DateUpdated); err != nil {
+	// Users with the same name get a number, the first free one wins.
+	base := baseHandle(usr.Name)
+	for n := 1; ; n++ {
+		usr.Handle = base
+		if n > 1 {
+			usr.Handle = fmt.Sprintf("%s_%d", base, n)
+		}
+		_, err = tx.Exec(q, usr.ID, usr.Name, usr.Email, usr.Handle, usr.PasswordHash, usr.Active, usr.DateCreated, usr.DateUpdated)
+		if err == nil {
+			return usr, nil
+		}
 		var sqLiteError *sqlite.Error
-		if errors.As(err, &sqLiteError) {
-			if sqLiteError.Code() == 2067 && strings.Contains(sqLiteError.Error(), "users.email") {
+		if errors.As(err, &sqLiteError) && sqLiteError.Code() == 2067 {
+			if strings.Contains(sqLiteError.Error(), "users.email") {
 				return Info{}, ErrDuplicateEmail
 			}
+			if strings.Contains(sqLiteError.Error(), "users.handle") {
+				continue
+			}
 		}
 		return Info{}, errors.Wrap(err, "inserting user")
 	}
+}
 
-	return usr, nil
+// handleStrip matches what can't be part of a mention. Underscores are
+// stripped as well, they separate the number of users with the same name.
+var handleStrip = regexp.MustCompile(`[^\p{L}\p{N}.+-]+`)
+
+// baseHandle derives a handle from the name of a user, "Ann Lee" is "ann.lee".
+func baseHandle(name string) string {
+	handle := strings.ToLower(strings.Join(strings.Fields(name), "."))
+	handle = strings.Trim(handleStrip.ReplaceAllString(handle, ""), ".-")
+	if handle == "" {
+		return "user"
+	}
+	return handle
 }
 
 // Authenticate queries the database for a user with a matching pasword.
+// Users who have not verified their email address can not log in.
 func (ur UserRepository) Authenticate(email, password string) (string, error) {
 	var id string
 	var hash []byte
+	var verified bool
 	const q = `
 	SELECT
-		user_id, password_hash
+		user_id, password_hash, verified_at IS NOT NULL
 	FROM
 		users
 	WHERE 
@@ -117,7 +165,7 @@ func (ur UserRepository) Authenticate(email, password string) (string, error) {
 		active = TRUE
 	`
 	row := ur.Db.QueryRowx(q, email)
-	err := row.Scan(&id, &hash)
+	err := row.Scan(&id, &hash, &verified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return id, ErrAuthenticationFailure
@@ -132,10 +180,82 @@ func (ur UserRepository) Authenticate(email, password string) (string, error) {
 			return id, err
 		}
 	}
+	if !verified {
+		return id, ErrNotVerified
+	}
 
 	return id, nil
 }
 
+// QueryByEmail gets the active user with the specified email address from the database.
+func (ur UserRepository) QueryByEmail(email string) (Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
+		email = $1
+	AND
+		active = TRUE`
+
+	var usr Info
+	if err := ur.Db.Get(&usr, q, email); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting user by email %q", email)
+	}
+
+	return usr, nil
+}
+
+// Verify marks the email address of a user as verified. Verifying a user
+// twice keeps the time of the first verification.
+func (ur UserRepository) Verify(userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		verified_at = COALESCE(verified_at, $2)
+	WHERE
+		user_id = $1
+	`
+	res, err := ur.Db.Exec(q, userID, time.Now().UTC())
+	if err != nil {
+		return errors.Wrapf(err, "verifying user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CheckPassword returns ErrAuthenticationFailure unless password is the
+// password of the user.
+func (ur UserRepository) CheckPassword(password, userID string) error {
+	var hash []byte
+	const p = `SELECT password_hash FROM users WHERE user_id = $1`
+	if err := ur.Db.Get(&hash, p, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "selecting hashed password for %q", userID)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrAuthenticationFailure
+		}
+		return err
+	}
+	return nil
+}
+
 func (ur UserRepository) ChangePassword(currentPassword, newPassword, userID string) error {
 	var currentPasswordHash []byte
 	const p = `
@@ -180,3 +300,65 @@ func (ur UserRepository) ChangePassword(currentPassword, newPassword, userID str
 
 	return nil
 }
+
+// UpdateDigest changes the digest settings of a user. Enabling the digest
+// counts as sending one, so the first digest arrives on the next scheduled day.
+func (ur UserRepository) UpdateDigest(ds DigestSettings, userID string) error {
+	if ds.Weekday < 0 || ds.Weekday > 6 || ds.Hour < 0 || ds.Hour > 23 {
+		return ErrInvalidDigest
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		digest_enabled = $2,
+		digest_weekday = $3,
+		digest_hour = $4,
+		digest_sent_at = CASE WHEN $2 AND NOT digest_enabled THEN $5 ELSE digest_sent_at END
+	WHERE
+		user_id = $1
+	`
+	res, err := ur.Db.Exec(q, userID, ds.Enabled, ds.Weekday, ds.Hour, time.Now().UTC())
+	if err != nil {
+		return errors.Wrapf(err, "updating digest settings for user %q", userID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// QueryDigestSubscribers retrieves all active users who want to get the digest.
+func (ur UserRepository) QueryDigestSubscribers() ([]Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
+		digest_enabled = TRUE AND active = TRUE
+	`
+	var users []Info
+	if err := ur.Db.Select(&users, q); err != nil {
+		return nil, errors.Wrap(err, "selecting digest subscribers")
+	}
+	return users, nil
+}
+
+// MarkDigestSent records when the last digest was sent to a user.
+func (ur UserRepository) MarkDigestSent(userID string, sentAt time.Time) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		digest_sent_at = $2
+	WHERE
+		user_id = $1
+	`
+	if _, err := ur.Db.Exec(q, userID, sentAt.UTC()); err != nil {
+		return errors.Wrapf(err, "marking digest as sent for user %q", userID)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestCreateHandle(t *testing.T) {
+	ur := New(datatest.NewDB(t))
+
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{name: "Ann Lee", email: "ann@a.example.com", want: "ann.lee"},
+		{name: "Ann  Lee", email: "ann@b.example.com", want: "ann.lee_2"},
+		{name: "ann_lee", email: "ann.lee@example.com", want: "annlee"},
+		{name: "Zoë O'Brien (work)", email: "zoe@example.com", want: "zoë.obrien.work"},
+		{name: "@@@", email: "at@example.com", want: "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			usr, err := ur.Create(NewUser{Name: tt.name, Email: tt.email, Password: "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if usr.Handle != tt.want {
+				t.Errorf("want handle %q; got %q", tt.want, usr.Handle)
+			}
+			got, err := ur.QueryByID(usr.ID)
+			if err != nil || got.Handle != tt.want {
+				t.Errorf("want stored handle %q; got %q, %v", tt.want, got.Handle, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package vote
+
+import "time"
+
+// Tally is the model for the up- and downvotes of a maybe.
+// Mine is the vote of the current user: 1, -1 or 0 if they did not vote.
+type Tally struct {
+	Up    int `db:"up"`
+	Down  int `db:"down"`
+	Score int `db:"score"`
+	Mine  int `db:"mine"`
+}
+
+// Round is the model for a dot-voting round of a workspace.
+// Spent is the number of dots the current user has spent in the round.
+type Round struct {
+	ID          string    `db:"round_id"`
+	WorkspaceID string    `db:"workspace_id"`
+	Name        string    `db:"name"`
+	Budget      int       `db:"budget"`
+	ClosesAt    time.Time `db:"closes_at"`
+	CreatedBy   string    `db:"created_by"`
+	DateCreated string    `db:"created_at"`
+	Spent       int       `db:"spent"`
+}
+
+type Rounds []Round
+
+// IsClosed returns true once the closing date of the round has passed.
+func (r Round) IsClosed() bool {
+	return !time.Now().UTC().Before(r.ClosesAt)
+}
+
+// Remaining returns the number of dots the current user can still spend.
+func (r Round) Remaining() int {
+	return r.Budget - r.Spent
+}
+
+// Ballot is a maybe of the workspace with the dots the current user spent on it.
+type Ballot struct {
+	MaybeID string `db:"maybe_id"`
+	Title   string `db:"title"`
+	Dots    int    `db:"dots"`
+}
+
+type Ballots []Ballot
+
+// Result is the total of dots all members spent on a maybe in a round.
+type Result struct {
+	MaybeID string `db:"maybe_id"`
+	Title   string `db:"title"`
+	Dots    int    `db:"dots"`
+	Voters  int    `db:"voters"`
+}
+
+type Results []Result
+
+// NewRound is the data for creating a new voting round.
+type NewRound struct {
+	Name     string
+	Budget   int
+	ClosesAt time.Time
+}
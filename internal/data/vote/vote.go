@@ -0,0 +1,357 @@
+package vote
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific maybe or round is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidVote occurs when a vote is not an upvote, a downvote or a withdrawal.
+	ErrInvalidVote = errors.New("vote is not valid")
+
+	// ErrRoundClosed occurs when a member tries to vote after the closing date of a round.
+	ErrRoundClosed = errors.New("voting round is closed")
+
+	// ErrBudgetExceeded occurs when a member tries to spend more dots than the round allows.
+	ErrBudgetExceeded = errors.New("vote budget exceeded")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// VoteRepository defines the repository for the vote service.
+type VoteRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a pointer to a vote repo.
+func New(db *sqlx.DB) VoteRepository {
+	return VoteRepository{Db: db}
+}
+
+// canView checks if the user can read the maybe, either as the author of a
+// personal maybe or as a member of its workspace.
+func (vr VoteRepository) canView(maybeID string, userID string) error {
+	if _, err := uuid.Parse(maybeID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		(m.workspace_id IS NULL AND m.user_id = $2) OR wm.user_id IS NOT NULL
+	FROM maybes AS m
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = m.workspace_id AND wm.user_id = $2
+	WHERE
+		m.maybe_id = $1
+	`
+	var ok bool
+	if err := vr.Db.Get(&ok, q, maybeID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "checking access to maybe %q", maybeID)
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Vote up- (1) or downvotes (-1) a maybe for the current user. A value of 0
+// withdraws the vote.
+func (vr VoteRepository) Vote(maybeID string, value int, userID string) error {
+	if value < -1 || value > 1 {
+		return ErrInvalidVote
+	}
+	if err := vr.canView(maybeID, userID); err != nil {
+		return err
+	}
+
+	if value == 0 {
+		const d = `DELETE FROM votes WHERE maybe_id = $1 AND user_id = $2`
+		if _, err := vr.Db.Exec(d, maybeID, userID); err != nil {
+			return errors.Wrapf(err, "withdrawing vote for maybe %q", maybeID)
+		}
+		return nil
+	}
+
+	const q = `
+	INSERT INTO votes
+		(maybe_id, user_id, value, created_at)
+	VALUES
+		($1, $2, $3, $4)
+	ON CONFLICT(maybe_id, user_id) DO UPDATE SET
+		value = excluded.value
+	`
+	if _, err := vr.Db.Exec(q, maybeID, userID, value, time.Now().UTC().String()); err != nil {
+		return errors.Wrapf(err, "voting for maybe %q", maybeID)
+	}
+	return nil
+}
+
+// QueryTally returns the votes of a maybe including the vote of the current user.
+func (vr VoteRepository) QueryTally(maybeID string, userID string) (Tally, error) {
+	if err := vr.canView(maybeID, userID); err != nil {
+		return Tally{}, err
+	}
+
+	const q = `
+	SELECT
+		COALESCE(SUM(value = 1), 0) AS up,
+		COALESCE(SUM(value = -1), 0) AS down,
+		COALESCE(SUM(value), 0) AS score,
+		COALESCE(SUM(CASE WHEN user_id = $2 THEN value END), 0) AS mine
+	FROM votes
+	WHERE
+		maybe_id = $1
+	`
+	var t Tally
+	if err := vr.Db.Get(&t, q, maybeID, userID); err != nil {
+		return Tally{}, errors.Wrapf(err, "selecting votes for maybe %q", maybeID)
+	}
+	return t, nil
+}
+
+// CreateRound starts a new voting round in a workspace. Only owners and
+// editors of the workspace can start rounds.
+func (vr VoteRepository) CreateRound(nr NewRound, workspaceID string, userID string) (Round, error) {
+	if _, err := uuid.Parse(workspaceID); err != nil {
+		return Round{}, ErrInvalidID
+	}
+
+	const m = `
+	SELECT
+		COUNT(*)
+	FROM workspacemembers
+	WHERE
+		workspace_id = $1 AND user_id = $2 AND role IN ('owner', 'editor')
+	`
+	var count int
+	if err := vr.Db.Get(&count, m, workspaceID, userID); err != nil {
+		return Round{}, errors.Wrapf(err, "checking membership in workspace %q", workspaceID)
+	}
+	if count == 0 {
+		return Round{}, ErrForbidden
+	}
+
+	round := Round{
+		ID:          uuid.New().String(),
+		WorkspaceID: workspaceID,
+		Name:        nr.Name,
+		Budget:      nr.Budget,
+		ClosesAt:    nr.ClosesAt.UTC(),
+		CreatedBy:   userID,
+		DateCreated: time.Now().UTC().String(),
+	}
+
+	const q = `
+	INSERT INTO votingrounds
+		(round_id, workspace_id, name, budget, closes_at, created_by, created_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := vr.Db.Exec(q, round.ID, round.WorkspaceID, round.Name, round.Budget, round.ClosesAt, round.CreatedBy, round.DateCreated); err != nil {
+		return Round{}, errors.Wrap(err, "inserting voting round")
+	}
+
+	return round, nil
+}
+
+// QueryRounds retrieves all voting rounds of a workspace the current user is a member of.
+func (vr VoteRepository) QueryRounds(workspaceID string, userID string) (Rounds, error) {
+	const q = `
+	SELECT
+		r.*,
+		COALESCE((
+			SELECT SUM(rv.dots) FROM roundvotes AS rv
+			WHERE rv.round_id = r.round_id AND rv.user_id = $2
+		), 0) AS spent
+	FROM votingrounds AS r
+	JOIN
+		workspacemembers AS wm ON wm.workspace_id = r.workspace_id AND wm.user_id = $2
+	WHERE
+		r.workspace_id = $1
+	ORDER BY
+		r.closes_at DESC
+	`
+	var rounds Rounds
+	if err := vr.Db.Select(&rounds, q, workspaceID, userID); err != nil {
+		return rounds, errors.Wrap(err, "selecting voting rounds")
+	}
+	return rounds, nil
+}
+
+// QueryRoundByID retrieves a voting round if the current user is a member of its workspace.
+func (vr VoteRepository) QueryRoundByID(roundID string, userID string) (Round, error) {
+	if _, err := uuid.Parse(roundID); err != nil {
+		return Round{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		r.*,
+		COALESCE((
+			SELECT SUM(rv.dots) FROM roundvotes AS rv
+			WHERE rv.round_id = r.round_id AND rv.user_id = $2
+		), 0) AS spent,
+		wm.user_id IS NOT NULL AS member
+	FROM votingrounds AS r
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = r.workspace_id AND wm.user_id = $2
+	WHERE
+		r.round_id = $1
+	`
+	var round struct {
+		Round
+		Member bool `db:"member"`
+	}
+	if err := vr.Db.Get(&round, q, roundID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return Round{}, ErrNotFound
+		}
+		return Round{}, errors.Wrapf(err, "selecting voting round %q", roundID)
+	}
+	if !round.Member {
+		return Round{}, ErrForbidden
+	}
+	return round.Round, nil
+}
+
+// QueryBallot retrieves all maybes of the workspace of a round together with the
+// dots the current user has spent on them.
+func (vr VoteRepository) QueryBallot(roundID string, userID string) (Ballots, error) {
+	round, err := vr.QueryRoundByID(roundID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		m.maybe_id,
+		m.title,
+		COALESCE(rv.dots, 0) AS dots
+	FROM maybes AS m
+	LEFT JOIN
+		roundvotes AS rv ON rv.maybe_id = m.maybe_id AND rv.round_id = $2 AND rv.user_id = $3
+	WHERE
+		m.workspace_id = $1
+	ORDER BY
+		m.title
+	`
+	var ballots Ballots
+	if err := vr.Db.Select(&ballots, q, round.WorkspaceID, round.ID, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting ballot for round %q", roundID)
+	}
+	return ballots, nil
+}
+
+// CastDots sets the dots the current user spends on a maybe in an open round.
+// All dots of a member together must not exceed the budget of the round.
+func (vr VoteRepository) CastDots(roundID string, maybeID string, dots int, userID string) error {
+	if dots < 0 {
+		return ErrInvalidVote
+	}
+	if _, err := uuid.Parse(maybeID); err != nil {
+		return ErrInvalidID
+	}
+
+	round, err := vr.QueryRoundByID(roundID, userID)
+	if err != nil {
+		return err
+	}
+	if round.IsClosed() {
+		return ErrRoundClosed
+	}
+
+	tx, err := vr.Db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	var count int
+	const m = `SELECT COUNT(*) FROM maybes WHERE maybe_id = $1 AND workspace_id = $2`
+	if err := tx.Get(&count, m, maybeID, round.WorkspaceID); err != nil {
+		return errors.Wrapf(err, "selecting maybe %q", maybeID)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	var others int
+	const s = `
+	SELECT
+		COALESCE(SUM(dots), 0)
+	FROM roundvotes
+	WHERE
+		round_id = $1 AND user_id = $2 AND maybe_id != $3
+	`
+	if err := tx.Get(&others, s, round.ID, userID, maybeID); err != nil {
+		return errors.Wrapf(err, "summing dots in round %q", round.ID)
+	}
+	if others+dots > round.Budget {
+		return ErrBudgetExceeded
+	}
+
+	if dots == 0 {
+		const d = `DELETE FROM roundvotes WHERE round_id = $1 AND maybe_id = $2 AND user_id = $3`
+		if _, err := tx.Exec(d, round.ID, maybeID, userID); err != nil {
+			return errors.Wrapf(err, "withdrawing dots for maybe %q", maybeID)
+		}
+	} else {
+		const q = `
+		INSERT INTO roundvotes
+			(round_id, maybe_id, user_id, dots)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT(round_id, maybe_id, user_id) DO UPDATE SET
+			dots = excluded.dots
+		`
+		if _, err := tx.Exec(q, round.ID, maybeID, userID, dots); err != nil {
+			return errors.Wrapf(err, "casting dots for maybe %q", maybeID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// QueryResults ranks the maybes of a round by the dots all members spent on them.
+func (vr VoteRepository) QueryResults(roundID string, userID string) (Results, error) {
+	round, err := vr.QueryRoundByID(roundID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		m.maybe_id,
+		m.title,
+		SUM(rv.dots) AS dots,
+		COUNT(DISTINCT rv.user_id) AS voters
+	FROM roundvotes AS rv
+	JOIN
+		maybes AS m ON m.maybe_id = rv.maybe_id
+	WHERE
+		rv.round_id = $1
+	GROUP BY
+		m.maybe_id, m.title
+	ORDER BY
+		dots DESC, voters DESC, m.title
+	`
+	var results Results
+	if err := vr.Db.Select(&results, q, round.ID); err != nil {
+		return nil, errors.Wrapf(err, "selecting results of round %q", roundID)
+	}
+	return results, nil
+}
@@ -0,0 +1,139 @@
+package vote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestVote(t *testing.T) {
+	db := datatest.NewDB(t)
+	vr := New(db)
+	alice, bob, carol, outsider := datatest.User(t, db, "alice"), datatest.User(t, db, "bob"), datatest.User(t, db, "carol"), datatest.User(t, db, "outsider")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner", bob: "editor", carol: "viewer"})
+	m := datatest.Maybe(t, db, "Shared", alice, ws)
+
+	for _, v := range []struct {
+		userID string
+		value  int
+	}{{alice, 1}, {bob, 1}, {carol, -1}, {bob, -1}} {
+		if err := vr.Vote(m, v.value, v.userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tally, err := vr.QueryTally(m, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bob changed the vote, so there is one upvote and two downvotes
+	if want := (Tally{Up: 1, Down: 2, Score: -1, Mine: -1}); tally != want {
+		t.Errorf("got tally %+v, want %+v", tally, want)
+	}
+
+	if err := vr.Vote(m, 0, carol); err != nil {
+		t.Fatal(err)
+	}
+	tally, err = vr.QueryTally(m, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Tally{Up: 1, Down: 1, Score: 0, Mine: 0}); tally != want {
+		t.Errorf("after withdrawing: got tally %+v, want %+v", tally, want)
+	}
+
+	datatest.WantErr(t, "voting 2", vr.Vote(m, 2, alice), ErrInvalidVote)
+	datatest.WantErr(t, "non-member voting", vr.Vote(m, 1, outsider), ErrForbidden)
+	_, err = vr.QueryTally(m, outsider)
+	datatest.WantErr(t, "non-member tally", err, ErrForbidden)
+	datatest.WantErr(t, "voting for an unknown maybe", vr.Vote(uuid.New().String(), 1, alice), ErrNotFound)
+
+	personal := datatest.Maybe(t, db, "Mine", alice, "")
+	if err := vr.Vote(personal, 1, alice); err != nil {
+		t.Errorf("voting for a personal maybe: %v", err)
+	}
+	datatest.WantErr(t, "voting for a personal maybe of another user", vr.Vote(personal, 1, bob), ErrForbidden)
+}
+
+func TestRounds(t *testing.T) {
+	db := datatest.NewDB(t)
+	vr := New(db)
+	alice, bob, carol, outsider := datatest.User(t, db, "alice"), datatest.User(t, db, "bob"), datatest.User(t, db, "carol"), datatest.User(t, db, "outsider")
+	ws := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner", bob: "editor", carol: "viewer"})
+	other := datatest.Workspace(t, db, "Team", map[string]string{alice: "owner"})
+	m1, m2, m3 := datatest.Maybe(t, db, "First", alice, ws), datatest.Maybe(t, db, "Second", bob, ws), datatest.Maybe(t, db, "Third", alice, ws)
+	elsewhere := datatest.Maybe(t, db, "Elsewhere", alice, other)
+
+	_, err := vr.CreateRound(NewRound{Name: "Nope", Budget: 3, ClosesAt: time.Now().Add(time.Hour)}, ws, carol)
+	datatest.WantErr(t, "viewer creating a round", err, ErrForbidden)
+	_, err = vr.CreateRound(NewRound{Name: "Nope", Budget: 3, ClosesAt: time.Now().Add(time.Hour)}, ws, outsider)
+	datatest.WantErr(t, "non-member creating a round", err, ErrForbidden)
+	round, err := vr.CreateRound(NewRound{Name: "Next quarter", Budget: 5, ClosesAt: time.Now().Add(time.Hour)}, ws, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// carol spends her budget, then moves dots around
+	if err := vr.CastDots(round.ID, m1, 3, carol); err != nil {
+		t.Fatal(err)
+	}
+	if err := vr.CastDots(round.ID, m2, 2, carol); err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "exceeding the budget", vr.CastDots(round.ID, m3, 1, carol), ErrBudgetExceeded)
+	// replacing an allocation only counts the new dots
+	datatest.WantErr(t, "raising an allocation over the budget", vr.CastDots(round.ID, m1, 4, carol), ErrBudgetExceeded)
+	if err := vr.CastDots(round.ID, m1, 1, carol); err != nil {
+		t.Fatal(err)
+	}
+	if err := vr.CastDots(round.ID, m3, 2, carol); err != nil {
+		t.Fatal(err)
+	}
+	// 0 withdraws the dots
+	if err := vr.CastDots(round.ID, m2, 0, carol); err != nil {
+		t.Fatal(err)
+	}
+	got, err := vr.QueryRoundByID(round.ID, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Spent != 3 || got.Remaining() != 2 {
+		t.Errorf("got %d dots spent, %d remaining, want 3 and 2", got.Spent, got.Remaining())
+	}
+
+	if err := vr.CastDots(round.ID, m3, 4, alice); err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "negative dots", vr.CastDots(round.ID, m1, -1, alice), ErrInvalidVote)
+	datatest.WantErr(t, "maybe of another workspace", vr.CastDots(round.ID, elsewhere, 1, alice), ErrNotFound)
+	datatest.WantErr(t, "non-member casting dots", vr.CastDots(round.ID, m1, 1, outsider), ErrForbidden)
+
+	results, err := vr.QueryResults(round.ID, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Results{
+		{MaybeID: m3, Title: "Third", Dots: 6, Voters: 2},
+		{MaybeID: m1, Title: "First", Dots: 1, Voters: 1},
+	}
+	if len(results) != len(want) || results[0] != want[0] || results[1] != want[1] {
+		t.Errorf("got results %+v, want %+v", results, want)
+	}
+
+	_, err = vr.QueryRoundByID(round.ID, outsider)
+	datatest.WantErr(t, "non-member reading the round", err, ErrForbidden)
+	_, err = vr.QueryResults(round.ID, outsider)
+	datatest.WantErr(t, "non-member reading the results", err, ErrForbidden)
+	_, err = vr.QueryRoundByID(uuid.New().String(), alice)
+	datatest.WantErr(t, "unknown round", err, ErrNotFound)
+	if rounds, err := vr.QueryRounds(ws, outsider); err != nil || len(rounds) != 0 {
+		t.Errorf("non-member listing rounds: got %+v, %v, want none", rounds, err)
+	}
+
+	closed, err := vr.CreateRound(NewRound{Name: "Last quarter", Budget: 5, ClosesAt: time.Now().Add(-time.Minute)}, ws, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "casting dots after the round closed", vr.CastDots(closed.ID, m1, 1, alice), ErrRoundClosed)
+}
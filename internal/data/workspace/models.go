@@ -0,0 +1,65 @@
+package workspace
+
+// Roles of a member in a workspace.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Info is the model for a workspace as seen by one of its members.
+type Info struct {
+	ID          string `db:"workspace_id"`
+	Name        string `db:"name"`
+	Role        string `db:"role"`
+	DateCreated string `db:"created_at"`
+	DateUpdated string `db:"updated_at"`
+}
+
+type Infos []Info
+
+// Member is the model for a user in a workspace.
+type Member struct {
+	WorkspaceID string `db:"workspace_id"`
+	UserID      string `db:"user_id"`
+	Name        string `db:"name"`
+	Email       string `db:"email"`
+	Role        string `db:"role"`
+	DateCreated string `db:"created_at"`
+}
+
+type Members []Member
+
+// Invite is the model for a pending invitation to a workspace.
+type Invite struct {
+	ID            string `db:"invite_id"`
+	WorkspaceID   string `db:"workspace_id"`
+	WorkspaceName string `db:"workspace_name"`
+	Email         string `db:"email"`
+	Role          string `db:"role"`
+	InvitedBy     string `db:"invited_by"`
+	DateCreated   string `db:"created_at"`
+}
+
+type Invites []Invite
+
+// NewWorkspace is the data for creating a new workspace.
+type NewWorkspace struct {
+	Name string
+}
+
+// NewInvite is the data for inviting a user by email.
+type NewInvite struct {
+	Email string
+	Role  string
+}
+
+// CanEdit returns true if the role allows changing maybes of the workspace.
+func (i Info) CanEdit() bool {
+	return i.Role == RoleOwner || i.Role == RoleEditor
+}
+
+// IsOwner returns true if the role allows managing the workspace.
+func (i Info) IsOwner() bool {
+	return i.Role == RoleOwner
+}
@@ -0,0 +1,495 @@
+package workspace
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+)
+
+var (
+	// ErrNotFound is used when a specific workspace or invite is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidRole occurs when a role is not one of owner, editor or viewer.
+	ErrInvalidRole = errors.New("role is not valid")
+
+	// ErrDuplicateInvite occurs when an email has already been invited or is a member.
+	ErrDuplicateInvite = errors.New("email already invited")
+
+	// ErrLastOwner occurs when the last owner of a workspace tries to leave or lose their role.
+	ErrLastOwner = errors.New("workspace needs at least one owner")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// WorkspaceRepository defines the repository for the workspace service.
+type WorkspaceRepository struct {
+	Db *sqlx.DB
+}
+
+// New returns a pointer to a workspace repo.
+func New(db *sqlx.DB) WorkspaceRepository {
+	return WorkspaceRepository{Db: db}
+}
+
+func validRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// Create adds a new workspace with the current user as its owner.
+func (wr WorkspaceRepository) Create(nw NewWorkspace, userID string) (Info, error) {
+	ws := Info{
+		ID:          uuid.New().String(),
+		Name:        nw.Name,
+		Role:        RoleOwner,
+		DateCreated: time.Now().UTC().String(),
+		DateUpdated: time.Now().UTC().String(),
+	}
+
+	tx, err := wr.Db.Beginx()
+	if err != nil {
+		return Info{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO workspaces
+		(workspace_id, name, created_at, updated_at)
+	VALUES
+		($1, $2, $3, $4)
+	`
+	if _, err := tx.Exec(q, ws.ID, ws.Name, ws.DateCreated, ws.DateUpdated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting workspace")
+	}
+
+	const m = `
+	INSERT INTO workspacemembers
+		(workspace_id, user_id, role, created_at)
+	VALUES
+		($1, $2, $3, $4)
+	`
+	if _, err := tx.Exec(m, ws.ID, userID, RoleOwner, ws.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting workspace owner")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, errors.Wrap(err, "committing workspace")
+	}
+
+	return ws, nil
+}
+
+// Query retrieves all workspaces the current user is a member of.
+func (wr WorkspaceRepository) Query(userID string) (Infos, error) {
+	const q = `
+	SELECT
+		w.*,
+		wm.role AS role
+	FROM workspaces AS w
+	JOIN
+		workspacemembers AS wm ON wm.workspace_id = w.workspace_id
+	WHERE
+		wm.user_id = $1
+	ORDER BY
+		w.name
+	`
+	var workspaces Infos
+	if err := wr.Db.Select(&workspaces, q, userID); err != nil {
+		return workspaces, errors.Wrap(err, "selecting workspaces")
+	}
+	return workspaces, nil
+}
+
+// QueryByID retrieves a workspace if the current user is a member.
+func (wr WorkspaceRepository) QueryByID(workspaceID string, userID string) (Info, error) {
+	if _, err := uuid.Parse(workspaceID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		w.*,
+		COALESCE(wm.role, '') AS role
+	FROM workspaces AS w
+	LEFT JOIN
+		workspacemembers AS wm ON wm.workspace_id = w.workspace_id AND wm.user_id = $2
+	WHERE
+		w.workspace_id = $1
+	`
+	var ws Info
+	if err := wr.Db.Get(&ws, q, workspaceID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting workspace %q", workspaceID)
+	}
+
+	if ws.Role == "" {
+		return Info{}, ErrForbidden
+	}
+
+	return ws, nil
+}
+
+// queryOwned retrieves a workspace if the current user is one of its owners.
+func (wr WorkspaceRepository) queryOwned(workspaceID string, userID string) (Info, error) {
+	ws, err := wr.QueryByID(workspaceID, userID)
+	if err != nil {
+		return Info{}, err
+	}
+	if !ws.IsOwner() {
+		return Info{}, ErrForbidden
+	}
+	return ws, nil
+}
+
+// Delete removes a workspace including its maybes. Only owners can delete a workspace.
+func (wr WorkspaceRepository) Delete(workspaceID string, userID string) error {
+	if _, err := wr.queryOwned(workspaceID, userID); err != nil {
+		return err
+	}
+
+	const q = `
+	DELETE FROM
+		workspaces
+	WHERE
+		workspace_id = $1
+	`
+	if _, err := wr.Db.Exec(q, workspaceID); err != nil {
+		return errors.Wrapf(err, "deleting workspace %q", workspaceID)
+	}
+
+	return nil
+}
+
+// QueryMembers retrieves all members of a workspace the current user is a member of.
+func (wr WorkspaceRepository) QueryMembers(workspaceID string, userID string) (Members, error) {
+	var members Members
+	if _, err := wr.QueryByID(workspaceID, userID); err != nil {
+		return members, err
+	}
+
+	const q = `
+	SELECT
+		wm.*,
+		u.name AS name,
+		u.email AS email
+	FROM workspacemembers AS wm
+	JOIN
+		users AS u ON u.user_id = wm.user_id
+	WHERE
+		wm.workspace_id = $1
+	ORDER BY
+		u.name
+	`
+	if err := wr.Db.Select(&members, q, workspaceID); err != nil {
+		return members, errors.Wrapf(err, "selecting members of workspace %q", workspaceID)
+	}
+	return members, nil
+}
+
+// UpdateRole changes the role of a member. Only owners can change roles and
+// a workspace always keeps at least one owner.
+func (wr WorkspaceRepository) UpdateRole(workspaceID string, memberID string, role string, userID string) error {
+	if !validRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := wr.queryOwned(workspaceID, userID); err != nil {
+		return err
+	}
+
+	if role != RoleOwner {
+		if err := wr.checkNotLastOwner(workspaceID, memberID); err != nil {
+			return err
+		}
+	}
+
+	const q = `
+	UPDATE workspacemembers
+	SET
+		role = $3
+	WHERE
+		workspace_id = $1 AND user_id = $2
+	`
+	res, err := wr.Db.Exec(q, workspaceID, memberID, role)
+	if err != nil {
+		return errors.Wrapf(err, "updating role of %q in workspace %q", memberID, workspaceID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RemoveMember removes a member from a workspace. Owners can remove anyone,
+// other members can only leave the workspace themselves.
+func (wr WorkspaceRepository) RemoveMember(workspaceID string, memberID string, userID string) error {
+	ws, err := wr.QueryByID(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !ws.IsOwner() && memberID != userID {
+		return ErrForbidden
+	}
+
+	if err := wr.checkNotLastOwner(workspaceID, memberID); err != nil {
+		return err
+	}
+
+	const q = `
+	DELETE FROM
+		workspacemembers
+	WHERE
+		workspace_id = $1 AND user_id = $2
+	`
+	res, err := wr.Db.Exec(q, workspaceID, memberID)
+	if err != nil {
+		return errors.Wrapf(err, "removing %q from workspace %q", memberID, workspaceID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// checkNotLastOwner returns ErrLastOwner if the member is the only owner of the workspace.
+func (wr WorkspaceRepository) checkNotLastOwner(workspaceID string, memberID string) error {
+	const q = `
+	SELECT
+		COUNT(*)
+	FROM workspacemembers
+	WHERE
+		workspace_id = $1 AND role = 'owner' AND user_id != $2
+	`
+	var others int
+	if err := wr.Db.Get(&others, q, workspaceID, memberID); err != nil {
+		return errors.Wrapf(err, "counting owners of workspace %q", workspaceID)
+	}
+
+	var role string
+	const r = `SELECT role FROM workspacemembers WHERE workspace_id = $1 AND user_id = $2`
+	if err := wr.Db.Get(&role, r, workspaceID, memberID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "selecting role of %q in workspace %q", memberID, workspaceID)
+	}
+
+	if role == RoleOwner && others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// Invite creates a pending invitation for an email address. Only owners can invite.
+func (wr WorkspaceRepository) Invite(workspaceID string, ni NewInvite, userID string) (Invite, error) {
+	if !validRole(ni.Role) {
+		return Invite{}, ErrInvalidRole
+	}
+	ws, err := wr.queryOwned(workspaceID, userID)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(ni.Email))
+
+	// users that are already members cannot be invited again
+	const m = `
+	SELECT
+		COUNT(*)
+	FROM workspacemembers AS wm
+	JOIN
+		users AS u ON u.user_id = wm.user_id
+	WHERE
+		wm.workspace_id = $1 AND LOWER(u.email) = $2
+	`
+	var count int
+	if err := wr.Db.Get(&count, m, workspaceID, email); err != nil {
+		return Invite{}, errors.Wrap(err, "checking existing membership")
+	}
+	if count > 0 {
+		return Invite{}, ErrDuplicateInvite
+	}
+
+	inv := Invite{
+		ID:            uuid.New().String(),
+		WorkspaceID:   workspaceID,
+		WorkspaceName: ws.Name,
+		Email:         email,
+		Role:          ni.Role,
+		InvitedBy:     userID,
+		DateCreated:   time.Now().UTC().String(),
+	}
+
+	const q = `
+	INSERT INTO workspaceinvites
+		(invite_id, workspace_id, email, role, invited_by, created_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := wr.Db.Exec(q, inv.ID, inv.WorkspaceID, inv.Email, inv.Role, inv.InvitedBy, inv.DateCreated); err != nil {
+		var sqLiteError *sqlite.Error
+		if errors.As(err, &sqLiteError) && sqLiteError.Code() == 2067 {
+			return Invite{}, ErrDuplicateInvite
+		}
+		return Invite{}, errors.Wrap(err, "inserting invite")
+	}
+
+	return inv, nil
+}
+
+// QueryInvites retrieves the pending invitations of a workspace. Only owners can see them.
+func (wr WorkspaceRepository) QueryInvites(workspaceID string, userID string) (Invites, error) {
+	var invites Invites
+	if _, err := wr.queryOwned(workspaceID, userID); err != nil {
+		return invites, err
+	}
+
+	const q = `
+	SELECT
+		i.*,
+		w.name AS workspace_name
+	FROM workspaceinvites AS i
+	JOIN
+		workspaces AS w ON w.workspace_id = i.workspace_id
+	WHERE
+		i.workspace_id = $1
+	ORDER BY
+		i.created_at
+	`
+	if err := wr.Db.Select(&invites, q, workspaceID); err != nil {
+		return invites, errors.Wrapf(err, "selecting invites of workspace %q", workspaceID)
+	}
+	return invites, nil
+}
+
+// QueryInvitesForUser retrieves the pending invitations for the email address of the current user.
+func (wr WorkspaceRepository) QueryInvitesForUser(userID string) (Invites, error) {
+	const q = `
+	SELECT
+		i.*,
+		w.name AS workspace_name
+	FROM workspaceinvites AS i
+	JOIN
+		workspaces AS w ON w.workspace_id = i.workspace_id
+	JOIN
+		users AS u ON LOWER(u.email) = i.email
+	WHERE
+		u.user_id = $1
+	ORDER BY
+		i.created_at
+	`
+	var invites Invites
+	if err := wr.Db.Select(&invites, q, userID); err != nil {
+		return invites, errors.Wrap(err, "selecting invites for user")
+	}
+	return invites, nil
+}
+
+// queryInviteForUser retrieves a pending invitation addressed to the current user.
+func (wr WorkspaceRepository) queryInviteForUser(inviteID string, userID string) (Invite, error) {
+	if _, err := uuid.Parse(inviteID); err != nil {
+		return Invite{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		i.*,
+		w.name AS workspace_name
+	FROM workspaceinvites AS i
+	JOIN
+		workspaces AS w ON w.workspace_id = i.workspace_id
+	JOIN
+		users AS u ON LOWER(u.email) = i.email
+	WHERE
+		i.invite_id = $1 AND u.user_id = $2
+	`
+	var inv Invite
+	if err := wr.Db.Get(&inv, q, inviteID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return Invite{}, ErrNotFound
+		}
+		return Invite{}, errors.Wrapf(err, "selecting invite %q", inviteID)
+	}
+	return inv, nil
+}
+
+// AcceptInvite makes the current user a member of the workspace they were invited to.
+func (wr WorkspaceRepository) AcceptInvite(inviteID string, userID string) (Invite, error) {
+	inv, err := wr.queryInviteForUser(inviteID, userID)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	tx, err := wr.Db.Beginx()
+	if err != nil {
+		return Invite{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const m = `
+	INSERT OR IGNORE INTO workspacemembers
+		(workspace_id, user_id, role, created_at)
+	VALUES
+		($1, $2, $3, $4)
+	`
+	if _, err := tx.Exec(m, inv.WorkspaceID, userID, inv.Role, time.Now().UTC().String()); err != nil {
+		return Invite{}, errors.Wrap(err, "inserting workspace member")
+	}
+
+	const d = `DELETE FROM workspaceinvites WHERE invite_id = $1`
+	if _, err := tx.Exec(d, inv.ID); err != nil {
+		return Invite{}, errors.Wrapf(err, "deleting invite %q", inv.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Invite{}, errors.Wrap(err, "committing invite")
+	}
+
+	return inv, nil
+}
+
+// DeclineInvite removes a pending invitation of the current user.
+func (wr WorkspaceRepository) DeclineInvite(inviteID string, userID string) error {
+	inv, err := wr.queryInviteForUser(inviteID, userID)
+	if err != nil {
+		return err
+	}
+
+	const d = `DELETE FROM workspaceinvites WHERE invite_id = $1`
+	if _, err := wr.Db.Exec(d, inv.ID); err != nil {
+		return errors.Wrapf(err, "deleting invite %q", inv.ID)
+	}
+	return nil
+}
+
+// RevokeInvite removes a pending invitation of a workspace. Only owners can revoke invites.
+func (wr WorkspaceRepository) RevokeInvite(workspaceID string, inviteID string, userID string) error {
+	if _, err := uuid.Parse(inviteID); err != nil {
+		return ErrInvalidID
+	}
+	if _, err := wr.queryOwned(workspaceID, userID); err != nil {
+		return err
+	}
+
+	const d = `DELETE FROM workspaceinvites WHERE invite_id = $1 AND workspace_id = $2`
+	res, err := wr.Db.Exec(d, inviteID, workspaceID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting invite %q", inviteID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
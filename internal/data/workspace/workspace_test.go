@@ -0,0 +1,163 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+)
+
+func TestInvites(t *testing.T) {
+	db := datatest.NewDB(t)
+	wr := New(db)
+	owner, editor, viewer, outsider := datatest.User(t, db, "owner"), datatest.User(t, db, "editor"), datatest.User(t, db, "viewer"), datatest.User(t, db, "outsider")
+
+	ws, err := wr.Create(NewWorkspace{Name: "Team"}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := wr.Invite(ws.ID, NewInvite{Email: " Editor@Example.com ", Role: RoleEditor}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Email != "editor@example.com" {
+		t.Errorf("got invite for %q, want the normalized email", inv.Email)
+	}
+	_, err = wr.Invite(ws.ID, NewInvite{Email: "editor@example.com", Role: RoleViewer}, owner)
+	datatest.WantErr(t, "inviting twice", err, ErrDuplicateInvite)
+	_, err = wr.Invite(ws.ID, NewInvite{Email: "viewer@example.com", Role: "admin"}, owner)
+	datatest.WantErr(t, "inviting with an invalid role", err, ErrInvalidRole)
+	_, err = wr.AcceptInvite(inv.ID, outsider)
+	datatest.WantErr(t, "accepting an invite for someone else", err, ErrNotFound)
+
+	invites, err := wr.QueryInvitesForUser(editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 1 || invites[0].ID != inv.ID || invites[0].WorkspaceName != "Team" {
+		t.Fatalf("got invites %+v, want the one to Team", invites)
+	}
+	if _, err := wr.AcceptInvite(inv.ID, editor); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := wr.QueryByID(ws.ID, editor); err != nil || got.Role != RoleEditor {
+		t.Errorf("after accepting: got %+v, %v, want the editor role", got, err)
+	}
+	_, err = wr.AcceptInvite(inv.ID, editor)
+	datatest.WantErr(t, "accepting twice", err, ErrNotFound)
+	_, err = wr.Invite(ws.ID, NewInvite{Email: "editor@example.com", Role: RoleViewer}, owner)
+	datatest.WantErr(t, "inviting a member", err, ErrDuplicateInvite)
+
+	// only owners invite and revoke
+	_, err = wr.Invite(ws.ID, NewInvite{Email: "viewer@example.com", Role: RoleViewer}, editor)
+	datatest.WantErr(t, "inviting as an editor", err, ErrForbidden)
+	inv, err = wr.Invite(ws.ID, NewInvite{Email: "viewer@example.com", Role: RoleViewer}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "revoking as an editor", wr.RevokeInvite(ws.ID, inv.ID, editor), ErrForbidden)
+	if err := wr.RevokeInvite(ws.ID, inv.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "revoking twice", wr.RevokeInvite(ws.ID, inv.ID, owner), ErrNotFound)
+	_, err = wr.AcceptInvite(inv.ID, viewer)
+	datatest.WantErr(t, "accepting a revoked invite", err, ErrNotFound)
+
+	inv, err = wr.Invite(ws.ID, NewInvite{Email: "viewer@example.com", Role: RoleViewer}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.DeclineInvite(inv.ID, viewer); err != nil {
+		t.Fatal(err)
+	}
+	_, err = wr.AcceptInvite(inv.ID, viewer)
+	datatest.WantErr(t, "accepting a declined invite", err, ErrNotFound)
+	_, err = wr.QueryByID(ws.ID, viewer)
+	datatest.WantErr(t, "declined invitee", err, ErrForbidden)
+
+	invites, err = wr.QueryInvites(ws.ID, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 0 {
+		t.Errorf("got pending invites %+v, want none", invites)
+	}
+	_, err = wr.QueryInvites(ws.ID, editor)
+	datatest.WantErr(t, "listing invites as an editor", err, ErrForbidden)
+}
+
+func TestMembers(t *testing.T) {
+	db := datatest.NewDB(t)
+	wr := New(db)
+	owner, editor, viewer, outsider := datatest.User(t, db, "owner"), datatest.User(t, db, "editor"), datatest.User(t, db, "viewer"), datatest.User(t, db, "outsider")
+
+	ws, err := wr.Create(NewWorkspace{Name: "Team"}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct{ id, email, role string }{
+		{editor, "editor@example.com", RoleEditor},
+		{viewer, "viewer@example.com", RoleViewer},
+	} {
+		inv, err := wr.Invite(ws.ID, NewInvite{Email: m.email, Role: m.role}, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wr.AcceptInvite(inv.ID, m.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = wr.QueryByID(ws.ID, outsider)
+	datatest.WantErr(t, "workspace of a non-member", err, ErrForbidden)
+	_, err = wr.QueryMembers(ws.ID, outsider)
+	datatest.WantErr(t, "members as a non-member", err, ErrForbidden)
+	_, err = wr.QueryByID(uuid.New().String(), owner)
+	datatest.WantErr(t, "unknown workspace", err, ErrNotFound)
+	if got, err := wr.Query(outsider); err != nil || len(got) != 0 {
+		t.Errorf("workspaces of a non-member: got %+v, %v", got, err)
+	}
+	members, err := wr.QueryMembers(ws.ID, viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Errorf("got %d members, want 3", len(members))
+	}
+
+	// the last owner can neither leave nor be demoted
+	datatest.WantErr(t, "last owner leaving", wr.RemoveMember(ws.ID, owner, owner), ErrLastOwner)
+	datatest.WantErr(t, "last owner demoted", wr.UpdateRole(ws.ID, owner, RoleEditor, owner), ErrLastOwner)
+
+	// only owners change roles and remove others
+	datatest.WantErr(t, "promoting as an editor", wr.UpdateRole(ws.ID, editor, RoleOwner, editor), ErrForbidden)
+	datatest.WantErr(t, "removing as an editor", wr.RemoveMember(ws.ID, viewer, editor), ErrForbidden)
+	datatest.WantErr(t, "invalid role", wr.UpdateRole(ws.ID, editor, "admin", owner), ErrInvalidRole)
+	datatest.WantErr(t, "role of a non-member", wr.UpdateRole(ws.ID, outsider, RoleEditor, owner), ErrNotFound)
+
+	// with a second owner, the first one can step down and leave
+	if err := wr.UpdateRole(ws.ID, editor, RoleOwner, owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.UpdateRole(ws.ID, owner, RoleViewer, owner); err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "new last owner demoted", wr.UpdateRole(ws.ID, editor, RoleViewer, editor), ErrLastOwner)
+	if err := wr.RemoveMember(ws.ID, owner, owner); err != nil {
+		t.Fatal(err)
+	}
+	_, err = wr.QueryByID(ws.ID, owner)
+	datatest.WantErr(t, "workspace after leaving", err, ErrForbidden)
+
+	// members can leave themselves
+	if err := wr.RemoveMember(ws.ID, viewer, viewer); err != nil {
+		t.Fatal(err)
+	}
+	datatest.WantErr(t, "deleting as a former member", wr.Delete(ws.ID, viewer), ErrForbidden)
+	if err := wr.Delete(ws.ID, editor); err != nil {
+		t.Fatal(err)
+	}
+	_, err = wr.QueryByID(ws.ID, editor)
+	datatest.WantErr(t, "deleted workspace", err, ErrNotFound)
+}
@@ -0,0 +1,202 @@
+// Package digest generates and sends the weekly email digest that resurfaces
+// stale and random maybes.
+package digest
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+)
+
+const (
+	// StaleAfter is how long a maybe must not have been updated to be stale.
+	StaleAfter = 90 * 24 * time.Hour
+
+	// MaxStale is the maximum number of stale maybes in a digest, the oldest come first.
+	MaxStale = 5
+
+	// RandomCount is the number of random maybes in a digest.
+	RandomCount = 3
+)
+
+// Digest is the data of a digest email.
+type Digest struct {
+	Name    string
+	BaseURL string
+	Stale   maybe.Infos
+	Random  maybe.Infos
+}
+
+// Empty returns true if the digest has no maybes to show.
+func (d Digest) Empty() bool {
+	return len(d.Stale) == 0 && len(d.Random) == 0
+}
+
+// LastSlot returns the latest scheduled time of a weekly digest at or before now.
+func LastSlot(weekday int, hour int, now time.Time) time.Time {
+	now = now.UTC()
+	slot := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	slot = slot.AddDate(0, 0, -((int(now.Weekday()) - weekday + 7) % 7))
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// NextSlot returns the next scheduled time of a weekly digest after now.
+func NextSlot(weekday int, hour int, now time.Time) time.Time {
+	return LastSlot(weekday, hour, now).AddDate(0, 0, 7)
+}
+
+// Due returns true if the user subscribed to the digest and has not got one
+// since the last scheduled time.
+func Due(u user.Info, now time.Time) bool {
+	if !u.DigestEnabled {
+		return false
+	}
+	slot := LastSlot(u.DigestWeekday, u.DigestHour, now)
+	return u.DigestSentAt == nil || u.DigestSentAt.Before(slot)
+}
+
+// Select picks the stale maybes and a few random other maybes for a digest.
+// The random maybes only depend on the seed, so a preview shows the same
+// maybes as the digest sent with the same seed.
+func Select(maybes maybe.Infos, now time.Time, seed int64) (stale maybe.Infos, random maybe.Infos) {
+	var rest maybe.Infos
+	for _, m := range maybes {
+		updated, err := time.Parse(time.RFC3339Nano, m.DateUpdated)
+		if err == nil && now.Sub(updated) >= StaleAfter {
+			stale = append(stale, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].DateUpdated < stale[j].DateUpdated
+	})
+	if len(stale) > MaxStale {
+		rest = append(rest, stale[MaxStale:]...)
+		stale = stale[:MaxStale]
+	}
+
+	// order the rest by ID first, so that the pick does not depend on the
+	// order of the query
+	sort.Slice(rest, func(i, j int) bool { return rest[i].ID < rest[j].ID })
+	rnd := rand.New(rand.NewSource(seed))
+	rnd.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	if len(rest) > RandomCount {
+		rest = rest[:RandomCount]
+	}
+
+	return stale, rest
+}
+
+// Seed returns the seed for the random maybes of the digest of a user at a scheduled time.
+func Seed(userID string, slot time.Time) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(userID))
+	h.Write([]byte(slot.UTC().Format(time.RFC3339)))
+	return int64(h.Sum64())
+}
+
+// Generator creates digests and sends them.
+type Generator struct {
+	Users interface {
+		QueryDigestSubscribers() ([]user.Info, error)
+		MarkDigestSent(userID string, sentAt time.Time) error
+	}
+	Maybes interface {
+		QueryAllSpaces(userID string, now time.Time) (maybe.Infos, error)
+	}
+	Templates *mail.Templates
+	Sender    mail.Sender
+	BaseURL   string
+}
+
+// Generate creates the digest of a user for a scheduled time.
+func (g Generator) Generate(u user.Info, slot time.Time) (Digest, error) {
+	maybes, err := g.Maybes.QueryAllSpaces(u.ID, slot)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	stale, random := Select(maybes, slot, Seed(u.ID, slot))
+
+	return Digest{
+		Name:    u.Name,
+		BaseURL: strings.TrimSuffix(g.BaseURL, "/"),
+		Stale:   stale,
+		Random:  random,
+	}, nil
+}
+
+// Preview renders the next digest of a user without sending it.
+func (g Generator) Preview(u user.Info, now time.Time) (mail.Message, error) {
+	slot := NextSlot(u.DigestWeekday, u.DigestHour, now)
+	if Due(u, now) {
+		slot = LastSlot(u.DigestWeekday, u.DigestHour, now)
+	}
+	d, err := g.Generate(u, slot)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	return g.Templates.Render("digest", u.Email, d)
+}
+
+// Run sends the digest to every subscriber who is due at the given time.
+// Users without any maybes do not get an empty email.
+func (g Generator) Run(ctx context.Context, now time.Time) error {
+	users, err := g.Users.QueryDigestSubscribers()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, u := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !Due(u, now) {
+			continue
+		}
+
+		if err := g.send(u, LastSlot(u.DigestWeekday, u.DigestHour, now)); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+
+		if err := g.Users.MarkDigestSent(u.ID, now); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("sending %d digests: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (g Generator) send(u user.Info, slot time.Time) error {
+	d, err := g.Generate(u, slot)
+	if err != nil {
+		return err
+	}
+	if d.Empty() {
+		return nil
+	}
+
+	msg, err := g.Templates.Render("digest", u.Email, d)
+	if err != nil {
+		return err
+	}
+	return g.Sender.Send(msg)
+}
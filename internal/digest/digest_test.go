@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+)
+
+func TestLastSlot(t *testing.T) {
+	// 2021-03-03 is a Wednesday
+	now := time.Date(2021, 3, 3, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		weekday int
+		hour    int
+		want    time.Time
+	}{
+		{"Earlier today", 3, 8, time.Date(2021, 3, 3, 8, 0, 0, 0, time.UTC)},
+		{"Later today", 3, 12, time.Date(2021, 2, 24, 12, 0, 0, 0, time.UTC)},
+		{"Monday", 1, 8, time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"Friday", 5, 8, time.Date(2021, 2, 26, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LastSlot(tt.weekday, tt.hour, now); !got.Equal(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2021, 3, 3, 10, 30, 0, 0, time.UTC)
+	at := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name string
+		user user.Info
+		want bool
+	}{
+		{"Disabled", user.Info{DigestWeekday: 3, DigestHour: 8}, false},
+		{"Never sent", user.Info{DigestEnabled: true, DigestWeekday: 3, DigestHour: 8}, true},
+		{"Sent before slot", user.Info{DigestEnabled: true, DigestWeekday: 3, DigestHour: 8, DigestSentAt: at(now.AddDate(0, 0, -7))}, true},
+		{"Sent after slot", user.Info{DigestEnabled: true, DigestWeekday: 3, DigestHour: 8, DigestSentAt: at(now.Add(-time.Hour))}, false},
+		{"Slot later today", user.Info{DigestEnabled: true, DigestWeekday: 3, DigestHour: 12, DigestSentAt: at(now.AddDate(0, 0, -6))}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Due(tt.user, now); got != tt.want {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	now := time.Date(2021, 3, 3, 10, 30, 0, 0, time.UTC)
+	updated := func(days int) string {
+		return now.AddDate(0, 0, -days).Format(time.RFC3339Nano)
+	}
+
+	var maybes maybe.Infos
+	for i := 0; i < 10; i++ {
+		maybes = append(maybes, maybe.Info{ID: fmt.Sprintf("fresh-%d", i), DateUpdated: updated(i)})
+	}
+	maybes = append(maybes,
+		maybe.Info{ID: "stale-1", DateUpdated: updated(100)},
+		maybe.Info{ID: "stale-2", DateUpdated: updated(200)},
+	)
+
+	stale, random := Select(maybes, now, 42)
+
+	if len(stale) != 2 || stale[0].ID != "stale-2" || stale[1].ID != "stale-1" {
+		t.Errorf("want stale maybes oldest first; got %v", stale)
+	}
+	if len(random) != RandomCount {
+		t.Fatalf("want %d random maybes; got %d", RandomCount, len(random))
+	}
+
+	// the same seed picks the same maybes, regardless of the order of the input
+	reversed := make(maybe.Infos, len(maybes))
+	for i, m := range maybes {
+		reversed[len(maybes)-1-i] = m
+	}
+	_, again := Select(reversed, now, 42)
+	for i := range random {
+		if random[i].ID != again[i].ID {
+			t.Fatalf("want the same random maybes for the same seed; got %v and %v", random, again)
+		}
+	}
+}
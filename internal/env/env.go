@@ -2,24 +2,47 @@ package env
 
 import (
 	"html/template"
-	"log"
+	"log/slog"
+	"net"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/oidc"
+	"github.com/sophiabrandt/go-maybe-list/internal/backup"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
 )
 
 // Env defines the local app context and holds global
 // dependencies.
+// MailTemplates renders emails, Mailer sends them and BaseURL is
+// the public URL of the app for links in emails. Secret signs
+// tokens in these links. OIDC is the single sign-on provider, nil if
+// it is not configured. Backups takes the periodic backups, nil if they
+// are turned off. Metrics collects the metrics of the app and
+// MetricsAccess decides who may scrape them, nobody if it is nil.
+// TrustedProxies are the networks of the reverse proxies whose
+// X-Forwarded-For header tells the IP address of the client.
 type Env struct {
-	Log           *log.Logger
-	TemplateCache map[string]*template.Template
-	Session       *scs.SessionManager
+	Log            *slog.Logger
+	TemplateCache  map[string]*template.Template
+	Session        *scs.SessionManager
+	MailTemplates  *mail.Templates
+	Mailer         mail.Sender
+	BaseURL        string
+	Secret         []byte
+	OIDC           *oidc.Provider
+	Backups        *backup.Job
+	Metrics        *metrics.App
+	MetricsAccess  *metrics.Access
+	TrustedProxies []*net.IPNet
 }
 
 // New creates a new pointer to an Env struct.
-func New(log *log.Logger, templateCache map[string]*template.Template, session *scs.SessionManager) *Env {
+func New(log *slog.Logger, templateCache map[string]*template.Template, session *scs.SessionManager) *Env {
 	return &Env{
 		Log:           log,
 		TemplateCache: templateCache,
 		Session:       session,
+		Metrics:       metrics.NewApp(),
 	}
 }
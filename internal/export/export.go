@@ -0,0 +1,308 @@
+// Package export builds archives of the personal data of users.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+)
+
+// maxLogins is how many failed logins of a user go into an archive.
+const maxLogins = 1000
+
+// Job builds the pending exports and tells users when they can download them.
+type Job struct {
+	Users interface {
+		QueryByID(userID string) (user.Info, error)
+		QuerySessions(userID string) (user.Sessions, error)
+		QueryFailedLogins(email string, limit int) ([]user.LoginAttempt, error)
+		QueryPendingExports() (user.Exports, error)
+		FinishExport(exportID string, archive []byte, now time.Time) error
+		DeleteExpiredExports(before time.Time) error
+	}
+	Maybes interface {
+		QueryAuthored(userID string) (maybe.Infos, error)
+	}
+	Comments interface {
+		QueryAuthored(userID string) (comment.Infos, error)
+	}
+	Notifications interface {
+		QueryAll(userID string) (notification.Infos, error)
+	}
+	Notifier notify.Notifier
+}
+
+// Profile is the account of a user without its secrets.
+type Profile struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	CreatedAt     string     `json:"created_at"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+	TwoFactor     bool       `json:"two_factor"`
+	DigestEnabled bool       `json:"digest_enabled"`
+	DigestWeekday int        `json:"digest_weekday"`
+	DigestHour    int        `json:"digest_hour"`
+}
+
+// Maybe is a maybe with the names of its tags.
+type Maybe struct {
+	ID           string     `json:"id"`
+	WorkspaceID  *string    `json:"workspace_id"`
+	Title        string     `json:"title"`
+	URL          string     `json:"url"`
+	Description  string     `json:"description"`
+	Priority     string     `json:"priority"`
+	Effort       int        `json:"effort"`
+	DueDate      *time.Time `json:"due_date"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	Tags         []string   `json:"tags"`
+	CreatedAt    string     `json:"created_at"`
+	UpdatedAt    string     `json:"updated_at"`
+}
+
+// Tag is a tag and how many maybes of the user have it.
+type Tag struct {
+	Name   string `json:"name"`
+	Maybes int    `json:"maybes"`
+}
+
+// Comment is a comment of the user on a maybe.
+type Comment struct {
+	ID        string  `json:"id"`
+	MaybeID   string  `json:"maybe_id"`
+	ParentID  *string `json:"parent_id"`
+	Body      string  `json:"body"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// Notification is a message in the inbox of the user.
+type Notification struct {
+	Message   string     `json:"message"`
+	Link      string     `json:"link"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// Login is a session or a failed login of the user.
+type Login struct {
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	Failed     string     `json:"failed,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// Run builds all pending exports and removes the ones that expired. An
+// export that can't be built is marked as failed so that the user can ask
+// for a new one.
+func (j Job) Run(ctx context.Context, now time.Time) error {
+	if err := j.Users.DeleteExpiredExports(now.Add(-user.ExportTTL)); err != nil {
+		return err
+	}
+
+	exports, err := j.Users.QueryPendingExports()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, ex := range exports {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		usr, err := j.Users.QueryByID(ex.UserID)
+		if err != nil {
+			return err
+		}
+
+		n := notify.Notification{
+			UserID:  usr.ID,
+			Email:   usr.Email,
+			Subject: "Your data export is ready",
+			Message: "The archive of your data is ready for download for the next 7 days.",
+			Link:    "/users/export",
+		}
+		archive, err := j.Build(usr)
+		if err != nil {
+			failed = append(failed, err.Error())
+			n.Subject = "Your data export failed"
+			n.Message = "The archive of your data could not be built. Please try again later."
+		}
+		if err := j.Users.FinishExport(ex.ID, archive, now); err != nil {
+			return err
+		}
+		if err := j.Notifier.Notify(ctx, n); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("building exports: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Build returns a zip archive with the profile, maybes, tags, comments,
+// notifications and logins of a user as JSON files.
+func (j Job) Build(usr user.Info) ([]byte, error) {
+	maybes, err := j.Maybes.QueryAuthored(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := j.Comments.QueryAuthored(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := j.Notifications.QueryAll(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := j.Users.QuerySessions(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := j.Users.QueryFailedLogins(usr.Email, maxLogins)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile(usr)},
+		{"maybes.json", toMaybes(maybes)},
+		{"tags.json", toTags(maybes)},
+		{"comments.json", toComments(comments)},
+		{"notifications.json", toNotifications(notifications)},
+		{"logins.json", toLogins(sessions, attempts)},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", f.name)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, errors.Wrapf(err, "encoding %s", f.name)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "closing archive")
+	}
+	return buf.Bytes(), nil
+}
+
+func profile(usr user.Info) Profile {
+	return Profile{
+		ID:            usr.ID,
+		Name:          usr.Name,
+		Email:         usr.Email,
+		CreatedAt:     usr.DateCreated,
+		VerifiedAt:    usr.VerifiedAt,
+		TwoFactor:     usr.TOTPEnabledAt != nil,
+		DigestEnabled: usr.DigestEnabled,
+		DigestWeekday: usr.DigestWeekday,
+		DigestHour:    usr.DigestHour,
+	}
+}
+
+func toMaybes(maybes maybe.Infos) []Maybe {
+	out := make([]Maybe, 0, len(maybes))
+	for _, m := range maybes {
+		tags := make([]string, 0, len(m.Tags))
+		for _, t := range m.Tags {
+			tags = append(tags, t.Name)
+		}
+		out = append(out, Maybe{
+			ID:           m.ID,
+			WorkspaceID:  m.WorkspaceID,
+			Title:        m.Title,
+			URL:          m.Url,
+			Description:  m.Description,
+			Priority:     m.Priority,
+			Effort:       m.Effort,
+			DueDate:      m.DueDate,
+			SnoozedUntil: m.SnoozedUntil,
+			Tags:         tags,
+			CreatedAt:    m.DateCreated,
+			UpdatedAt:    m.DateUpdated,
+		})
+	}
+	return out
+}
+
+func toTags(maybes maybe.Infos) []Tag {
+	counts := map[string]int{}
+	for _, m := range maybes {
+		for _, t := range m.Tags {
+			counts[t.Name]++
+		}
+	}
+	out := make([]Tag, 0, len(counts))
+	for name, n := range counts {
+		out = append(out, Tag{Name: name, Maybes: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func toComments(comments comment.Infos) []Comment {
+	out := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		out = append(out, Comment{
+			ID:        c.ID,
+			MaybeID:   c.MaybeID,
+			ParentID:  c.ParentID,
+			Body:      c.Body,
+			CreatedAt: c.DateCreated,
+			UpdatedAt: c.DateUpdated,
+		})
+	}
+	return out
+}
+
+func toNotifications(notifications notification.Infos) []Notification {
+	out := make([]Notification, 0, len(notifications))
+	for _, n := range notifications {
+		out = append(out, Notification{
+			Message:   n.Message,
+			Link:      n.Link,
+			ReadAt:    n.ReadAt,
+			CreatedAt: n.DateCreated,
+		})
+	}
+	return out
+}
+
+// toLogins merges the sessions and failed logins of a user, the latest first.
+func toLogins(sessions user.Sessions, attempts []user.LoginAttempt) []Login {
+	out := make([]Login, 0, len(sessions)+len(attempts))
+	for _, s := range sessions {
+		lastSeen := s.LastSeenAt
+		out = append(out, Login{IP: s.IP, UserAgent: s.UserAgent, CreatedAt: s.CreatedAt, LastSeenAt: &lastSeen})
+	}
+	for _, a := range attempts {
+		out = append(out, Login{IP: a.IP, Failed: a.Reason, CreatedAt: a.CreatedAt})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
@@ -0,0 +1,173 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+)
+
+type fakeUsers struct {
+	exports  user.Exports
+	archives map[string][]byte
+	expired  time.Time
+}
+
+func (f *fakeUsers) QueryByID(userID string) (user.Info, error) {
+	return user.Info{ID: userID, Name: "alice", Email: "alice@example.com", PasswordHash: []byte("secret-hash"), TOTPSecret: "SECRET"}, nil
+}
+
+func (f *fakeUsers) QuerySessions(userID string) (user.Sessions, error) {
+	return user.Sessions{{IP: "127.0.0.1", UserAgent: "Firefox", CreatedAt: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}}, nil
+}
+
+func (f *fakeUsers) QueryFailedLogins(email string, limit int) ([]user.LoginAttempt, error) {
+	return []user.LoginAttempt{{Email: email, IP: "10.0.0.1", Reason: user.LoginWrongPassword, CreatedAt: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)}}, nil
+}
+
+func (f *fakeUsers) QueryPendingExports() (user.Exports, error) {
+	return f.exports, nil
+}
+
+func (f *fakeUsers) FinishExport(exportID string, archive []byte, now time.Time) error {
+	f.archives[exportID] = archive
+	return nil
+}
+
+func (f *fakeUsers) DeleteExpiredExports(before time.Time) error {
+	f.expired = before
+	return nil
+}
+
+type fakeMaybes struct{ err error }
+
+func (f fakeMaybes) QueryAuthored(userID string) (maybe.Infos, error) {
+	return maybe.Infos{
+		{ID: "m1", Title: "Read a book", Tags: []maybe.Tag{{Name: "books"}, {Name: "fun"}}},
+		{ID: "m2", Title: "Learn Go", Tags: []maybe.Tag{{Name: "fun"}}},
+	}, f.err
+}
+
+type fakeComments struct{}
+
+func (fakeComments) QueryAuthored(userID string) (comment.Infos, error) {
+	return comment.Infos{{ID: "c1", MaybeID: "m1", Body: "Which one?"}}, nil
+}
+
+type fakeNotifications struct{}
+
+func (fakeNotifications) QueryAll(userID string) (notification.Infos, error) {
+	return notification.Infos{{Message: "Reminder"}}, nil
+}
+
+type fakeNotifier struct{ sent []notify.Notification }
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func TestJobRun(t *testing.T) {
+	now := time.Date(2021, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maybesErr   error
+		wantErr     bool
+		wantSubject string
+	}{
+		{name: "Ready", wantSubject: "Your data export is ready"},
+		{name: "Failed", maybesErr: errors.New("db down"), wantErr: true, wantSubject: "Your data export failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{exports: user.Exports{{ID: "e1", UserID: "u1"}}, archives: map[string][]byte{}}
+			notifier := &fakeNotifier{}
+			j := Job{Users: users, Maybes: fakeMaybes{err: tt.maybesErr}, Comments: fakeComments{}, Notifications: fakeNotifications{}, Notifier: notifier}
+
+			err := j.Run(context.Background(), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+
+			if !users.expired.Equal(now.Add(-user.ExportTTL)) {
+				t.Errorf("want exports before %v deleted; got %v", now.Add(-user.ExportTTL), users.expired)
+			}
+			archive, ok := users.archives["e1"]
+			if !ok {
+				t.Fatal("want export finished")
+			}
+			if (archive == nil) != tt.wantErr {
+				t.Errorf("want archive %v; got %d bytes", !tt.wantErr, len(archive))
+			}
+			if len(notifier.sent) != 1 || notifier.sent[0].Subject != tt.wantSubject || notifier.sent[0].Email != "alice@example.com" {
+				t.Errorf("unexpected notifications %+v", notifier.sent)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	j := Job{Users: &fakeUsers{}, Maybes: fakeMaybes{}, Comments: fakeComments{}, Notifications: fakeNotifications{}}
+	usr, _ := j.Users.QueryByID("u1")
+
+	archive, err := j.Build(usr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	for _, name := range []string{"profile.json", "maybes.json", "tags.json", "comments.json", "notifications.json", "logins.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("want %s in archive", name)
+		}
+	}
+
+	// secrets stay out of the archive
+	for _, secret := range []string{"secret-hash", "SECRET"} {
+		if bytes.Contains(files["profile.json"], []byte(secret)) {
+			t.Errorf("want no %q in profile; got %s", secret, files["profile.json"])
+		}
+	}
+
+	var tags []Tag
+	if err := json.Unmarshal(files["tags.json"], &tags); err != nil {
+		t.Fatal(err)
+	}
+	want := []Tag{{Name: "books", Maybes: 1}, {Name: "fun", Maybes: 2}}
+	if len(tags) != len(want) || tags[0] != want[0] || tags[1] != want[1] {
+		t.Errorf("want tags %v; got %v", want, tags)
+	}
+
+	var logins []Login
+	if err := json.Unmarshal(files["logins.json"], &logins); err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 2 || logins[0].Failed != user.LoginWrongPassword {
+		t.Errorf("want failed login first; got %+v", logins)
+	}
+}
@@ -0,0 +1,269 @@
+// Package fake fills the database with realistic users, maybes and tags to
+// test pagination, search and performance with large datasets.
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password of all generated users.
+const Password = "password"
+
+// batchSize is how many rows are inserted in one transaction.
+const batchSize = 5000
+
+// Options says how much data to generate. The same options generate the same
+// data, so generating it again adds nothing.
+type Options struct {
+	Users  int
+	Maybes int
+	Tags   int
+	// Seed seeds the random generator.
+	Seed int64
+	// End is the time of the newest generated row, the oldest ones are two
+	// years older.
+	End time.Time
+}
+
+// Stats counts the inserted rows. Tags counts the tags in use, including
+// existing ones with the same names.
+type Stats struct {
+	Users     int
+	Maybes    int
+	Tags      int
+	MaybeTags int
+}
+
+// Generate inserts users, maybes and tags in batched transactions. Maybes are
+// spread unevenly across users and tags, like they are in real life: few users
+// have many maybes, and few tags are on many maybes.
+func Generate(ctx context.Context, db *sqlx.DB, opts Options) (Stats, error) {
+	if opts.Users <= 0 && (opts.Maybes > 0 || opts.Tags > 0) {
+		return Stats{}, errors.New("maybes and tags need at least one user")
+	}
+
+	g := generator{rnd: rand.New(rand.NewSource(opts.Seed)), end: opts.End.UTC()}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.DefaultCost)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "generating password hash")
+	}
+
+	var stats Stats
+	userIDs := make([]string, opts.Users)
+	err = batched(ctx, db, opts.Users, `
+	INSERT INTO users
+		(user_id, name, email, handle, password_hash, active, created_at, updated_at, verified_at)
+	VALUES
+		($1, $2, $3, $4, $5, TRUE, $6, $6, $6)
+	ON CONFLICT DO NOTHING
+	`, func(stmt *sqlx.Stmt, i int) error {
+		userIDs[i] = g.uuid()
+		first, last := g.pick(firstNames), g.pick(lastNames)
+		email := fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1)
+		handle := fmt.Sprintf("%s.%s_%d", strings.ToLower(first), strings.ToLower(last), i+1)
+		res, err := stmt.Exec(userIDs[i], first+" "+last, email, handle, hash, g.time().String())
+		stats.Users += affected(res)
+		return err
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "inserting users")
+	}
+
+	// tag names are unique, existing tags with the same name are reused
+	tagIDs := make([]string, opts.Tags)
+	err = batched(ctx, db, opts.Tags, `
+	INSERT INTO tags (tag_id, name) VALUES ($1, $2)
+	ON CONFLICT(name) DO UPDATE SET name = excluded.name
+	RETURNING tag_id
+	`, func(stmt *sqlx.Stmt, i int) error {
+		stats.Tags++
+		return stmt.Get(&tagIDs[i], g.uuid(), tagName(i))
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "inserting tags")
+	}
+
+	type link struct{ tagID, maybeID, userID string }
+	var links []link
+	err = batched(ctx, db, opts.Maybes, `
+	INSERT INTO maybes
+		(maybe_id, user_id, title, url, description, created_at, updated_at, priority, effort)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT DO NOTHING
+	`, func(stmt *sqlx.Stmt, i int) error {
+		id := g.uuid()
+		userID := userIDs[g.skewed(len(userIDs))]
+		title, url, description := g.maybe()
+		created := g.time()
+		updated := created
+		if g.rnd.Intn(4) == 0 {
+			updated = created.Add(time.Duration(g.rnd.Int63n(int64(g.end.Sub(created)) + 1)))
+		}
+
+		for _, t := range g.tags(len(tagIDs)) {
+			links = append(links, link{tagID: tagIDs[t], maybeID: id, userID: userID})
+		}
+		res, err := stmt.Exec(id, userID, title, url, description, created.String(), updated.String(), g.pick(priorities), g.rnd.Intn(6))
+		stats.Maybes += affected(res)
+		return err
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "inserting maybes")
+	}
+
+	err = batched(ctx, db, len(links), `
+	INSERT INTO maybetags (tag_id, maybe_id, user_id) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING
+	`, func(stmt *sqlx.Stmt, i int) error {
+		res, err := stmt.Exec(links[i].tagID, links[i].maybeID, links[i].userID)
+		stats.MaybeTags += affected(res)
+		return err
+	})
+	if err != nil {
+		return stats, errors.Wrap(err, "linking tags")
+	}
+
+	return stats, nil
+}
+
+// batched runs fn for the rows 0 to n with a statement prepared from q,
+// committing every batchSize rows.
+func batched(ctx context.Context, db *sqlx.DB, n int, q string, fn func(stmt *sqlx.Stmt, i int) error) error {
+	for start := 0; start < n; start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return errors.Wrap(err, "beginning transaction")
+		}
+		stmt, err := tx.Preparex(q)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "preparing statement")
+		}
+		for i := start; i < n && i < start+batchSize; i++ {
+			if err := fn(stmt, i); err != nil {
+				stmt.Close()
+				tx.Rollback()
+				return err
+			}
+		}
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return errors.Wrap(err, "committing batch")
+		}
+	}
+	return nil
+}
+
+// affected returns the number of rows a statement inserted.
+func affected(res sql.Result) int {
+	if res == nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
+}
+
+// generator makes up data from a seeded random generator.
+type generator struct {
+	rnd *rand.Rand
+	end time.Time
+}
+
+func (g generator) uuid() string {
+	id, _ := uuid.NewRandomFromReader(g.rnd)
+	return id.String()
+}
+
+func (g generator) pick(words []string) string {
+	return words[g.rnd.Intn(len(words))]
+}
+
+// time returns a time in the two years before end. Its String method formats
+// it like the times the app writes.
+func (g generator) time() time.Time {
+	const span = int64(2 * 365 * 24 * time.Hour)
+	return g.end.Add(-time.Duration(g.rnd.Int63n(span))).Truncate(time.Microsecond)
+}
+
+// skewed returns an index below n, the lower ones much more likely.
+func (g generator) skewed(n int) int {
+	return int(math.Pow(g.rnd.Float64(), 2) * float64(n))
+}
+
+// tags returns the indexes of up to four different tags below n.
+func (g generator) tags(n int) []int {
+	if n == 0 {
+		return nil
+	}
+
+	var tags []int
+	seen := make(map[int]bool)
+	for k := g.rnd.Intn(5); k > 0; k-- {
+		t := g.skewed(n)
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func (g generator) maybe() (title, url, description string) {
+	kind := kinds[g.rnd.Intn(len(kinds))]
+	subject := g.pick(adjectives) + " " + g.pick(subjects)
+	title = fmt.Sprintf(kind.title, subject)
+	url = fmt.Sprintf("https://%s/%s-%d", g.pick(kind.sites), strings.ReplaceAll(strings.ToLower(subject), " ", "-"), g.rnd.Intn(100000))
+	if d := g.pick(descriptions); d != "" {
+		description = fmt.Sprintf(d, strings.ToLower(subject))
+	}
+	return title, url, description
+}
+
+// tagName returns the name of the i-th tag, numbered once the words run out.
+func tagName(i int) string {
+	if i < len(tagWords) {
+		return tagWords[i]
+	}
+	return fmt.Sprintf("%s-%d", tagWords[i%len(tagWords)], i/len(tagWords)+1)
+}
+
+var kinds = []struct {
+	title string
+	sites []string
+}{
+	{"Read %s", []string{"www.manning.com/books", "www.oreilly.com/library", "en.wikipedia.org/wiki"}},
+	{"Watch %s", []string{"www.youtube.com/watch", "vimeo.com", "www.netflix.com/title"}},
+	{"Listen to %s", []string{"open.spotify.com/episode", "podcasts.apple.com/podcast"}},
+	{"Try %s", []string{"github.com", "www.producthunt.com/posts"}},
+	{"Learn %s", []string{"www.coursera.org/learn", "go.dev/doc", "developer.mozilla.org/docs"}},
+	{"Visit %s", []string{"www.tripadvisor.com/attraction", "maps.example.com/place"}},
+	{"Cook %s", []string{"www.bbcgoodfood.com/recipes", "cooking.nytimes.com/recipes"}},
+}
+
+var (
+	firstNames   = []string{"Ada", "Alan", "Barbara", "Brian", "Claude", "Dennis", "Edsger", "Frances", "Grace", "Hedy", "Ivan", "Joan", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Sophie", "Tim"}
+	lastNames    = []string{"Allen", "Berners-Lee", "Dijkstra", "Hamilton", "Hopper", "Kernighan", "Lamarr", "Liskov", "Lovelace", "Perlman", "Pike", "Ritchie", "Shannon", "Sutherland", "Thompson", "Torvalds", "Turing", "Wirth"}
+	adjectives   = []string{"Advanced", "Beginner's", "Concurrent", "Distributed", "Functional", "Hidden", "Modern", "Practical", "Quiet", "Slow", "Small", "Spicy", "Weekend", "Wild"}
+	subjects     = []string{"Algorithms", "Baking", "Cities", "Databases", "Gardening", "Go", "Hiking", "Jazz", "Mountains", "Networking", "Pasta", "Photography", "Rust", "Systems", "Typography"}
+	descriptions = []string{"a friend recommended this one about %s", "everyone keeps talking about %s", "might be useful for work: %s", "for a rainy sunday, %s", "saw this on the train, something about %s", ""}
+	priorities   = []string{"", "", maybe.PriorityLow, maybe.PriorityMedium, maybe.PriorityHigh}
+	tagWords     = []string{"books", "go", "watchlist", "music", "podcasts", "travel", "food", "work", "learning", "weekend", "tools", "science", "history", "design", "health", "games", "movies", "articles", "courses", "ideas"}
+)
@@ -0,0 +1,72 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/datatest"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+)
+
+func TestGenerate(t *testing.T) {
+	opts := Options{Users: 20, Maybes: 300, Tags: 25, Seed: 42, End: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	var titles [2][]string
+	for i := range titles {
+		db := datatest.NewDB(t)
+		// the seeded tags are reused
+		if err := schema.Seed(db); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := Generate(context.Background(), db, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Users != 20 || stats.Maybes != 300 || stats.Tags != 25 || stats.MaybeTags == 0 {
+			t.Errorf("want 20 users, 300 maybes and 25 tags; got %+v", stats)
+		}
+		if err := db.Select(&titles[i], `SELECT title FROM maybes ORDER BY maybe_id`); err != nil {
+			t.Fatal(err)
+		}
+
+		var tags, outside int
+		db.Get(&tags, `SELECT COUNT(*) FROM tags`)
+		db.Get(&outside, `
+		SELECT COUNT(*) FROM maybes AS m JOIN users AS u ON u.user_id = m.user_id
+		WHERE u.email LIKE '%@example.com' AND (m.created_at < $1 OR m.updated_at > $2)
+		`, opts.End.AddDate(-2, 0, -1).String(), opts.End.String())
+		if tags != 25 || outside != 0 {
+			t.Errorf("want 25 tags and maybes of the two years before end; got %d tags and %d maybes outside", tags, outside)
+		}
+
+		// generating again adds nothing
+		stats, err = Generate(context.Background(), db, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Users != 0 || stats.Maybes != 0 || stats.MaybeTags != 0 {
+			t.Errorf("want nothing added again; got %+v", stats)
+		}
+
+		// generated users can log in
+		users, err := user.New(db).QueryAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := user.New(db).Authenticate(users[len(users)-1].Email, Password); err != nil {
+			t.Errorf("want generated user to log in; got %v", err)
+		}
+	}
+
+	if len(titles[0]) != 302 || len(titles[0]) != len(titles[1]) {
+		t.Fatalf("want the same maybes from the same seed; got %d and %d", len(titles[0]), len(titles[1]))
+	}
+	for i := range titles[0] {
+		if titles[0][i] != titles[1][i] {
+			t.Fatalf("want the same maybes from the same seed; got %q and %q", titles[0][i], titles[1][i])
+		}
+	}
+}
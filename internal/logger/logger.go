@@ -0,0 +1,84 @@
+// Package logger sets up structured logging with log/slog. Attributes added
+// to a context are logged with every line logged with that context, which
+// ties the lines of a request together.
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Formats of the log lines.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger that writes lines at the level and above to w.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch format {
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, errors.Errorf("unknown log format %q", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+type contextKey struct{}
+
+// fields are the attributes of a context. They are shared by the contexts
+// derived from it, so that attributes added deep in a request, like the user
+// ID, also show up in the line logged once the request is done.
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// NewContext returns a context that carries attributes for log lines.
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, contextKey{}, &fields{attrs: attrs})
+}
+
+// Add adds attributes to the lines logged with a context made by NewContext
+// and all contexts derived from it. It does nothing for other contexts.
+func Add(ctx context.Context, attrs ...slog.Attr) {
+	f, ok := ctx.Value(contextKey{}).(*fields)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attrs = append(f.attrs, attrs...)
+}
+
+// contextHandler adds the attributes of the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, ok := ctx.Value(contextKey{}).(*fields); ok {
+		f.mu.Lock()
+		r.AddAttrs(f.attrs...)
+		f.mu.Unlock()
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
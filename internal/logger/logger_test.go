@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := NewContext(context.Background(), slog.String("request_id", "abc"))
+	// attributes added to a derived context show up in the parent as well
+	type key struct{}
+	Add(context.WithValue(ctx, key{}, true), slog.String("user_id", "u1"))
+	log.With("component", "test").InfoContext(ctx, "done", "status", 200)
+	log.DebugContext(ctx, "hidden")
+	log.InfoContext(context.Background(), "plain")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"msg": "done", "component": "test", "status": float64(200), "request_id": "abc", "user_id": "u1"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("got %s = %v, want %v", k, got[k], v)
+		}
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("line without request context has a request ID: %s", lines[1])
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
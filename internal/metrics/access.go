@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net"
+	"strings"
+)
+
+// Access decides who may scrape the metrics: clients from one of Networks,
+// or clients that send Token as a bearer token. A nil Access allows nobody.
+type Access struct {
+	Networks []*net.IPNet
+	Token    string
+}
+
+// Allows reports whether a client with the IP address and the Authorization
+// header of a request may scrape the metrics.
+func (a *Access) Allows(clientIP, authorization string) bool {
+	if a == nil {
+		return false
+	}
+	if a.Token != "" {
+		token, ok := strings.CutPrefix(authorization, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1 {
+			return true
+		}
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, n := range a.Networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
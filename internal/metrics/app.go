@@ -0,0 +1,197 @@
+// Package metrics collects the metrics of the web app with the Prometheus
+// client library and exposes them for scraping.
+package metrics
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of a login for the logins counter.
+const (
+	LoginSuccess       = "success"
+	LoginWrongPassword = "wrong_password"
+	LoginThrottled     = "throttled"
+	LoginUnverified    = "unverified"
+	LoginWrongCode     = "wrong_code"
+)
+
+// App holds the metrics of the web app. Its methods do nothing on a nil App,
+// so handlers and tests don't have to set one up.
+type App struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	renderDuration  *prometheus.HistogramVec
+	logins          *prometheus.CounterVec
+}
+
+// NewApp creates the metrics of the web app in a new registry, together with
+// the metrics of the Go runtime and the process.
+func NewApp() *App {
+	a := &App{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "maybelist_http_requests_total",
+			Help: "Number of HTTP requests by route pattern and status code.",
+		}, []string{"route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "maybelist_http_request_duration_seconds",
+			Help: "Latency of HTTP requests by route pattern and status code.",
+		}, []string{"route", "status"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "maybelist_template_render_duration_seconds",
+			Help: "Time to render HTML templates by template.",
+		}, []string{"template"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "maybelist_logins_total",
+			Help: "Number of logins by result.",
+		}, []string{"result"}),
+	}
+	a.registry.MustRegister(
+		a.requests, a.requestDuration, a.renderDuration, a.logins,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return a
+}
+
+// ObserveRequest counts a request to a route pattern and its latency.
+func (a *App) ObserveRequest(route string, status int, d time.Duration) {
+	if a == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	a.requests.WithLabelValues(route, code).Inc()
+	a.requestDuration.WithLabelValues(route, code).Observe(d.Seconds())
+}
+
+// ObserveRender records the time it took to render a template.
+func (a *App) ObserveRender(tmpl string, d time.Duration) {
+	if a == nil {
+		return
+	}
+	a.renderDuration.WithLabelValues(tmpl).Observe(d.Seconds())
+}
+
+// CountLogin counts a login with one of the Login results.
+func (a *App) CountLogin(result string) {
+	if a == nil {
+		return
+	}
+	a.logins.WithLabelValues(result).Inc()
+}
+
+// Handler exposes the metrics in the format the scraper asks for, none on a
+// nil App. Metrics that fail to be collected are logged and left out, the
+// others are still exposed.
+func (a *App) Handler(log *slog.Logger) http.Handler {
+	if a == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(a.registry, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(log.Handler(), slog.LevelWarn),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// RegisterDB adds the connection pool stats of db.
+func (a *App) RegisterDB(db *sql.DB) {
+	if a == nil {
+		return
+	}
+	gauge := func(name, help string, fn func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help},
+			func() float64 { return fn(db.Stats()) })
+	}
+	counter := func(name, help string, fn func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help},
+			func() float64 { return fn(db.Stats()) })
+	}
+	a.registry.MustRegister(
+		gauge("maybelist_db_open_connections", "Number of open database connections.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("maybelist_db_in_use_connections", "Number of database connections in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("maybelist_db_idle_connections", "Number of idle database connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		gauge("maybelist_db_max_open_connections", "Maximum number of open database connections.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		counter("maybelist_db_wait_count_total", "Number of times a database connection was waited for.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("maybelist_db_wait_duration_seconds_total", "Time spent waiting for database connections.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
+
+// Counts are the numbers of users and their content.
+type Counts struct {
+	Users       int
+	ActiveUsers int
+	Maybes      int
+	Tags        int
+	Comments    int
+	Workspaces  int
+}
+
+// RegisterCounts adds gauges for the counts that fn returns. fn is called on
+// every scrape, so the counts can come straight from the database.
+func (a *App) RegisterCounts(fn func() (Counts, error)) {
+	if a == nil {
+		return
+	}
+	a.registry.MustRegister(countsCollector{
+		fn:         fn,
+		users:      prometheus.NewDesc("maybelist_users", "Number of users.", nil, nil),
+		active:     prometheus.NewDesc("maybelist_users_active", "Number of users who are not deactivated.", nil, nil),
+		maybes:     prometheus.NewDesc("maybelist_maybes", "Number of maybes.", nil, nil),
+		tags:       prometheus.NewDesc("maybelist_tags", "Number of tags.", nil, nil),
+		comments:   prometheus.NewDesc("maybelist_comments", "Number of comments.", nil, nil),
+		workspaces: prometheus.NewDesc("maybelist_workspaces", "Number of workspaces.", nil, nil),
+	})
+}
+
+// countsCollector reads all counts at once when it is collected.
+type countsCollector struct {
+	fn                                                func() (Counts, error)
+	users, active, maybes, tags, comments, workspaces *prometheus.Desc
+}
+
+func (c countsCollector) descs() []*prometheus.Desc {
+	return []*prometheus.Desc{c.users, c.active, c.maybes, c.tags, c.comments, c.workspaces}
+}
+
+// Describe implements prometheus.Collector.
+func (c countsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs() {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c countsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.fn()
+	if err != nil {
+		for _, d := range c.descs() {
+			ch <- prometheus.NewInvalidMetric(d, err)
+		}
+		return
+	}
+	for d, v := range map[*prometheus.Desc]int{
+		c.users:      counts.Users,
+		c.active:     counts.ActiveUsers,
+		c.maybes:     counts.Maybes,
+		c.tags:       counts.Tags,
+		c.comments:   counts.Comments,
+		c.workspaces: counts.Workspaces,
+	} {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v))
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metrics of the app in the text format.
+func scrape(t *testing.T, a *App) (int, string) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Handler(slog.New(slog.NewTextHandler(io.Discard, nil))).ServeHTTP(rr, r)
+	return rr.Code, rr.Body.String()
+}
+
+func TestApp(t *testing.T) {
+	a := NewApp()
+	a.ObserveRequest("GET /{$}", 200, 50*time.Millisecond)
+	a.ObserveRequest("GET /{$}", 200, 3*time.Second)
+	a.ObserveRender(`say "hi"`+"\n", time.Millisecond)
+	a.CountLogin(LoginSuccess)
+	a.CountLogin(LoginSuccess)
+	a.RegisterCounts(func() (Counts, error) {
+		return Counts{Users: 3, ActiveUsers: 2, Maybes: 42}, nil
+	})
+
+	code, body := scrape(t, a)
+
+	if code != http.StatusOK {
+		t.Errorf("want status %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{
+		"# TYPE maybelist_http_requests_total counter",
+		`maybelist_http_requests_total{route="GET /{$}",status="200"} 2`,
+		`maybelist_http_request_duration_seconds_bucket{route="GET /{$}",status="200",le="0.1"} 1`,
+		`maybelist_http_request_duration_seconds_count{route="GET /{$}",status="200"} 2`,
+		`maybelist_template_render_duration_seconds_count{template="say \"hi\"\n"} 1`,
+		`maybelist_logins_total{result="success"} 2`,
+		"# TYPE maybelist_users gauge",
+		"maybelist_users 3",
+		"maybelist_users_active 2",
+		"maybelist_maybes 42",
+		"maybelist_workspaces 0",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %q in\n%s", want, body)
+		}
+	}
+}
+
+func TestCountsError(t *testing.T) {
+	a := NewApp()
+	a.CountLogin(LoginThrottled)
+	a.RegisterCounts(func() (Counts, error) { return Counts{}, errors.New("db down") })
+
+	code, body := scrape(t, a)
+
+	if code != http.StatusOK {
+		t.Errorf("want status %d; got %d", http.StatusOK, code)
+	}
+	if strings.Contains(body, "maybelist_users") {
+		t.Errorf("want no counts, got\n%s", body)
+	}
+	if !strings.Contains(body, `maybelist_logins_total{result="throttled"} 1`) {
+		t.Errorf("want the other metrics exposed, got\n%s", body)
+	}
+}
+
+func TestNilApp(t *testing.T) {
+	var a *App
+	a.ObserveRequest("GET /{$}", 200, time.Second)
+	a.ObserveRender("home.page.tmpl", time.Second)
+	a.CountLogin(LoginSuccess)
+	a.RegisterCounts(func() (Counts, error) { return Counts{}, nil })
+	if code, _ := scrape(t, a); code != http.StatusNotFound {
+		t.Errorf("want status %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"127.0.0.1/32", "::1/128", "10.0.0.0/8"} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		networks = append(networks, n)
+	}
+	a := &Access{Networks: networks, Token: "s3cret"}
+
+	tests := []struct {
+		ip, authorization string
+		want              bool
+	}{
+		{"127.0.0.1", "", true},
+		{"::1", "", true},
+		{"10.1.2.3", "", true},
+		{"192.168.1.1", "", false},
+		{"192.168.1.1", "Bearer s3cret", true},
+		{"192.168.1.1", "Bearer wrong", false},
+		{"192.168.1.1", "s3cret", false},
+		{"not an ip", "", false},
+	}
+	for _, tt := range tests {
+		if got := a.Allows(tt.ip, tt.authorization); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %t, want %t", tt.ip, tt.authorization, got, tt.want)
+		}
+	}
+
+	if (&Access{Networks: networks}).Allows("192.168.1.1", "Bearer ") {
+		t.Error("want an empty token to allow nobody")
+	}
+	var nobody *Access
+	if nobody.Allows("127.0.0.1", "") {
+		t.Error("want a nil Access to allow nobody")
+	}
+}
@@ -0,0 +1,114 @@
+// Package notify delivers notifications to users through the in-app inbox,
+// email or webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+)
+
+// Notification is a message for a user. Link is a path within the app.
+type Notification struct {
+	UserID  string `json:"user_id"`
+	Email   string `json:"-"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+	Link    string `json:"link"`
+}
+
+// Notifier delivers notifications through a channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Inbox delivers notifications to the in-app inbox.
+type Inbox struct {
+	Repo interface {
+		Create(nn notification.NewNotification) (notification.Info, error)
+	}
+}
+
+// Notify adds the notification to the inbox of the user.
+func (i Inbox) Notify(ctx context.Context, n Notification) error {
+	_, err := i.Repo.Create(notification.NewNotification{UserID: n.UserID, Message: n.Message, Link: n.Link})
+	return err
+}
+
+// Email delivers notifications by email. BaseURL is prepended to links.
+type Email struct {
+	Sender  mail.Sender
+	BaseURL string
+}
+
+// Notify sends the notification to the email address of the user.
+func (e Email) Notify(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return errors.Errorf("no email address for user %q", n.UserID)
+	}
+	text := n.Message
+	if n.Link != "" {
+		text += "\n\n" + strings.TrimSuffix(e.BaseURL, "/") + n.Link
+	}
+	return e.Sender.Send(mail.Message{To: n.Email, Subject: n.Subject, Text: text})
+}
+
+// Webhook delivers notifications as JSON in a POST request to a URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify posts the notification to the webhook.
+func (wh Webhook) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return errors.Wrap(err, "encoding notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := wh.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "calling webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Multi delivers notifications through all of its notifiers. A failing
+// notifier does not keep the others from delivering.
+type Multi []Notifier
+
+// Notify delivers the notification through all notifiers and reports all failures.
+func (m Multi) Notify(ctx context.Context, n Notification) error {
+	var failed []string
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			failed = append(failed, fmt.Sprintf("%T: %v", notifier, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("delivering notification: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package reminder delivers due reminders for maybes.
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+)
+
+// Job delivers due reminders through a notifier.
+type Job struct {
+	Maybes interface {
+		QueryDueReminders(now time.Time) (maybe.Reminders, error)
+		DeleteReminder(rm maybe.Reminder) error
+	}
+	Notifier notify.Notifier
+}
+
+// Run delivers all reminders due at the given time. Reminders are delivered
+// at most once: they are removed even if a channel fails, so that a broken
+// mail server does not flood the inbox with the same reminder.
+func (j Job) Run(ctx context.Context, now time.Time) error {
+	reminders, err := j.Maybes.QueryDueReminders(now)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, rm := range reminders {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n := notify.Notification{
+			UserID:  rm.UserID,
+			Email:   rm.Email,
+			Subject: fmt.Sprintf("Reminder: %s", rm.Title),
+			Message: fmt.Sprintf("Hi %s, you asked to be reminded of %q.", rm.Name, rm.Title),
+			Link:    fmt.Sprintf("/maybes/view/%s", rm.MaybeID),
+		}
+		if err := j.Notifier.Notify(ctx, n); err != nil {
+			failed = append(failed, err.Error())
+		}
+
+		if err := j.Maybes.DeleteReminder(rm); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("delivering %d of %d reminders: %s", len(failed), len(reminders), strings.Join(failed, "; "))
+	}
+	return nil
+}
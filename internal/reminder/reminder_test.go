@@ -0,0 +1,83 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+)
+
+type fakeMaybes struct {
+	reminders maybe.Reminders
+	deleted   []string
+}
+
+func (f *fakeMaybes) QueryDueReminders(now time.Time) (maybe.Reminders, error) {
+	var due maybe.Reminders
+	for _, rm := range f.reminders {
+		if !rm.RemindAt.After(now) {
+			due = append(due, rm)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeMaybes) DeleteReminder(rm maybe.Reminder) error {
+	f.deleted = append(f.deleted, rm.MaybeID)
+	return nil
+}
+
+type fakeNotifier struct {
+	sent []notify.Notification
+	err  error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	f.sent = append(f.sent, n)
+	return f.err
+}
+
+func TestJobRun(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	reminders := maybe.Reminders{
+		{MaybeID: "due", Title: "Read a book", UserID: "u1", Name: "alice", Email: "alice@example.com", RemindAt: now.Add(-time.Minute)},
+		{MaybeID: "later", Title: "Learn Go", UserID: "u1", Name: "alice", Email: "alice@example.com", RemindAt: now.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "Delivered"},
+		{name: "Failed channel", err: errors.New("smtp down"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maybes := &fakeMaybes{reminders: reminders}
+			notifier := &fakeNotifier{err: tt.err}
+
+			err := Job{Maybes: maybes, Notifier: notifier}.Run(context.Background(), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+
+			if len(notifier.sent) != 1 {
+				t.Fatalf("want 1 notification; got %d", len(notifier.sent))
+			}
+			n := notifier.sent[0]
+			if n.Email != "alice@example.com" || n.Link != "/maybes/view/due" || n.Subject != "Reminder: Read a book" {
+				t.Errorf("unexpected notification %+v", n)
+			}
+
+			// reminders are delivered at most once
+			if len(maybes.deleted) != 1 || maybes.deleted[0] != "due" {
+				t.Errorf("want due reminder deleted; got %v", maybes.deleted)
+			}
+		})
+	}
+}
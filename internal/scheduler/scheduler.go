@@ -0,0 +1,77 @@
+// Package scheduler runs background jobs in fixed intervals.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Clock tells the time and waits. Tests replace it with a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the clock of the system.
+type RealClock struct{}
+
+// Now returns the current time.
+func (RealClock) Now() time.Time { return time.Now() }
+
+// After waits for the duration to elapse.
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Job is a unit of background work, now is the time of the current run.
+type Job func(ctx context.Context, now time.Time) error
+
+type namedJob struct {
+	name string
+	job  Job
+}
+
+// Scheduler runs its jobs every interval until its context is done.
+type Scheduler struct {
+	clock    Clock
+	interval time.Duration
+	log      *slog.Logger
+	jobs     []namedJob
+}
+
+// New creates a new scheduler.
+func New(clock Clock, interval time.Duration, log *slog.Logger) *Scheduler {
+	return &Scheduler{clock: clock, interval: interval, log: log}
+}
+
+// Add registers a job with a name for logging.
+func (s *Scheduler) Add(name string, job Job) {
+	s.jobs = append(s.jobs, namedJob{name: name, job: job})
+}
+
+// Run runs all jobs right away and then after every interval. It blocks
+// until the context is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// RunOnce runs all jobs one after another. Failing jobs are logged and do not
+// keep the other jobs from running.
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	now := s.clock.Now().UTC()
+	for _, j := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := j.job(ctx, now); err != nil {
+			s.log.ErrorContext(ctx, "scheduler job failed", "job", j.name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when the test advances it.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+	waiting chan struct{}
+}
+
+type waiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiting: make(chan struct{}, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{until: c.now.Add(d), c: ch})
+	c.waiting <- struct{}{}
+	return ch
+}
+
+// Advance moves the clock forward and wakes up everyone waiting until then.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var waiting []waiter
+	for _, w := range c.waiters {
+		if !c.now.Before(w.until) {
+			w.c <- c.now
+		} else {
+			waiting = append(waiting, w)
+		}
+	}
+	c.waiters = waiting
+}
+
+func TestSchedulerRun(t *testing.T) {
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+
+	runs := make(chan time.Time, 10)
+	s := New(clock, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.Add("record", func(ctx context.Context, now time.Time) error {
+		runs <- now
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// the first run starts right away
+	if got := <-runs; !got.Equal(start) {
+		t.Errorf("want first run at %v; got %v", start, got)
+	}
+
+	<-clock.waiting
+	clock.Advance(30 * time.Minute)
+	select {
+	case got := <-runs:
+		t.Fatalf("want no run before the interval; got run at %v", got)
+	default:
+	}
+
+	clock.Advance(30 * time.Minute)
+	if got, want := <-runs, start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("want second run at %v; got %v", want, got)
+	}
+
+	<-clock.waiting
+	cancel()
+	<-done
+}
+
+func TestSchedulerRunOnceKeepsGoing(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	s := New(clock, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var ran []string
+	s.Add("failing", func(ctx context.Context, now time.Time) error {
+		ran = append(ran, "failing")
+		return context.DeadlineExceeded
+	})
+	s.Add("next", func(ctx context.Context, now time.Time) error {
+		ran = append(ran, "next")
+		return nil
+	})
+
+	s.RunOnce(context.Background())
+
+	if len(ran) != 2 || ran[1] != "next" {
+		t.Errorf("want both jobs to run; got %v", ran)
+	}
+}
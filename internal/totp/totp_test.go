@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// Test vectors for SHA1 from RFC 6238, appendix B.
+func TestHOTPRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got := hotp(key, uint64(Step(time.Unix(tt.unix, 0))), 8)
+		if got != tt.want {
+			t.Errorf("time %d: want %s; got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "050471" {
+		t.Fatalf("want code 050471; got %s", code)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		now      time.Time
+		lastStep int64
+		want     bool
+	}{
+		{"Current period", code, now, 0, true},
+		{"With spaces", "050 471", now, 0, true},
+		{"Previous period", code, now.Add(Period), 0, true},
+		{"Too old", code, now.Add(2 * Period), 0, false},
+		{"Already used", code, now, Step(now), false},
+		{"Wrong code", "123456", now, 0, false},
+		{"Too short", "12345", now, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(secret, tt.code, tt.now, tt.lastStep)
+			if ok != tt.want {
+				t.Fatalf("want %t; got %t", tt.want, ok)
+			}
+			if ok && step != Step(now) {
+				t.Errorf("want step %d; got %d", Step(now), step)
+			}
+		})
+	}
+}
+
+func TestIsCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"050471":    true,
+		"050 471":   true,
+		"05047":     false,
+		"0504711":   false,
+		"abcd-efgh": false,
+		"23456a":    false,
+		"２３４５６７":    false,
+	} {
+		if got := IsCode(code); got != want {
+			t.Errorf("%q: want %t; got %t", code, want, got)
+		}
+	}
+}
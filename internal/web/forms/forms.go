@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -92,6 +94,32 @@ func (f *Form) ValidUrl(field string) {
 	}
 }
 
+// DateLayout is the layout of dates in forms, as sent by date inputs.
+const DateLayout = "2006-01-02"
+
+// ValidDate checks that an optional field contains a date in DateLayout.
+func (f *Form) ValidDate(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if _, err := time.Parse(DateLayout, value); err != nil {
+		f.Errors.Add(field, "Invalid date")
+	}
+}
+
+// IntegerRange checks that an optional field contains a whole number between min and max.
+func (f *Form) IntegerRange(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be a number between %d and %d", min, max))
+	}
+}
+
 // IsEqual checks if two string input fields are equal.
 func (f *Form) IsEqualString(field1 string, field2 string) {
 	string1 := f.Get(field1)
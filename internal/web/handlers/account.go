@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+func (ug userGroup) deleteAccountForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	td := &data.TemplateData{User: &usr, Form: forms.New(nil), Confirmed: hasConfirmed(e, r, userID)}
+	return web.Render(e, w, r, "delete.page.tmpl", td, http.StatusOK)
+}
+
+func (ug userGroup) deleteAccount(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	form := forms.New(r.PostForm)
+	ok, err := ug.confirmUser(e, w, r, usr, form)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return web.Render(e, w, r, "delete.page.tmpl", &data.TemplateData{User: &usr, Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	deleteAt, err := ug.user.ScheduleDeletion(userID, time.Now())
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	n := notify.Notification{
+		UserID:  usr.ID,
+		Email:   usr.Email,
+		Subject: "Your account will be deleted",
+		Message: fmt.Sprintf("Your account and all your maybes, including those in shared workspaces, will be deleted on %s. To keep them, log in and cancel the deletion on your profile before then.", deleteAt.Format("02 Jan 2006 at 15:04 UTC")),
+		Link:    "/users/profile",
+	}
+	if err := ug.notifier.Notify(r.Context(), n); err != nil {
+		e.Log.ErrorContext(r.Context(), "notifying user about deletion", "user_id", usr.ID, "err", err)
+	}
+
+	// scheduling the deletion logged out all sessions
+	e.Session.Remove(r.Context(), "authenticatedUserID")
+	e.Session.Remove(r.Context(), "sessionID")
+	e.Session.Put(r.Context(), "flash", fmt.Sprintf("Your account will be deleted in %d days. To keep it, log in and cancel the deletion on your profile.", int(user.DeletionGrace.Hours()/24)))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+func (ug userGroup) cancelDeletion(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ug.user.CancelDeletion(userID); err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+
+	e.Session.Put(r.Context(), "flash", "Your account will not be deleted.")
+	http.Redirect(w, r, "/users/profile", http.StatusSeeOther)
+	return nil
+}
+
+func (ug userGroup) exports(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	exports, err := ug.user.QueryExports(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+
+	return web.Render(e, w, r, "exports.page.tmpl", &data.TemplateData{Exports: exports}, http.StatusOK)
+}
+
+func (ug userGroup) requestExport(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if _, err := ug.user.RequestExport(userID, time.Now()); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrExportPending:
+			e.Session.Put(r.Context(), "flash", "Your data is already being exported.")
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	} else {
+		e.Session.Put(r.Context(), "flash", "Your data is being exported. We'll let you know when it's ready.")
+	}
+
+	http.Redirect(w, r, "/users/export", http.StatusSeeOther)
+	return nil
+}
+
+func (ug userGroup) downloadExport(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	archive, err := ug.user.QueryExportArchive(id, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidID, user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", id)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="maybe-list-export.zip"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Write(archive)
+	return nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/setting"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/stats"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+// Weekly counts and top lists on the stats page.
+const (
+	statsWeeks = 12
+	statsLimit = 10
+)
+
+// Lengths of the lists in the admin area.
+const (
+	recentLimit = 10
+	loginsLimit = 20
+	usersLimit  = 50
+)
+
+type adminGroup struct {
+	user interface {
+		QueryByID(userID string) (user.Info, error)
+		QueryRecent(limit int) (user.Infos, error)
+		Search(query string, limit, offset int) (user.Infos, error)
+		QueryFailedLogins(email string, limit int) ([]user.LoginAttempt, error)
+		QueryRecentFailedLogins(limit int) ([]user.LoginAttempt, error)
+		SetActive(userID string, active bool) error
+	}
+	settings interface {
+		Query() (setting.Settings, error)
+		Update(s setting.Settings, now time.Time) error
+	}
+	stats interface {
+		Query(now time.Time, weeks, limit int) (stats.Info, error)
+	}
+}
+
+// getDashboard shows the recent signups, the recent failed logins and the
+// instance settings.
+func (ag adminGroup) getDashboard(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	settings, err := ag.settings.Query()
+	if err != nil {
+		return err
+	}
+	return ag.dashboard(e, w, r, settings, forms.New(nil), http.StatusOK)
+}
+
+// dashboard renders the admin dashboard with settings that may not be saved
+// yet and the errors of the settings form.
+func (ag adminGroup) dashboard(e *env.Env, w http.ResponseWriter, r *http.Request, settings setting.Settings, form *forms.Form, status int) error {
+	users, err := ag.user.QueryRecent(recentLimit)
+	if err != nil {
+		return err
+	}
+	logins, err := ag.user.QueryRecentFailedLogins(loginsLimit)
+	if err != nil {
+		return err
+	}
+
+	return web.Render(e, w, r, "admin.page.tmpl", &data.TemplateData{Users: users, LoginAttempts: logins, Settings: &settings, Form: form}, status)
+}
+
+// getUsers shows a page of the users matching the search query q.
+func (ag adminGroup) getUsers(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query().Get("q")
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// one more user than fits on the page tells if there is a next page
+	users, err := ag.user.Search(query, usersLimit+1, (page-1)*usersLimit)
+	if err != nil {
+		return err
+	}
+	td := &data.TemplateData{Users: users, Query: query, PrevPage: page - 1}
+	if len(users) > usersLimit {
+		td.Users = users[:usersLimit]
+		td.NextPage = page + 1
+	}
+
+	return web.Render(e, w, r, "admin_users.page.tmpl", td, http.StatusOK)
+}
+
+// getUser shows a user and their latest failed logins.
+func (ag adminGroup) getUser(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	usr, err := ag.queryUser(r)
+	if err != nil {
+		return err
+	}
+	logins, err := ag.user.QueryFailedLogins(usr.Email, loginsLimit)
+	if err != nil {
+		return err
+	}
+
+	return web.Render(e, w, r, "admin_user.page.tmpl", &data.TemplateData{User: &usr, LoginAttempts: logins}, http.StatusOK)
+}
+
+// deactivateUser stops a user from logging in and logs them out.
+func (ag adminGroup) deactivateUser(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return ag.setActive(e, w, r, false)
+}
+
+// reactivateUser lets a deactivated user log in again.
+func (ag adminGroup) reactivateUser(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return ag.setActive(e, w, r, true)
+}
+
+func (ag adminGroup) setActive(e *env.Env, w http.ResponseWriter, r *http.Request, active bool) error {
+	usr, err := ag.queryUser(r)
+	if err != nil {
+		return err
+	}
+
+	link := "/admin/users/" + usr.ID
+	if usr.ID == e.Session.GetString(r.Context(), "authenticatedUserID") {
+		e.Session.Put(r.Context(), "flash", "You can't deactivate your own account.")
+		http.Redirect(w, r, link, http.StatusSeeOther)
+		return nil
+	}
+
+	if err := ag.user.SetActive(usr.ID, active); err != nil {
+		return errors.Wrapf(err, "setting user %s active to %t", usr.ID, active)
+	}
+
+	if active {
+		e.Session.Put(r.Context(), "flash", usr.Email+" can log in again.")
+	} else {
+		e.Session.Put(r.Context(), "flash", usr.Email+" was deactivated and logged out.")
+	}
+	http.Redirect(w, r, link, http.StatusSeeOther)
+	return nil
+}
+
+// updateSettings saves the instance settings.
+func (ag adminGroup) updateSettings(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("registration", "invite_quota")
+	form.PermittedValues("registration", setting.RegistrationModes...)
+	settings := setting.Settings{
+		Registration:   form.Get("registration"),
+		AllowedDomains: setting.ParseDomains(form.Get("allowed_domains")),
+	}
+	if form.Get("invite_quota") != "" {
+		quota, err := strconv.Atoi(form.Get("invite_quota"))
+		if err != nil || quota < 0 {
+			form.Errors.Add("invite_quota", "Please enter a number of 0 or more")
+		}
+		settings.InviteQuota = quota
+	}
+	if settings.DomainOnly() && len(settings.AllowedDomains) == 0 {
+		form.Errors.Add("allowed_domains", "Please enter at least one domain")
+	}
+	if !form.Valid() {
+		return ag.dashboard(e, w, r, settings, form, http.StatusUnprocessableEntity)
+	}
+
+	if err := ag.settings.Update(settings, time.Now()); err != nil {
+		return err
+	}
+
+	e.Session.Put(r.Context(), "flash", "Settings saved.")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	return nil
+}
+
+// getStats shows how the instance is used.
+func (ag adminGroup) getStats(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	info, err := ag.stats.Query(time.Now(), statsWeeks, statsLimit)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "stats.page.tmpl", &data.TemplateData{Stats: &info}, http.StatusOK)
+}
+
+// queryUser returns the user with the ID in the path.
+func (ag adminGroup) queryUser(r *http.Request) (user.Info, error) {
+	id := web.ParamByName(r, "id")
+	usr, err := ag.user.QueryByID(id)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidID:
+			return usr, web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case user.ErrNotFound:
+			return usr, web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return usr, errors.Wrapf(err, "querying user %s", id)
+		}
+	}
+	return usr, nil
+}
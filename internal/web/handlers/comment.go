@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+type commentGroup struct {
+	comment interface {
+		Create(nc comment.NewComment, userID string) (comment.Info, error)
+		Update(commentID string, body string, userID string) (comment.Info, error)
+		Delete(commentID string, userID string) (comment.Info, error)
+		QueryParticipants(maybeID string) (comment.Participants, error)
+	}
+	notification interface {
+		Create(nn notification.NewNotification) (notification.Info, error)
+	}
+}
+
+// commentError maps errors of the comment repository to HTTP errors.
+func commentError(err error, msg string) error {
+	switch errors.Cause(err) {
+	case comment.ErrInvalidID:
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	case comment.ErrForbidden:
+		return web.StatusError{Err: err, Code: http.StatusForbidden}
+	case comment.ErrNotFound:
+		return web.StatusError{Err: err, Code: http.StatusNotFound}
+	default:
+		return errors.Wrap(err, msg)
+	}
+}
+
+// validComment validates the body of a comment form. Invalid comments are
+// reported with a flash message on the detail page of the maybe.
+func validComment(e *env.Env, r *http.Request, form *forms.Form) bool {
+	form.Required("body")
+	form.MaxLength("body", 5000)
+	if !form.Valid() {
+		e.Session.Put(r.Context(), "flash", "Comment is invalid: "+form.Errors.Get("body"))
+		return false
+	}
+	return true
+}
+
+func (cg commentGroup) createComment(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	if !validComment(e, r, form) {
+		http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", id), http.StatusSeeOther)
+		return nil
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	nc := comment.NewComment{
+		MaybeID:  id,
+		ParentID: form.Get("parent"),
+		Body:     strings.TrimSpace(form.Get("body")),
+	}
+	c, err := cg.comment.Create(nc, userID)
+	if err != nil {
+		return commentError(err, fmt.Sprintf("creating comment for maybe with ID: %s", id))
+	}
+
+	// the comment is saved, so a failed notification doesn't fail the request
+	if err := cg.notifyMentions(c); err != nil {
+		e.Log.ErrorContext(r.Context(), "notifying mentioned users", "comment_id", c.ID, "err", err)
+	}
+
+	e.Session.Put(r.Context(), "flash", "Comment successfully added!")
+
+	http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v#comment-%v", id, c.ID), http.StatusSeeOther)
+	return nil
+}
+
+// notifyMentions notifies every user who can read the maybe and is mentioned
+// with @handle in the comment, except the author.
+func (cg commentGroup) notifyMentions(c comment.Info) error {
+	participants, err := cg.comment.QueryParticipants(c.MaybeID)
+	if err != nil {
+		return errors.Wrap(err, "selecting participants for mentions")
+	}
+
+	for _, p := range participants.Mentioned(c.Body) {
+		if p.ID == c.UserID {
+			continue
+		}
+		nn := notification.NewNotification{
+			UserID:  p.ID,
+			Message: fmt.Sprintf("%s mentioned you in a comment", c.AuthorName),
+			Link:    fmt.Sprintf("/maybes/view/%v#comment-%v", c.MaybeID, c.ID),
+		}
+		if _, err := cg.notification.Create(nn); err != nil {
+			return errors.Wrapf(err, "notifying %q about mention", p.ID)
+		}
+	}
+	return nil
+}
+
+func (cg commentGroup) updateComment(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	if !validComment(e, r, form) {
+		http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", form.Get("maybe")), http.StatusSeeOther)
+		return nil
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	c, err := cg.comment.Update(id, strings.TrimSpace(form.Get("body")), userID)
+	if err != nil {
+		return commentError(err, fmt.Sprintf("updating comment with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "flash", "Comment successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v#comment-%v", c.MaybeID, c.ID), http.StatusSeeOther)
+	return nil
+}
+
+func (cg commentGroup) deleteComment(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	c, err := cg.comment.Delete(id, userID)
+	if err != nil {
+		return commentError(err, fmt.Sprintf("deleting comment with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "flash", "Comment successfully deleted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", c.MaybeID), http.StatusSeeOther)
+	return nil
+}
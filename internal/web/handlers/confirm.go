@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/token"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+const (
+	// confirmCookie shows that the user logged in at the identity provider again
+	// to confirm a change of the account. Like oidcCookie, it can't be kept in
+	// the session as the session cookie is not sent back from the provider.
+	confirmCookie = "oidc_confirmed"
+	// confirmTTL is how long the user has to make the change after that.
+	confirmTTL = 5 * time.Minute
+)
+
+// confirmPaths are the pages that users return to after they confirmed a
+// change at the identity provider.
+var confirmPaths = map[string]string{
+	"delete":     "/users/delete",
+	"two-factor": "/users/two-factor",
+}
+
+// confirmUser checks that the user who asks for a change that is hard to undo
+// owns the account. Users confirm with their password. Users who never chose
+// one, as they signed up with OpenID Connect, confirm with a code of their
+// authenticator app or a new login at the identity provider instead. Wrong
+// passwords and codes count as failed logins and are added to the form.
+func (ug userGroup) confirmUser(e *env.Env, w http.ResponseWriter, r *http.Request, usr user.Info, form *forms.Form) (bool, error) {
+	password, code := form.Get("password"), form.Get("code")
+	switch {
+	case password != "":
+	case usr.PasswordSet:
+		form.Errors.Add("password", "This field is required")
+		return false, nil
+	case code != "" && usr.TOTPEnabledAt != nil:
+	case confirmedAtProvider(e, w, r, usr.ID):
+		return true, nil
+	default:
+		form.Errors.Add("generic", "Please confirm that it's you.")
+		return false, nil
+	}
+
+	ip, now := web.ClientIP(r), time.Now()
+	wait, err := ug.user.CheckLogin(usr.Email, ip, now)
+	if err != nil {
+		return false, errors.Wrap(err, "checking login throttle")
+	}
+	if !wait.IsZero() {
+		form.Errors.Add("generic", "Too many failed attempts. Please try again later.")
+		return false, nil
+	}
+
+	field, reason := "password", user.LoginWrongPassword
+	if password != "" {
+		err = ug.user.CheckPassword(password, usr.ID)
+	} else {
+		field, reason = "code", user.LoginWrongCode
+		_, err = ug.useCode(usr, code, now)
+	}
+	switch errors.Cause(err) {
+	case nil:
+		return true, nil
+	case user.ErrAuthenticationFailure, user.ErrInvalidCode:
+		_, locked, err := ug.user.RecordFailedLogin(usr.Email, ip, reason, now)
+		if err != nil {
+			return false, errors.Wrap(err, "recording login attempt")
+		}
+		if locked {
+			ug.notifyLockout(e, r, usr)
+		}
+		if field == "password" {
+			form.Errors.Add(field, "Password is incorrect")
+		} else {
+			form.Errors.Add(field, "Code is incorrect")
+		}
+		return false, nil
+	default:
+		return false, errors.Wrapf(err, "confirming ID : %s", usr.ID)
+	}
+}
+
+// confirmedAtProvider reports whether the user logged in at the identity
+// provider in the last confirmTTL. A login confirms a single change.
+func confirmedAtProvider(e *env.Env, w http.ResponseWriter, r *http.Request, userID string) bool {
+	if !hasConfirmed(e, r, userID) {
+		return false
+	}
+	http.SetCookie(w, &http.Cookie{Name: confirmCookie, Path: "/users", MaxAge: -1, HttpOnly: true, Secure: true})
+	return true
+}
+
+// hasConfirmed is like confirmedAtProvider but keeps the confirmation, for
+// showing the form of the change.
+func hasConfirmed(e *env.Env, r *http.Request, userID string) bool {
+	c, err := r.Cookie(confirmCookie)
+	if err != nil {
+		return false
+	}
+	subject, err := token.Verify(e.Secret, "oidc-confirm", c.Value, time.Now())
+	return err == nil && subject == userID
+}
@@ -3,17 +3,22 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/backup"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
 type debugGroup struct {
 	db *sqlx.DB
 }
 
-// health checks if the service is available and database is up.
+// health checks if the service is available and database is up, and reports
+// the last backup if periodic backups are turned on.
 func (dg debugGroup) health(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	status := "ok"
 	statusCode := http.StatusOK
@@ -22,8 +27,13 @@ func (dg debugGroup) health(e *env.Env, w http.ResponseWriter, r *http.Request)
 		statusCode = http.StatusInternalServerError
 	}
 	health := struct {
-		Status string `json:"status`
+		Status string         `json:"status"`
+		Backup *backup.Health `json:"backup,omitempty"`
 	}{Status: status}
+	if e.Backups != nil {
+		h := e.Backups.Health(time.Now())
+		health.Backup = &h
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -31,3 +41,14 @@ func (dg debugGroup) health(e *env.Env, w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(health)
 	return nil
 }
+
+// metrics exposes the metrics of the app for Prometheus to the clients that
+// e.MetricsAccess allows.
+func (dg debugGroup) metrics(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	if !e.MetricsAccess.Allows(web.ClientIP(r), r.Header.Get("Authorization")) {
+		return web.StatusError{Err: errors.New("metrics not allowed from this client"), Code: http.StatusForbidden}
+	}
+
+	e.Metrics.Handler(e.Log).ServeHTTP(w, r)
+	return nil
+}
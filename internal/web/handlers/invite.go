@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+// inviteQuota returns how many invites the user of the request can create,
+// -1 for admins, who have no limit.
+func (ug userGroup) inviteQuota(r *http.Request) (int, error) {
+	if web.IsAdmin(r) {
+		return -1, nil
+	}
+	settings, err := ug.settings.Query()
+	if err != nil {
+		return 0, errors.Wrap(err, "querying settings")
+	}
+	return settings.InviteQuota, nil
+}
+
+// invites shows the invite codes of the user and how many are left.
+func (ug userGroup) invites(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	invites, err := ug.user.QueryInvites(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+	settings, err := ug.settings.Query()
+	if err != nil {
+		return errors.Wrap(err, "querying settings")
+	}
+	quota, err := ug.inviteQuota(r)
+	if err != nil {
+		return err
+	}
+	left := -1
+	if quota >= 0 {
+		left = max(quota-len(invites), 0)
+	}
+
+	return web.Render(e, w, r, "invites.page.tmpl", &data.TemplateData{
+		SignupInvites: invites,
+		InvitesLeft:   left,
+		Settings:      &settings,
+		BaseURL:       e.BaseURL,
+	}, http.StatusOK)
+}
+
+func (ug userGroup) createInvite(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	quota, err := ug.inviteQuota(r)
+	if err != nil {
+		return err
+	}
+	inv, err := ug.user.CreateInvite(userID, quota, time.Now())
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInviteQuota:
+			e.Session.Put(r.Context(), "flash", "You have no invites left.")
+			http.Redirect(w, r, "/users/invites", http.StatusSeeOther)
+			return nil
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Invite code "+inv.Code+" created.")
+	http.Redirect(w, r, "/users/invites", http.StatusSeeOther)
+	return nil
+}
+
+func (ug userGroup) deleteInvite(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	code := web.ParamByName(r, "code")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ug.user.DeleteInvite(code, userID); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Invite code deleted.")
+	http.Redirect(w, r, "/users/invites", http.StatusSeeOther)
+	return nil
+}
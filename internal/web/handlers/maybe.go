@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/vote"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
@@ -16,31 +20,64 @@ import (
 
 type maybeGroup struct {
 	maybe interface {
-		Query(userID string) (maybe.Infos, error)
+		Query(userID, workspaceID, sort string) (maybe.Infos, error)
 		QueryByID(maybeID, userID string) (maybe.Info, error)
-		QueryByTag(maybeID, userID string) (maybe.Infos, error)
-		QueryTags(userID string) (maybe.Tags, error)
+		QueryByTag(tagID, userID, workspaceID, sort string) (maybe.Infos, error)
+		QueryTags(userID, workspaceID string) (maybe.Tags, error)
+		QueryTagByID(tagID string) (maybe.Tag, error)
 		Create(nm maybe.NewOrUpdateMaybe, userID string) (maybe.Info, error)
 		Update(um maybe.NewOrUpdateMaybe, maybeID string, userID string) error
-		Delete(maybeID string) error
+		Delete(maybeID string, userID string) error
+		QuerySnoozed(userID, workspaceID string) (maybe.Infos, error)
+		SetReminder(maybeID string, remindAt *time.Time, userID string) error
+		Snooze(maybeID string, until *time.Time, userID string) error
+	}
+	comment interface {
+		Query(maybeID string, userID string) (comment.Infos, error)
+	}
+	vote interface {
+		QueryTally(maybeID string, userID string) (vote.Tally, error)
 	}
 }
 
 func (mg maybeGroup) getAllMaybes(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
-	maybes, err := mg.maybe.Query(userID)
+	sort := r.URL.Query().Get("sort")
+	maybes, err := mg.maybe.Query(userID, web.CurrentWorkspaceID(r), sort)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "home.page.tmpl", &data.TemplateData{Maybes: maybes, Sort: sort}, http.StatusOK)
+}
+
+// getUpNext lists the maybes of the current space ordered by their urgency.
+func (mg maybeGroup) getUpNext(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+	maybes, err := mg.maybe.Query(userID, web.CurrentWorkspaceID(r), "")
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "upnext.page.tmpl", &data.TemplateData{Maybes: maybe.UpNext(maybes, time.Now().UTC())}, http.StatusOK)
+}
+
+func (mg maybeGroup) getSnoozedMaybes(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+	maybes, err := mg.maybe.QuerySnoozed(userID, web.CurrentWorkspaceID(r))
 	if err != nil {
 		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
 	}
 
-	return web.Render(e, w, r, "home.page.tmpl", &data.TemplateData{Maybes: maybes}, http.StatusOK)
+	return web.Render(e, w, r, "snoozed.page.tmpl", &data.TemplateData{Maybes: maybes}, http.StatusOK)
 }
 
 func (mg maybeGroup) getMaybesByTag(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	id := web.ParamByName(r, "id")
 	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
 
-	maybes, err := mg.maybe.QueryByTag(id, userID)
+	sort := r.URL.Query().Get("sort")
+	maybes, err := mg.maybe.QueryByTag(id, userID, web.CurrentWorkspaceID(r), sort)
 	if err != nil {
 		switch errors.Cause(err) {
 		case maybe.ErrInvalidTag:
@@ -52,7 +89,17 @@ func (mg maybeGroup) getMaybesByTag(e *env.Env, w http.ResponseWriter, r *http.R
 		}
 	}
 
-	return web.Render(e, w, r, "home.page.tmpl", &data.TemplateData{Maybes: maybes}, http.StatusOK)
+	tag, err := mg.maybe.QueryTagByID(id)
+	if err != nil {
+		switch errors.Cause(err) {
+		case maybe.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+		}
+	}
+
+	return web.Render(e, w, r, "home.page.tmpl", &data.TemplateData{Maybes: maybes, Tag: &tag, Sort: sort}, http.StatusOK)
 }
 
 func (mg maybeGroup) getMaybeByID(e *env.Env, w http.ResponseWriter, r *http.Request) error {
@@ -73,7 +120,17 @@ func (mg maybeGroup) getMaybeByID(e *env.Env, w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	return web.Render(e, w, r, "maybe_detail.page.tmpl", &data.TemplateData{Maybe: &mb}, http.StatusOK)
+	comments, err := mg.comment.Query(id, userID)
+	if err != nil {
+		return errors.Wrapf(err, "selecting comments for ID : %s", id)
+	}
+
+	tally, err := mg.vote.QueryTally(id, userID)
+	if err != nil {
+		return errors.Wrapf(err, "selecting votes for ID : %s", id)
+	}
+
+	return web.Render(e, w, r, "maybe_detail.page.tmpl", &data.TemplateData{Maybe: &mb, Comments: comments, Tally: &tally, Form: forms.New(nil)}, http.StatusOK)
 }
 
 func (mg maybeGroup) createMaybeForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
@@ -87,6 +144,7 @@ func (mg maybeGroup) createMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 	form.ValidUrl("url")
 	form.MaxLength("title", 255)
 	form.MaxLength("description", 255)
+	validatePlanning(form)
 
 	if !form.Valid() {
 		return web.Render(e, w, r, "create.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
@@ -97,7 +155,9 @@ func (mg maybeGroup) createMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 		Url:         form.Get("url"),
 		Description: form.Get("description"),
 		Tags:        nil,
+		WorkspaceID: web.CurrentWorkspaceID(r),
 	}
+	setPlanning(form, &nm)
 
 	// if user added tags into the form, make a slice of tags,
 	// sanitize them and add them to the model
@@ -120,6 +180,8 @@ func (mg maybeGroup) createMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 		case maybe.ErrInvalidTag:
 			form.Errors.Add("tags", "tags are invalid")
 			return web.Render(e, w, r, "create.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+		case maybe.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
 		default:
 			return errors.Wrapf(err, "creating new maybe: %v", nm)
 		}
@@ -141,6 +203,8 @@ func (mg maybeGroup) updateMaybeForm(e *env.Env, w http.ResponseWriter, r *http.
 		switch errors.Cause(err) {
 		case maybe.ErrInvalidID:
 			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case maybe.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
 		case maybe.ErrNotFound:
 			return web.StatusError{Err: err, Code: http.StatusNotFound}
 		default:
@@ -153,6 +217,13 @@ func (mg maybeGroup) updateMaybeForm(e *env.Env, w http.ResponseWriter, r *http.
 	form.Set("title", mb.Title)
 	form.Set("url", mb.Url)
 	form.Set("description", mb.Description)
+	form.Set("priority", mb.Priority)
+	if mb.Effort > 0 {
+		form.Set("effort", strconv.Itoa(mb.Effort))
+	}
+	if mb.DueDate != nil {
+		form.Set("due", mb.DueDate.Format(forms.DateLayout))
+	}
 	// convert tag model into a list of strings for the form
 	if mb.Tags != nil {
 		tagNames := make([]string, len(mb.Tags))
@@ -172,6 +243,7 @@ func (mg maybeGroup) updateMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 	form.MaxLength("title", 255)
 	form.ValidUrl("url")
 	form.MaxLength("description", 255)
+	validatePlanning(form)
 
 	if !form.Valid() {
 		return web.Render(e, w, r, "update.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
@@ -183,6 +255,7 @@ func (mg maybeGroup) updateMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 		Description: form.Get("description"),
 		Tags:        nil,
 	}
+	setPlanning(form, &um)
 
 	// if user added tags into the form, make a slice of tags,
 	// sanitize them and add them to the model
@@ -224,12 +297,15 @@ func (mg maybeGroup) updateMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 
 func (mg maybeGroup) deleteMaybe(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
 
-	err := mg.maybe.Delete(id)
+	err := mg.maybe.Delete(id, userID)
 	if err != nil {
 		switch errors.Cause(err) {
 		case maybe.ErrInvalidID:
 			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case maybe.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
 		case maybe.ErrNotFound:
 			return web.StatusError{Err: err, Code: http.StatusNotFound}
 		default:
@@ -246,7 +322,7 @@ func (mg maybeGroup) deleteMaybe(e *env.Env, w http.ResponseWriter, r *http.Requ
 func (mg maybeGroup) getAllTags(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
 
-	tags, err := mg.maybe.QueryTags(userID)
+	tags, err := mg.maybe.QueryTags(userID, web.CurrentWorkspaceID(r))
 	if err != nil {
 		switch errors.Cause(err) {
 		case maybe.ErrNotFound:
@@ -257,3 +333,110 @@ func (mg maybeGroup) getAllTags(e *env.Env, w http.ResponseWriter, r *http.Reque
 	}
 	return web.Render(e, w, r, "tag.page.tmpl", &data.TemplateData{Tags: tags}, http.StatusOK)
 }
+
+// validatePlanning validates the optional priority, effort and due date of a maybe form.
+func validatePlanning(form *forms.Form) {
+	form.PermittedValues("priority", maybe.PriorityLow, maybe.PriorityMedium, maybe.PriorityHigh)
+	form.IntegerRange("effort", 0, 1000)
+	form.ValidDate("due")
+}
+
+// setPlanning adds the priority, effort and due date of a validated maybe form to the model.
+func setPlanning(form *forms.Form, m *maybe.NewOrUpdateMaybe) {
+	m.Priority = form.Get("priority")
+	m.Effort, _ = strconv.Atoi(form.Get("effort"))
+	if due, err := time.Parse(forms.DateLayout, form.Get("due")); err == nil {
+		m.DueDate = &due
+	}
+}
+
+// reminderTime returns the time of a reminder form: a number of days from now
+// in "in" or a date in "at". Without both, it returns nil.
+func reminderTime(form *forms.Form) *time.Time {
+	if days, err := strconv.Atoi(form.Get("in")); err == nil {
+		t := time.Now().UTC().AddDate(0, 0, days)
+		return &t
+	}
+	if at, err := time.Parse(forms.DateLayout, form.Get("at")); err == nil {
+		return &at
+	}
+	return nil
+}
+
+func (mg maybeGroup) remindMaybe(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+
+	form := forms.New(r.PostForm)
+	form.PermittedValues("in", "1", "7", "14", "30")
+	form.ValidDate("at")
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid reminder form"), Code: http.StatusBadRequest}
+	}
+
+	remindAt := reminderTime(form)
+	if remindAt != nil && remindAt.Before(time.Now()) {
+		e.Session.Put(r.Context(), "flash", "Reminders must be in the future.")
+		http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", id), http.StatusSeeOther)
+		return nil
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := mg.maybe.SetReminder(id, remindAt, userID); err != nil {
+		switch errors.Cause(err) {
+		case maybe.ErrInvalidID:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case maybe.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
+		case maybe.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "setting reminder for maybe: %s", id)
+		}
+	}
+
+	if remindAt == nil {
+		e.Session.Put(r.Context(), "flash", "Reminder removed!")
+	} else {
+		e.Session.Put(r.Context(), "flash", "Reminder successfully set!")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", id), http.StatusSeeOther)
+	return nil
+}
+
+func (mg maybeGroup) snoozeMaybe(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+
+	form := forms.New(r.PostForm)
+	form.PermittedValues("in", "1", "7", "14", "30", "90")
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid snooze form"), Code: http.StatusBadRequest}
+	}
+
+	until := reminderTime(form)
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := mg.maybe.Snooze(id, until, userID); err != nil {
+		switch errors.Cause(err) {
+		case maybe.ErrInvalidID:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case maybe.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
+		case maybe.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "snoozing maybe: %s", id)
+		}
+	}
+
+	if until == nil {
+		e.Session.Put(r.Context(), "flash", "Maybe is back on your list!")
+		http.Redirect(w, r, fmt.Sprintf("/maybes/view/%v", id), http.StatusSeeOther)
+		return nil
+	}
+
+	e.Session.Put(r.Context(), "flash", "Maybe snoozed, we will remind you!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+type notificationGroup struct {
+	notification interface {
+		Query(userID string) (notification.Infos, error)
+		MarkRead(notificationID string, userID string) (notification.Info, error)
+		MarkAllRead(userID string) error
+	}
+}
+
+func (ng notificationGroup) getAllNotifications(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	notifications, err := ng.notification.Query(userID)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "notifications.page.tmpl", &data.TemplateData{Notifications: notifications}, http.StatusOK)
+}
+
+// readNotification marks a notification as read and redirects to its link.
+func (ng notificationGroup) readNotification(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	n, err := ng.notification.MarkRead(id, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case notification.ErrInvalidID:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case notification.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "reading notification with ID: %s", id)
+		}
+	}
+
+	http.Redirect(w, r, n.Link, http.StatusSeeOther)
+	return nil
+}
+
+func (ng notificationGroup) readAllNotifications(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ng.notification.MarkAllRead(userID); err != nil {
+		return errors.Wrap(err, "reading all notifications")
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+	return nil
+}
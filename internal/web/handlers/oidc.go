@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/oidc"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/token"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+const (
+	// oidcCookie keeps the state, nonce and PKCE verifier of a login at the
+	// identity provider. The session cookie can't be used as it is SameSite=Strict
+	// and not sent with the redirect back from the provider.
+	oidcCookie = "oidc_login"
+	// oidcTTL is how long a user has to log in at the identity provider.
+	oidcTTL = 10 * time.Minute
+)
+
+type oidcGroup struct {
+	user interface {
+		QueryByIdentity(issuer, subject string) (user.Info, error)
+		LinkIdentity(id user.Identity, now time.Time, signup func(email string) error) (user.Info, error)
+	}
+	provider interface {
+		AuthCodeURL(state, nonce, verifier string) string
+		Exchange(ctx context.Context, code, verifier, nonce string) (oidc.Claims, error)
+	}
+	// login finishes logging in users the provider identified
+	login userGroup
+}
+
+func (og oidcGroup) startOIDC(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return og.start(e, w, r, "")
+}
+
+// startConfirm sends a logged in user to the identity provider to confirm a
+// change of the account, one of confirmPaths.
+func (og oidcGroup) startConfirm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	change := web.ParamByName(r, "change")
+	if _, ok := confirmPaths[change]; !ok {
+		return web.StatusError{Err: errors.Errorf("unknown change %q", change), Code: http.StatusNotFound}
+	}
+	return og.start(e, w, r, change)
+}
+
+// start redirects to the identity provider for a login, or for confirming a
+// change if change is set.
+func (og oidcGroup) start(e *env.Env, w http.ResponseWriter, r *http.Request, change string) error {
+	state, err := randomString()
+	if err != nil {
+		return err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return err
+	}
+	verifier := oidc.GenerateVerifier()
+
+	now := time.Now()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    token.Sign(e.Secret, "oidc", strings.Join([]string{state, nonce, verifier, change}, "."), now.Add(oidcTTL)),
+		Path:     "/users/oidc",
+		Expires:  now.Add(oidcTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, og.provider.AuthCodeURL(state, nonce, verifier), http.StatusSeeOther)
+	return nil
+}
+
+func (og oidcGroup) callback(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	// the login can only be tried once
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/users/oidc", MaxAge: -1, HttpOnly: true, Secure: true})
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		return og.loginError(e, w, r, "Login with "+e.OIDC.Name+" was cancelled.", http.StatusUnauthorized)
+	}
+
+	c, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return web.StatusError{Err: errors.New("no OpenID Connect login in progress"), Code: http.StatusBadRequest}
+	}
+	subject, err := token.Verify(e.Secret, "oidc", c.Value, time.Now())
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	}
+	parts := strings.Split(subject, ".")
+	if len(parts) != 4 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(q.Get("state"))) != 1 {
+		return web.StatusError{Err: errors.New("OpenID Connect state does not match"), Code: http.StatusBadRequest}
+	}
+	nonce, verifier := parts[1], parts[2]
+
+	claims, err := og.provider.Exchange(r.Context(), q.Get("code"), verifier, nonce)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	}
+	if path, ok := confirmPaths[parts[3]]; ok {
+		return og.confirm(e, w, r, claims, path)
+	}
+
+	usr, err := og.user.LinkIdentity(user.Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, time.Now(), og.login.checkSignup)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrRegistrationClosed:
+			return og.loginError(e, w, r, "Registration is closed. Only existing accounts can log in with "+e.OIDC.Name+".", http.StatusForbidden)
+		case user.ErrInviteRequired:
+			return og.loginError(e, w, r, "New accounts need an invite code. Please sign up with your invite code first, then you can log in with "+e.OIDC.Name+".", http.StatusForbidden)
+		case user.ErrDomainNotAllowed:
+			return og.loginError(e, w, r, "Your email address can't sign up here. Only existing accounts and allowed email domains can log in with "+e.OIDC.Name+".", http.StatusForbidden)
+		case user.ErrEmailNotVerified:
+			return og.loginError(e, w, r, e.OIDC.Name+" has not verified your email address. Please sign up with a password instead.", http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "linking identity %q at %q", claims.Subject, claims.Issuer)
+		}
+	}
+	if !usr.Active {
+		return web.StatusError{Err: errors.Errorf("user %s is not active", usr.ID), Code: http.StatusForbidden}
+	}
+
+	path, err := og.login.startLogin(e, r, usr)
+	if err != nil {
+		return err
+	}
+	return og.continueTo(e, w, r, path)
+}
+
+// confirm lets the user of an identity make the change at path for a while.
+// The session cookie is not sent back from the provider, so the change checks
+// that the logged in user is the same.
+func (og oidcGroup) confirm(e *env.Env, w http.ResponseWriter, r *http.Request, claims oidc.Claims, path string) error {
+	usr, err := og.user.QueryByIdentity(claims.Issuer, claims.Subject)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
+		default:
+			return errors.Wrapf(err, "querying identity %q at %q", claims.Subject, claims.Issuer)
+		}
+	}
+
+	now := time.Now()
+	http.SetCookie(w, &http.Cookie{
+		Name:     confirmCookie,
+		Value:    token.Sign(e.Secret, "oidc-confirm", usr.ID, now.Add(confirmTTL)),
+		Path:     "/users",
+		Expires:  now.Add(confirmTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return og.continueTo(e, w, r, path)
+}
+
+// continueTo sends the browser on to path with a page instead of a redirect.
+// A redirect would still count as coming from the identity provider, so the
+// browser would not send the SameSite=Strict session cookie.
+func (og oidcGroup) continueTo(e *env.Env, w http.ResponseWriter, r *http.Request, path string) error {
+	return web.Render(e, w, r, "continue.page.tmpl", &data.TemplateData{RedirectPath: path}, http.StatusOK)
+}
+
+// loginError shows the login form with a message about a failed login at the
+// identity provider.
+func (og oidcGroup) loginError(e *env.Env, w http.ResponseWriter, r *http.Request, msg string, code int) error {
+	form := forms.New(nil)
+	form.Errors.Add("generic", msg)
+	return web.Render(e, w, r, "login.page.tmpl", &data.TemplateData{Form: form}, code)
+}
+
+// randomString returns 32 random bytes encoded for use in URLs.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating random string")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
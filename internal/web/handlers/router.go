@@ -2,35 +2,53 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/justinas/alice"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/comment"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/notification"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/setting"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/share"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/stats"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/vote"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
+	"github.com/sophiabrandt/go-maybe-list/internal/digest"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/mid"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/ratelimit"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
 // New creates a new router with all application routes.
 func New(e *env.Env, db *sqlx.DB) http.Handler {
-	standardMiddleware := alice.New(mid.SecureHeaders, mid.LogRequest(e.Log), mid.RecoverPanic(e.Log))
+	r := http.NewServeMux()
 
-	dynamicMiddleware := alice.New(e.Session.LoadAndSave, mid.NoSurf, mid.Authenticate(e, user.New(db)))
+	standardMiddleware := alice.New(mid.RealIP(e.TrustedProxies), mid.RequestID, mid.SecureHeaders, mid.LogRequest(e.Log), mid.Metrics(e.Metrics, r), mid.RecoverPanic(e.Log))
 
-	r := http.NewServeMux()
+	dynamicMiddleware := alice.New(e.Session.LoadAndSave, mid.NoSurf, mid.Authenticate(e, user.New(db)), mid.LoadWorkspaces(e, workspace.New(db)))
 
-	// liveness check
+	// liveness check and metrics
 	dg := debugGroup{
 		db: db,
 	}
 	r.Handle("GET /debug/health", web.Handler{E: e, H: dg.health})
+	r.Handle("GET /debug/metrics", web.Handler{E: e, H: dg.metrics})
 
 	// maybe routes
 	mg := maybeGroup{
-		maybe: maybe.New(db),
+		maybe:   maybe.New(db),
+		comment: comment.New(db),
+		vote:    vote.New(db),
 	}
 	r.Handle("GET /{$}", dynamicMiddleware.Then(web.Handler{E: e, H: mg.getAllMaybes}))
+	r.Handle("GET /maybes/next", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.getUpNext}))
+	r.Handle("GET /maybes/snoozed", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.getSnoozedMaybes}))
+	r.Handle("POST /maybes/remind/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.remindMaybe}))
+	r.Handle("POST /maybes/snooze/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.snoozeMaybe}))
 	r.Handle("GET /maybes/create", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.createMaybeForm}))
 	r.Handle("POST /maybes/create", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.createMaybe}))
 	r.Handle("GET /maybes/view/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.getMaybeByID}))
@@ -40,19 +58,141 @@ func New(e *env.Env, db *sqlx.DB) http.Handler {
 	r.Handle("GET /tags", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.getAllTags}))
 	r.Handle("GET /tags/view/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: mg.getMaybesByTag}))
 
+	// comment routes
+	cg := commentGroup{
+		comment:      comment.New(db),
+		notification: notification.New(db),
+	}
+	r.Handle("POST /maybes/comments/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: cg.createComment}))
+	r.Handle("POST /comments/update/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: cg.updateComment}))
+	r.Handle("POST /comments/delete/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: cg.deleteComment}))
+
+	// notification routes
+	ng := notificationGroup{
+		notification: notification.New(db),
+	}
+	r.Handle("GET /notifications", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ng.getAllNotifications}))
+	r.Handle("POST /notifications/read/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ng.readNotification}))
+	r.Handle("POST /notifications/read-all", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ng.readAllNotifications}))
+
+	// vote routes
+	vg := voteGroup{
+		vote: vote.New(db),
+	}
+	r.Handle("POST /maybes/vote/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.voteMaybe}))
+	r.Handle("GET /rounds", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.getAllRounds}))
+	r.Handle("POST /rounds/create", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.createRound}))
+	r.Handle("GET /rounds/view/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.getRoundByID}))
+	r.Handle("POST /rounds/vote/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.castDots}))
+	r.Handle("GET /rounds/results/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: vg.getRoundResults}))
+
+	// share routes
+	sg := shareGroup{
+		share:     share.New(db),
+		maybe:     maybe.New(db),
+		workspace: workspace.New(db),
+	}
+	r.Handle("GET /shares", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: sg.getAllShares}))
+	r.Handle("POST /shares/create", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: sg.createShare}))
+	r.Handle("POST /shares/revoke/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: sg.deleteShare}))
+	r.Handle("GET /s/{token}", dynamicMiddleware.Then(web.Handler{E: e, H: sg.viewShare}))
+
+	// workspace routes
+	wg := workspaceGroup{
+		workspace: workspace.New(db),
+		user:      user.New(db),
+	}
+	r.Handle("GET /workspaces", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.getAllWorkspaces}))
+	r.Handle("POST /workspaces/create", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.createWorkspace}))
+	r.Handle("POST /workspaces/switch", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.switchWorkspace}))
+	r.Handle("GET /workspaces/view/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.getWorkspaceByID}))
+	r.Handle("POST /workspaces/delete/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.deleteWorkspace}))
+	r.Handle("POST /workspaces/invite/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.inviteMember}))
+	r.Handle("POST /workspaces/invites/revoke/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.revokeInvite}))
+	r.Handle("POST /workspaces/members/update/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.updateMember}))
+	r.Handle("POST /workspaces/members/remove/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.removeMember}))
+	r.Handle("POST /workspaces/leave/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.leaveWorkspace}))
+	r.Handle("POST /invites/accept/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.acceptInvite}))
+	r.Handle("POST /invites/decline/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: wg.declineInvite}))
+
 	// user
 	ug := userGroup{
 		user: user.New(db),
+		digest: digest.Generator{
+			Users:     user.New(db),
+			Maybes:    maybe.New(db),
+			Templates: e.MailTemplates,
+			BaseURL:   e.BaseURL,
+		},
+		resets:        ratelimit.New(5, 15*time.Minute),
+		verifications: ratelimit.New(3, 15*time.Minute),
+		settings:      setting.New(db),
+		notifier: notify.Multi{
+			notify.Inbox{Repo: notification.New(db)},
+			notify.Email{Sender: e.Mailer, BaseURL: e.BaseURL},
+		},
 	}
 	r.Handle("GET /users/signup", dynamicMiddleware.Then(web.Handler{E: e, H: ug.signupForm}))
 	r.Handle("POST /users/signup", dynamicMiddleware.Then(web.Handler{E: e, H: ug.signup}))
 	r.Handle("GET /users/login", dynamicMiddleware.Then(web.Handler{E: e, H: ug.loginForm}))
 	r.Handle("POST /users/login", dynamicMiddleware.Then(web.Handler{E: e, H: ug.login}))
+	r.Handle("GET /users/login/code", dynamicMiddleware.Then(web.Handler{E: e, H: ug.loginCodeForm}))
+	r.Handle("POST /users/login/code", dynamicMiddleware.Then(web.Handler{E: e, H: ug.loginCode}))
+	r.Handle("GET /users/forgot-password", dynamicMiddleware.Then(web.Handler{E: e, H: ug.forgotPasswordForm}))
+	r.Handle("POST /users/forgot-password", dynamicMiddleware.Then(web.Handler{E: e, H: ug.forgotPassword}))
+	r.Handle("GET /users/reset-password", dynamicMiddleware.Then(web.Handler{E: e, H: ug.resetPasswordForm}))
+	r.Handle("POST /users/reset-password", dynamicMiddleware.Then(web.Handler{E: e, H: ug.resetPassword}))
+	r.Handle("GET /users/verify", dynamicMiddleware.Then(web.Handler{E: e, H: ug.verify}))
+	r.Handle("GET /users/verify/resend", dynamicMiddleware.Then(web.Handler{E: e, H: ug.resendVerificationForm}))
+	r.Handle("POST /users/verify/resend", dynamicMiddleware.Then(web.Handler{E: e, H: ug.resendVerification}))
 	r.Handle("POST /users/logout", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.logout}))
 	r.Handle("GET /users/profile", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.profile}))
+	r.Handle("POST /users/digest", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.updateDigest}))
+	r.Handle("GET /users/digest/preview", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.previewDigest}))
+	r.Handle("POST /users/sessions/revoke/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.revokeSession}))
+	r.Handle("POST /users/sessions/revoke-all", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.revokeAllSessions}))
+	r.Handle("GET /users/two-factor", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.twoFactor}))
+	r.Handle("POST /users/two-factor/enable", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.enableTwoFactor}))
+	r.Handle("POST /users/two-factor/disable", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.disableTwoFactor}))
+	// single sign-on
+	if e.OIDC != nil {
+		og := oidcGroup{
+			user:     user.New(db),
+			provider: e.OIDC,
+			login:    ug,
+		}
+		r.Handle("GET /users/oidc/login", dynamicMiddleware.Then(web.Handler{E: e, H: og.startOIDC}))
+		r.Handle("GET /users/oidc/callback", dynamicMiddleware.Then(web.Handler{E: e, H: og.callback}))
+		r.Handle("GET /users/oidc/confirm/{change}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: og.startConfirm}))
+	}
+
+	r.Handle("GET /users/export", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.exports}))
+	r.Handle("POST /users/export", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.requestExport}))
+	r.Handle("GET /users/export/{id}", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.downloadExport}))
+	r.Handle("GET /users/delete", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.deleteAccountForm}))
+	r.Handle("POST /users/delete", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.deleteAccount}))
+	r.Handle("POST /users/delete/cancel", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.cancelDeletion}))
+	r.Handle("GET /users/invites", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.invites}))
+	r.Handle("POST /users/invites", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.createInvite}))
+	r.Handle("POST /users/invites/{code}/delete", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.deleteInvite}))
 	r.Handle("GET /users/change-password", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.changePasswordForm}))
 	r.Handle("POST /users/change-password", dynamicMiddleware.Append(mid.RequireAuthentication(e)).Then(web.Handler{E: e, H: ug.changePassword}))
 
+	// admin routes
+	ag := adminGroup{
+		user:     user.New(db),
+		settings: setting.New(db),
+		stats:    stats.New(db),
+	}
+	adminMiddleware := dynamicMiddleware.Append(mid.RequireAuthentication(e), mid.RequireAdmin(e))
+	r.Handle("GET /admin", adminMiddleware.Then(web.Handler{E: e, H: ag.getDashboard}))
+	r.Handle("POST /admin/settings", adminMiddleware.Then(web.Handler{E: e, H: ag.updateSettings}))
+	r.Handle("GET /admin/users", adminMiddleware.Then(web.Handler{E: e, H: ag.getUsers}))
+	r.Handle("GET /admin/users/{id}", adminMiddleware.Then(web.Handler{E: e, H: ag.getUser}))
+	r.Handle("POST /admin/users/{id}/deactivate", adminMiddleware.Then(web.Handler{E: e, H: ag.deactivateUser}))
+	r.Handle("POST /admin/users/{id}/reactivate", adminMiddleware.Then(web.Handler{E: e, H: ag.reactivateUser}))
+	r.Handle("GET /admin/stats", adminMiddleware.Then(web.Handler{E: e, H: ag.getStats}))
+
 	// fileServer
 	fileServer := http.FileServer(web.NeuteredFileSystem{Fs: http.Dir("./ui/static/")})
 	r.Handle("GET /static/", http.StripPrefix("/static", fileServer))
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/maybe"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/share"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+type shareGroup struct {
+	share interface {
+		Query(userID string) (share.Infos, error)
+		QueryByToken(token string) (share.Info, error)
+		Create(ns share.NewShare, userID string) (share.Info, error)
+		Delete(shareID string, userID string) error
+	}
+	maybe interface {
+		Query(userID, workspaceID, sort string) (maybe.Infos, error)
+		QueryByID(maybeID, userID string) (maybe.Info, error)
+		QueryByTag(tagID, userID, workspaceID, sort string) (maybe.Infos, error)
+		QueryTagByID(tagID string) (maybe.Tag, error)
+	}
+	workspace interface {
+		QueryByID(workspaceID string, userID string) (workspace.Info, error)
+	}
+}
+
+func (sg shareGroup) getAllShares(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+	shares, err := sg.share.Query(userID)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "shares.page.tmpl", &data.TemplateData{Shares: shares}, http.StatusOK)
+}
+
+func (sg shareGroup) createShare(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("kind", "target")
+	form.PermittedValues("kind", share.KindMaybe, share.KindTag, share.KindWorkspace)
+	form.PermittedValues("expires", "", "1", "7", "30")
+
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid share form"), Code: http.StatusBadRequest}
+	}
+
+	ns := share.NewShare{
+		Kind:     form.Get("kind"),
+		TargetID: form.Get("target"),
+	}
+	if days, err := strconv.Atoi(form.Get("expires")); err == nil {
+		expiresAt := time.Now().UTC().AddDate(0, 0, days)
+		ns.ExpiresAt = &expiresAt
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	_, err := sg.share.Create(ns, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case share.ErrInvalidID, share.ErrInvalidKind:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case share.ErrForbidden:
+			return web.StatusError{Err: err, Code: http.StatusForbidden}
+		default:
+			return errors.Wrapf(err, "creating new share: %v", ns)
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Public link successfully created!")
+
+	http.Redirect(w, r, "/shares", http.StatusSeeOther)
+	return nil
+}
+
+func (sg shareGroup) deleteShare(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	err := sg.share.Delete(id, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case share.ErrInvalidID:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case share.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "deleting share with ID: %s", id)
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Public link successfully revoked!")
+
+	http.Redirect(w, r, "/shares", http.StatusSeeOther)
+	return nil
+}
+
+// viewShare renders shared content without authentication. Only the shared
+// maybe or the maybes of the shared tag or workspace are exposed, never data of
+// the owner or the members.
+func (sg shareGroup) viewShare(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	token := web.ParamByName(r, "token")
+
+	sh, err := sg.share.QueryByToken(token)
+	if err != nil {
+		switch errors.Cause(err) {
+		case share.ErrNotFound, share.ErrExpired:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrap(err, "selecting share")
+		}
+	}
+
+	td := &data.TemplateData{}
+	switch sh.Kind {
+	case share.KindMaybe:
+		mb, err := sg.maybe.QueryByID(sh.TargetID, sh.UserID)
+		if err != nil {
+			return sharedContentError(err)
+		}
+		mb.UserID = ""
+		mb.Score = 0
+		td.Maybe = &mb
+	case share.KindTag:
+		tag, err := sg.maybe.QueryTagByID(sh.TargetID)
+		if err != nil {
+			return sharedContentError(err)
+		}
+		// tag views are shared from the personal space of the user
+		maybes, err := sg.maybe.QueryByTag(sh.TargetID, sh.UserID, "", "")
+		if err != nil {
+			return sharedContentError(err)
+		}
+		for i := range maybes {
+			maybes[i].UserID = ""
+			maybes[i].Score = 0
+		}
+		td.Tag = &tag
+		td.Maybes = maybes
+	case share.KindWorkspace:
+		ws, err := sg.workspace.QueryByID(sh.TargetID, sh.UserID)
+		if err != nil {
+			return sharedContentError(err)
+		}
+		maybes, err := sg.maybe.Query(sh.UserID, sh.TargetID, "")
+		if err != nil {
+			return sharedContentError(err)
+		}
+		for i := range maybes {
+			maybes[i].UserID = ""
+			maybes[i].Score = 0
+		}
+		td.Workspace = &workspace.Info{Name: ws.Name}
+		td.Maybes = maybes
+	}
+
+	return web.Render(e, w, r, "shared.page.tmpl", td, http.StatusOK)
+}
+
+// sharedContentError maps errors for content that has been deleted or is no
+// longer owned by the user who shared it to a not found error.
+func sharedContentError(err error) error {
+	switch errors.Cause(err) {
+	case maybe.ErrNotFound, maybe.ErrForbidden, maybe.ErrInvalidID, maybe.ErrInvalidTag,
+		workspace.ErrNotFound, workspace.ErrForbidden, workspace.ErrInvalidID:
+		return web.StatusError{Err: err, Code: http.StatusNotFound}
+	default:
+		return errors.Wrap(err, "selecting shared content")
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
+	"github.com/sophiabrandt/go-maybe-list/internal/totp"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+const (
+	// pendingTTL is how long a user has to enter the code after the password.
+	pendingTTL = 5 * time.Minute
+
+	// maxCodeAttempts is how many wrong codes a user can enter before having
+	// to log in with the password again.
+	maxCodeAttempts = 5
+
+	// totpIssuer is shown in authenticator apps next to the email address.
+	totpIssuer = "Maybe List"
+)
+
+// pendingUser returns the ID of the user who entered the correct password but
+// not the code yet. Expired logins are removed from the session.
+func pendingUser(e *env.Env, r *http.Request) string {
+	userID := e.Session.GetString(r.Context(), "pendingUserID")
+	if userID != "" && time.Now().Unix() > e.Session.GetInt64(r.Context(), "pendingUntil") {
+		clearPending(e, r)
+		return ""
+	}
+	return userID
+}
+
+func clearPending(e *env.Env, r *http.Request) {
+	e.Session.Remove(r.Context(), "pendingUserID")
+	e.Session.Remove(r.Context(), "pendingUntil")
+	e.Session.Remove(r.Context(), "pendingAttempts")
+}
+
+func (ug userGroup) loginCodeForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	if pendingUser(e, r) == "" {
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return nil
+	}
+	return web.Render(e, w, r, "logincode.page.tmpl", &data.TemplateData{Form: forms.New(nil)}, http.StatusOK)
+}
+
+// loginCode is the second step of the login for users with two-factor
+// authentication. It accepts a code from the authenticator app or a recovery code.
+func (ug userGroup) loginCode(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := pendingUser(e, r)
+	if userID == "" {
+		e.Session.Put(r.Context(), "flash", "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return nil
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	form.MaxLength("code", 20)
+
+	if !form.Valid() {
+		return web.Render(e, w, r, "logincode.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+
+	ip, now := web.ClientIP(r), time.Now()
+	wait, err := ug.user.CheckLogin(usr.Email, ip, now)
+	if err != nil {
+		return errors.Wrap(err, "checking login throttle")
+	}
+	if !wait.IsZero() {
+		e.Metrics.CountLogin(metrics.LoginThrottled)
+		clearPending(e, r)
+		e.Session.Put(r.Context(), "flash", "Too many failed logins. Please try again later.")
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return nil
+	}
+
+	recovery, err := ug.useCode(usr, form.Get("code"), now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidCode:
+			e.Metrics.CountLogin(metrics.LoginWrongCode)
+			_, locked, err := ug.user.RecordFailedLogin(usr.Email, ip, user.LoginWrongCode, now)
+			if err != nil {
+				return errors.Wrap(err, "recording login attempt")
+			}
+			if locked {
+				ug.notifyLockout(e, r, usr)
+			}
+			attempts := e.Session.GetInt(r.Context(), "pendingAttempts") + 1
+			if locked || attempts >= maxCodeAttempts {
+				clearPending(e, r)
+				e.Session.Put(r.Context(), "flash", "Too many wrong codes. Please log in again.")
+				http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+				return nil
+			}
+			e.Session.Put(r.Context(), "pendingAttempts", attempts)
+			form.Errors.Add("code", "Code is incorrect")
+			return web.Render(e, w, r, "logincode.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+		default:
+			return errors.Wrapf(err, "checking code for ID : %s", userID)
+		}
+	}
+
+	clearPending(e, r)
+	if err := e.Session.RenewToken(r.Context()); err != nil {
+		return errors.Wrap(err, "renewing session token")
+	}
+	if recovery {
+		e.Session.Put(r.Context(), "flash", "You logged in with a recovery code, it can't be used again.")
+	}
+	path, err := ug.completeLogin(e, r, usr)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, path, http.StatusSeeOther)
+	return nil
+}
+
+// useCode uses up a code of a user with two-factor authentication. Codes of
+// exactly 6 digits are from the authenticator app, anything else is checked
+// as a recovery code.
+func (ug userGroup) useCode(usr user.Info, code string, now time.Time) (recovery bool, err error) {
+	if !totp.IsCode(code) {
+		return true, ug.user.UseRecoveryCode(usr.ID, code, now)
+	}
+	step, ok := totp.Validate(usr.TOTPSecret, code, now, usr.TOTPLastStep)
+	if !ok {
+		return false, user.ErrInvalidCode
+	}
+	return false, ug.user.UseTOTPStep(usr.ID, step)
+}
+
+// twoFactor shows the two-factor settings of the user. Users who have not
+// enabled it yet get a new secret the first time they open the page, which is
+// kept until they enable it, so that reloading the page or opening it in a
+// second tab doesn't replace the secret they are adding to their app.
+func (ug userGroup) twoFactor(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	if usr.TOTPEnabledAt != nil {
+		left, err := ug.user.QueryRecoveryCodesLeft(userID)
+		if err != nil {
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+		tf := &user.TwoFactor{RecoveryCodesLeft: left}
+		td := &data.TemplateData{User: &usr, TwoFactor: tf, Form: forms.New(nil), Confirmed: hasConfirmed(e, r, userID)}
+		return web.Render(e, w, r, "twofactor.page.tmpl", td, http.StatusOK)
+	}
+
+	secret := usr.TOTPSecret
+	if secret == "" {
+		if secret, err = totp.GenerateSecret(); err != nil {
+			return err
+		}
+		if err := ug.user.SetTOTPSecret(userID, secret); err != nil {
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	tf := &user.TwoFactor{Secret: secret, URI: totp.URI(totpIssuer, usr.Email, secret)}
+	return web.Render(e, w, r, "twofactor.page.tmpl", &data.TemplateData{User: &usr, TwoFactor: tf, Form: forms.New(nil)}, http.StatusOK)
+}
+
+// enableTwoFactor turns on two-factor authentication once the user entered a
+// correct code for the new secret and shows the recovery codes once.
+func (ug userGroup) enableTwoFactor(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+	if usr.TOTPEnabledAt != nil || usr.TOTPSecret == "" {
+		http.Redirect(w, r, "/users/two-factor", http.StatusSeeOther)
+		return nil
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+
+	step, ok := totp.Validate(usr.TOTPSecret, form.Get("code"), time.Now(), 0)
+	if !ok {
+		form.Errors.Add("code", "Code is incorrect")
+	}
+	if !form.Valid() {
+		tf := &user.TwoFactor{Secret: usr.TOTPSecret, URI: totp.URI(totpIssuer, usr.Email, usr.TOTPSecret)}
+		return web.Render(e, w, r, "twofactor.page.tmpl", &data.TemplateData{User: &usr, TwoFactor: tf, Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	codes, err := ug.user.EnableTOTP(userID, step, time.Now())
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrTOTPEnabled:
+			http.Redirect(w, r, "/users/two-factor", http.StatusSeeOther)
+			return nil
+		default:
+			return errors.Wrapf(err, "enabling two-factor authentication for ID : %s", userID)
+		}
+	}
+
+	usr, err = ug.user.QueryByID(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+	tf := &user.TwoFactor{RecoveryCodes: codes, RecoveryCodesLeft: len(codes)}
+	return web.Render(e, w, r, "twofactor.page.tmpl", &data.TemplateData{User: &usr, TwoFactor: tf, Form: forms.New(nil)}, http.StatusOK)
+}
+
+func (ug userGroup) disableTwoFactor(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+	if usr.TOTPEnabledAt == nil {
+		http.Redirect(w, r, "/users/two-factor", http.StatusSeeOther)
+		return nil
+	}
+
+	form := forms.New(r.PostForm)
+	ok, err := ug.confirmUser(e, w, r, usr, form)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := ug.user.DisableTOTP(userID); err != nil {
+			return errors.Wrapf(err, "disabling two-factor authentication for ID : %s", userID)
+		}
+		e.Session.Put(r.Context(), "flash", "Two-factor authentication is turned off.")
+		http.Redirect(w, r, "/users/profile", http.StatusSeeOther)
+		return nil
+	}
+
+	left, err := ug.user.QueryRecoveryCodesLeft(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+	tf := &user.TwoFactor{RecoveryCodesLeft: left}
+	return web.Render(e, w, r, "twofactor.page.tmpl", &data.TemplateData{User: &usr, TwoFactor: tf, Form: form}, http.StatusUnprocessableEntity)
+}
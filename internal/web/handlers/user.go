@@ -1,30 +1,118 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/mail"
 	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/setting"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
+	"github.com/sophiabrandt/go-maybe-list/internal/notify"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/ratelimit"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/token"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
+// verifyTTL is how long the link in a verification email stays valid.
+const verifyTTL = 24 * time.Hour
+
 type userGroup struct {
 	user interface {
 		QueryByID(userID string) (user.Info, error)
 		Create(user user.NewUser) (user.Info, error)
+		CreateInvited(user user.NewUser, code string, now time.Time) (user.Info, error)
+		CreateInvite(userID string, quota int, now time.Time) (user.Invite, error)
+		QueryInvites(userID string) (user.Invites, error)
+		DeleteInvite(code, userID string) error
+		QueryByEmail(email string) (user.Info, error)
 		Authenticate(email, password string) (string, error)
+		Verify(userID string) error
+		CreateReset(email string, now time.Time) (string, user.Info, error)
+		CheckReset(tok string, now time.Time) error
+		ResetPassword(tok, newPassword string, now time.Time) error
+		SetTOTPSecret(userID, secret string) error
+		EnableTOTP(userID string, step int64, now time.Time) ([]string, error)
+		UseTOTPStep(userID string, step int64) error
+		UseRecoveryCode(userID, code string, now time.Time) error
+		QueryRecoveryCodesLeft(userID string) (int, error)
+		DisableTOTP(userID string) error
+		CheckLogin(email, ip string, now time.Time) (time.Time, error)
+		RecordFailedLogin(email, ip, reason string, now time.Time) (user.Info, bool, error)
+		ResetFailedLogins(userID string) error
+		CreateSession(userID, ip, userAgent string, now time.Time) (user.Session, error)
+		QuerySessions(userID string) (user.Sessions, error)
+		RevokeSession(sessionID, userID string) error
+		RevokeSessions(userID, exceptID string) error
 		ChangePassword(currentPassword, newPassword, userID string) error
+		CheckPassword(password, userID string) error
+		ScheduleDeletion(userID string, now time.Time) (time.Time, error)
+		CancelDeletion(userID string) error
+		RequestExport(userID string, now time.Time) (user.Export, error)
+		QueryExports(userID string) (user.Exports, error)
+		QueryExportArchive(exportID, userID string) ([]byte, error)
+		UpdateDigest(ds user.DigestSettings, userID string) error
+	}
+	digest interface {
+		Preview(u user.Info, now time.Time) (mail.Message, error)
+	}
+	// resets limits password reset requests per IP address
+	resets *ratelimit.Limiter
+	// verifications limits verification emails per IP and email address
+	verifications *ratelimit.Limiter
+	// notifier tells users that their account was locked or will be deleted
+	notifier notify.Notifier
+	settings interface {
+		Query() (setting.Settings, error)
 	}
 }
 
 func (ug userGroup) signupForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
-	return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: forms.New(nil)}, http.StatusOK)
+	settings, err := ug.settings.Query()
+	if err != nil {
+		return errors.Wrap(err, "querying settings")
+	}
+
+	// invite links carry the code, so that it doesn't have to be typed
+	form := forms.New(url.Values{"invite": {r.URL.Query().Get("invite")}})
+	return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form, Settings: &settings}, http.StatusOK)
+}
+
+// checkSignup returns an error if someone with the email address may not sign
+// up without a form, as with OpenID Connect.
+func (ug userGroup) checkSignup(email string) error {
+	settings, err := ug.settings.Query()
+	if err != nil {
+		return errors.Wrap(err, "querying settings")
+	}
+	switch {
+	case settings.Closed():
+		return user.ErrRegistrationClosed
+	case settings.InviteOnly():
+		return user.ErrInviteRequired
+	case settings.DomainOnly() && !settings.AllowsDomain(email):
+		return user.ErrDomainNotAllowed
+	}
+	return nil
 }
 
 func (ug userGroup) signup(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	settings, err := ug.settings.Query()
+	if err != nil {
+		return errors.Wrap(err, "querying settings")
+	}
+	if settings.Closed() {
+		return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Settings: &settings}, http.StatusForbidden)
+	}
+
 	// form validation
 	form := forms.New(r.PostForm)
 	form.Required("name", "email", "password")
@@ -32,9 +120,15 @@ func (ug userGroup) signup(e *env.Env, w http.ResponseWriter, r *http.Request) e
 	form.MaxLength("name", 255)
 	form.SecurePassword("password")
 	form.IsEqualString("password", "confirm password")
+	if settings.InviteOnly() {
+		form.Required("invite")
+	}
+	if settings.DomainOnly() && form.Errors.Get("email") == "" && !settings.AllowsDomain(form.Get("email")) {
+		form.Errors.Add("email", "Only email addresses at the allowed domains can sign up")
+	}
 
 	if !form.Valid() {
-		return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+		return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form, Settings: &settings}, http.StatusUnprocessableEntity)
 	}
 
 	nu := user.NewUser{
@@ -43,23 +137,121 @@ func (ug userGroup) signup(e *env.Env, w http.ResponseWriter, r *http.Request) e
 		Password:        form.Get("password"),
 		PasswordConfirm: form.Get("password_confirm"),
 	}
-	_, err := ug.user.Create(nu)
+	var usr user.Info
+	if settings.InviteOnly() {
+		usr, err = ug.user.CreateInvited(nu, form.Get("invite"), time.Now())
+	} else {
+		usr, err = ug.user.Create(nu)
+	}
 	if err != nil {
 		switch errors.Cause(err) {
 		case user.ErrDuplicateEmail:
 			form.Errors.Add("email", "Invalid email or email already in use")
-			return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+			return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form, Settings: &settings}, http.StatusUnprocessableEntity)
+		case user.ErrInvalidInvite:
+			form.Errors.Add("invite", "Invalid or already used invite code")
+			return web.Render(e, w, r, "signup.page.tmpl", &data.TemplateData{Form: form, Settings: &settings}, http.StatusUnprocessableEntity)
 		default:
 			return errors.Wrapf(err, "creating new user: %+v", nu)
 		}
 	}
 
-	e.Session.Put(r.Context(), "flash", "Signup successful. Please log in.")
+	// the account exists even if the email fails, the user can ask for a new one
+	if err := sendVerification(e, usr); err != nil {
+		e.Log.ErrorContext(r.Context(), "sending verification email", "user_id", usr.ID, "err", err)
+	}
+
+	e.Session.Put(r.Context(), "flash", "Signup successful. Please check your email to verify your address.")
+	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+	return nil
+}
+
+// sendVerification emails a signed link to verify the email address of a user.
+func sendVerification(e *env.Env, usr user.Info) error {
+	tok := token.Sign(e.Secret, "verify", usr.ID, time.Now().Add(verifyTTL))
+	msg, err := e.MailTemplates.Render("verify", usr.Email, struct {
+		Name string
+		Link string
+	}{
+		Name: usr.Name,
+		Link: e.BaseURL + "/users/verify?token=" + url.QueryEscape(tok),
+	})
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(msg)
+}
+
+func (ug userGroup) verify(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID, err := token.Verify(e.Secret, "verify", r.URL.Query().Get("token"), time.Now())
+	if err == nil {
+		err = ug.user.Verify(userID)
+	}
+	if err != nil {
+		switch errors.Cause(err) {
+		case token.ErrInvalid, token.ErrExpired, user.ErrInvalidID, user.ErrNotFound:
+			e.Session.Put(r.Context(), "flash", "The verification link is invalid or has expired. Please request a new one.")
+			http.Redirect(w, r, "/users/verify/resend", http.StatusSeeOther)
+			return nil
+		default:
+			return errors.Wrap(err, "verifying email address")
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Your email address is verified. Please log in.")
+	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+	return nil
+}
+
+func (ug userGroup) resendVerificationForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return web.Render(e, w, r, "resend.page.tmpl", &data.TemplateData{Form: forms.New(nil)}, http.StatusOK)
+}
+
+// resendVerification sends a new verification email. The response is the same
+// whether an unverified account exists or not, so it can't be used to find out
+// which email addresses are registered.
+func (ug userGroup) resendVerification(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MatchesPattern("email", forms.EmailRegex)
+
+	if !form.Valid() {
+		return web.Render(e, w, r, "resend.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	// both keys count, so neither one client nor many clients can flood an inbox
+	now := time.Now()
+	email := strings.ToLower(form.Get("email"))
+	if !ug.verifications.Allow("ip:"+web.ClientIP(r), now) || !ug.verifications.Allow("email:"+email, now) {
+		form.Errors.Add("generic", "Too many verification emails requested. Please try again later.")
+		return web.Render(e, w, r, "resend.page.tmpl", &data.TemplateData{Form: form}, http.StatusTooManyRequests)
+	}
+
+	usr, err := ug.user.QueryByEmail(form.Get("email"))
+	switch errors.Cause(err) {
+	case nil:
+		if usr.VerifiedAt == nil {
+			if err := sendVerification(e, usr); err != nil {
+				return errors.Wrapf(err, "sending verification email to user %s", usr.ID)
+			}
+		}
+	case user.ErrNotFound:
+	default:
+		return errors.Wrap(err, "resending verification email")
+	}
+
+	e.Session.Put(r.Context(), "flash", "If your address still needs to be verified, you'll get a new email shortly.")
 	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
 	return nil
 }
 
 func (ug userGroup) logout(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+	sessionID := e.Session.PopString(r.Context(), "sessionID")
+	if err := ug.user.RevokeSession(sessionID, userID); err != nil && errors.Cause(err) != user.ErrNotFound {
+		return errors.Wrapf(err, "revoking session of ID : %s", userID)
+	}
+
 	e.Session.Remove(r.Context(), "authenticatedUserID")
 	e.Session.Put(r.Context(), "flash", "You've been logged out successfully!")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -72,33 +264,115 @@ func (ug userGroup) loginForm(e *env.Env, w http.ResponseWriter, r *http.Request
 
 func (ug userGroup) login(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	form := forms.New(r.PostForm)
-	id, err := ug.user.Authenticate(form.Get("email"), form.Get("password"))
+	email, ip, now := form.Get("email"), web.ClientIP(r), time.Now()
+
+	wait, err := ug.user.CheckLogin(email, ip, now)
+	if err != nil {
+		return errors.Wrap(err, "checking login throttle")
+	}
+	if !wait.IsZero() {
+		e.Metrics.CountLogin(metrics.LoginThrottled)
+		if _, _, err := ug.user.RecordFailedLogin(email, ip, user.LoginThrottled, now); err != nil {
+			return errors.Wrap(err, "recording login attempt")
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Sub(now).Seconds())+1))
+		form.Errors.Add("generic", "Too many failed logins. Please try again later.")
+		return web.Render(e, w, r, "login.page.tmpl", &data.TemplateData{Form: form}, http.StatusTooManyRequests)
+	}
+
+	id, err := ug.user.Authenticate(email, form.Get("password"))
 	if err != nil {
 		switch errors.Cause(err) {
 		case user.ErrAuthenticationFailure:
+			e.Metrics.CountLogin(metrics.LoginWrongPassword)
+			usr, locked, err := ug.user.RecordFailedLogin(email, ip, user.LoginWrongPassword, now)
+			if err != nil {
+				return errors.Wrap(err, "recording login attempt")
+			}
+			if locked {
+				ug.notifyLockout(e, r, usr)
+			}
 			form.Errors.Add("generic", "Email or Password is incorrect")
 			return web.Render(e, w, r, "login.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+		case user.ErrNotVerified:
+			e.Metrics.CountLogin(metrics.LoginUnverified)
+			form.Errors.Add("verify", "Please verify your email address before logging in.")
+			return web.Render(e, w, r, "login.page.tmpl", &data.TemplateData{Form: form}, http.StatusForbidden)
 		default:
 			return errors.Wrap(err, "authenticating")
 		}
 	}
 
-	err = e.Session.RenewToken(r.Context())
+	usr, err := ug.user.QueryByID(id)
 	if err != nil {
-		return web.Render(e, w, r, "login.page.tmpl", &data.TemplateData{Form: form}, http.StatusInternalServerError)
+		return errors.Wrapf(err, "ID : %s", id)
 	}
-	e.Session.Put(r.Context(), "authenticatedUserID", id)
 
-	path := e.Session.PopString(r.Context(), "redirectPathAfterLogin")
-	if path != "" {
-		http.Redirect(w, r, path, http.StatusSeeOther)
-		return nil
+	path, err := ug.startLogin(e, r, usr)
+	if err != nil {
+		return err
 	}
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, path, http.StatusSeeOther)
 	return nil
 }
 
+// startLogin logs in a user whose identity was confirmed and returns the path
+// to redirect to. Users with two-factor authentication are logged in once they
+// entered a code.
+func (ug userGroup) startLogin(e *env.Env, r *http.Request, usr user.Info) (string, error) {
+	if err := e.Session.RenewToken(r.Context()); err != nil {
+		return "", errors.Wrap(err, "renewing session token")
+	}
+
+	if usr.TOTPEnabledAt != nil {
+		e.Session.Put(r.Context(), "pendingUserID", usr.ID)
+		e.Session.Put(r.Context(), "pendingUntil", time.Now().Add(pendingTTL).Unix())
+		e.Session.Put(r.Context(), "pendingAttempts", 0)
+		return "/users/login/code", nil
+	}
+
+	return ug.completeLogin(e, r, usr)
+}
+
+// notifyLockout tells the owner of an account that it was locked after too many
+// failed logins. The login goes on if the notification fails.
+func (ug userGroup) notifyLockout(e *env.Env, r *http.Request, usr user.Info) {
+	n := notify.Notification{
+		UserID:  usr.ID,
+		Email:   usr.Email,
+		Subject: "Your account was locked",
+		Message: fmt.Sprintf("After %d failed logins, your account is locked for %.0f minutes. If this wasn't you, reset your password.", user.LockoutAfter, user.LockoutDuration.Minutes()),
+		Link:    "/users/forgot-password",
+	}
+	if err := ug.notifier.Notify(r.Context(), n); err != nil {
+		e.Log.ErrorContext(r.Context(), "notifying user about lockout", "user_id", usr.ID, "err", err)
+	}
+}
+
+// completeLogin clears the failed logins, records the session, adds the user
+// to it and returns the path of the page the user wanted to see before logging
+// in. The session token must have been renewed.
+func (ug userGroup) completeLogin(e *env.Env, r *http.Request, usr user.Info) (string, error) {
+	if err := ug.user.ResetFailedLogins(usr.ID); err != nil {
+		return "", errors.Wrapf(err, "ID : %s", usr.ID)
+	}
+
+	sess, err := ug.user.CreateSession(usr.ID, web.ClientIP(r), r.UserAgent(), time.Now())
+	if err != nil {
+		return "", errors.Wrapf(err, "creating session for ID : %s", usr.ID)
+	}
+
+	e.Session.Put(r.Context(), "authenticatedUserID", usr.ID)
+	e.Session.Put(r.Context(), "sessionVersion", usr.SessionVersion)
+	e.Session.Put(r.Context(), "sessionID", sess.ID)
+	e.Metrics.CountLogin(metrics.LoginSuccess)
+
+	if path := e.Session.PopString(r.Context(), "redirectPathAfterLogin"); path != "" {
+		return path, nil
+	}
+	return "/", nil
+}
+
 func (ug userGroup) profile(e *env.Env, w http.ResponseWriter, r *http.Request) error {
 	id := web.ParamByName(r, "id")
 	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
@@ -113,7 +387,58 @@ func (ug userGroup) profile(e *env.Env, w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	return web.Render(e, w, r, "profile.page.tmpl", &data.TemplateData{User: &usr}, http.StatusOK)
+	sessions, err := ug.user.QuerySessions(userID)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", userID)
+	}
+	current := e.Session.GetString(r.Context(), "sessionID")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	return web.Render(e, w, r, "profile.page.tmpl", &data.TemplateData{User: &usr, Sessions: sessions}, http.StatusOK)
+}
+
+func (ug userGroup) revokeSession(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ug.user.RevokeSession(id, userID); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "revoking session %s of ID : %s", id, userID)
+		}
+	}
+
+	// revoking the current session is the same as logging out
+	if id == e.Session.GetString(r.Context(), "sessionID") {
+		e.Session.Remove(r.Context(), "authenticatedUserID")
+		e.Session.Remove(r.Context(), "sessionID")
+		e.Session.Put(r.Context(), "flash", "You've been logged out successfully!")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	e.Session.Put(r.Context(), "flash", "Session logged out.")
+	http.Redirect(w, r, "/users/profile", http.StatusSeeOther)
+	return nil
+}
+
+// revokeAllSessions logs the user out everywhere, including the current session.
+func (ug userGroup) revokeAllSessions(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ug.user.RevokeSessions(userID, ""); err != nil {
+		return errors.Wrapf(err, "revoking sessions of ID : %s", userID)
+	}
+
+	e.Session.Remove(r.Context(), "authenticatedUserID")
+	e.Session.Remove(r.Context(), "sessionID")
+	e.Session.Put(r.Context(), "flash", "You've been logged out everywhere.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
 }
 
 func (ug userGroup) changePasswordForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
@@ -145,8 +470,168 @@ func (ug userGroup) changePassword(e *env.Env, w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	// a new password logs out all other sessions, someone else might know the old one
+	if err := ug.user.RevokeSessions(userID, e.Session.GetString(r.Context(), "sessionID")); err != nil {
+		return errors.Wrapf(err, "revoking sessions of ID : %s", userID)
+	}
+
 	e.Session.Put(r.Context(), "flash", "Password successfully updated!")
 
 	http.Redirect(w, r, "/users/profile", http.StatusSeeOther)
 	return nil
 }
+
+func (ug userGroup) updateDigest(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("weekday", "hour")
+	form.PermittedValues("weekday", "0", "1", "2", "3", "4", "5", "6")
+	form.IntegerRange("hour", 0, 23)
+
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid digest form"), Code: http.StatusBadRequest}
+	}
+
+	ds := user.DigestSettings{Enabled: form.Get("enabled") == "on"}
+	ds.Weekday, _ = strconv.Atoi(form.Get("weekday"))
+	ds.Hour, _ = strconv.Atoi(form.Get("hour"))
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := ug.user.UpdateDigest(ds, userID); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidDigest:
+			return web.StatusError{Err: err, Code: http.StatusBadRequest}
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "updating digest settings for ID : %s", userID)
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Digest settings successfully saved!")
+
+	http.Redirect(w, r, "/users/profile", http.StatusSeeOther)
+	return nil
+}
+
+// previewDigest renders the next digest of the user without sending it.
+func (ug userGroup) previewDigest(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	usr, err := ug.user.QueryByID(userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case user.ErrNotFound:
+			return web.StatusError{Err: err, Code: http.StatusNotFound}
+		default:
+			return errors.Wrapf(err, "ID : %s", userID)
+		}
+	}
+
+	msg, err := ug.digest.Preview(usr, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "rendering digest for ID : %s", userID)
+	}
+
+	return web.Render(e, w, r, "digest.page.tmpl", &data.TemplateData{User: &usr, Email: &msg}, http.StatusOK)
+}
+
+func (ug userGroup) forgotPasswordForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return web.Render(e, w, r, "forgot.page.tmpl", &data.TemplateData{Form: forms.New(nil)}, http.StatusOK)
+}
+
+// forgotPassword emails a password reset link. Like resending the verification
+// email, the response does not tell whether an account exists.
+func (ug userGroup) forgotPassword(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MatchesPattern("email", forms.EmailRegex)
+
+	if !form.Valid() {
+		return web.Render(e, w, r, "forgot.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	now := time.Now()
+	if !ug.resets.Allow(web.ClientIP(r), now) {
+		form.Errors.Add("generic", "Too many password reset requests. Please try again later.")
+		return web.Render(e, w, r, "forgot.page.tmpl", &data.TemplateData{Form: form}, http.StatusTooManyRequests)
+	}
+
+	tok, usr, err := ug.user.CreateReset(form.Get("email"), now)
+	switch errors.Cause(err) {
+	case nil:
+		// a failing email gets the same response, so it can't tell that the account exists
+		if err := sendReset(e, usr, tok); err != nil {
+			e.Log.ErrorContext(r.Context(), "sending password reset email", "user_id", usr.ID, "err", err)
+		}
+	case user.ErrNotFound:
+	case user.ErrTooManyResets:
+		e.Log.WarnContext(r.Context(), "too many password resets", "user_id", usr.ID)
+	default:
+		return errors.Wrap(err, "creating password reset")
+	}
+
+	e.Session.Put(r.Context(), "flash", "If an account exists for this address, you'll get an email with a link to reset your password shortly.")
+	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+	return nil
+}
+
+// sendReset emails a link with a password reset token to a user.
+func sendReset(e *env.Env, usr user.Info, tok string) error {
+	msg, err := e.MailTemplates.Render("reset", usr.Email, struct {
+		Name string
+		Link string
+	}{
+		Name: usr.Name,
+		Link: e.BaseURL + "/users/reset-password?token=" + url.QueryEscape(tok),
+	})
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(msg)
+}
+
+func (ug userGroup) resetPasswordForm(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	tok := r.URL.Query().Get("token")
+	if err := ug.user.CheckReset(tok, time.Now()); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidReset:
+			e.Session.Put(r.Context(), "flash", "The password reset link is invalid or has expired. Please request a new one.")
+			http.Redirect(w, r, "/users/forgot-password", http.StatusSeeOther)
+			return nil
+		default:
+			return errors.Wrap(err, "checking password reset")
+		}
+	}
+
+	form := forms.New(url.Values{"token": {tok}})
+	return web.Render(e, w, r, "reset.page.tmpl", &data.TemplateData{Form: form}, http.StatusOK)
+}
+
+func (ug userGroup) resetPassword(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("token", "password", "confirm password")
+	form.SecurePassword("password")
+	form.IsEqualString("password", "confirm password")
+
+	if !form.Valid() {
+		return web.Render(e, w, r, "reset.page.tmpl", &data.TemplateData{Form: form}, http.StatusUnprocessableEntity)
+	}
+
+	if err := ug.user.ResetPassword(form.Get("token"), form.Get("password"), time.Now()); err != nil {
+		switch errors.Cause(err) {
+		case user.ErrInvalidReset:
+			e.Session.Put(r.Context(), "flash", "The password reset link is invalid or has expired. Please request a new one.")
+			http.Redirect(w, r, "/users/forgot-password", http.StatusSeeOther)
+			return nil
+		default:
+			return errors.Wrap(err, "resetting password")
+		}
+	}
+
+	// the reset logged out all sessions, including this one if there was one
+	e.Session.Remove(r.Context(), "authenticatedUserID")
+	e.Session.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"html"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/logger"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/session"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/templates"
+)
+
+var csrfTokenRegex = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func TestLoginThrottleByForwardedIP(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "handlers.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := templates.NewCache("../../../ui/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := logger.New(io.Discard, logger.FormatText, slog.LevelError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test server is the proxy, every request comes from localhost
+	e := env.New(log, tc, session.New())
+	e.TrustedProxies = []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)}}
+	srv := httptest.NewTLSServer(New(e, db))
+	t.Cleanup(srv.Close)
+	c := srv.Client()
+	c.Jar, _ = cookiejar.New(nil)
+
+	resp, err := c.Get(srv.URL + "/users/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	m := csrfTokenRegex.FindSubmatch(body)
+	if m == nil {
+		t.Fatalf("no CSRF token in %s", body)
+	}
+	csrfToken := html.UnescapeString(string(m[1]))
+
+	login := func(clientIP string) int {
+		t.Helper()
+		form := url.Values{"email": {"nobody@example.com"}, "password": {"wrong"}, "csrf_token": {csrfToken}}
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/users/login", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Referer", srv.URL+"/users/login")
+		req.Header.Set("X-Forwarded-For", clientIP)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := login("203.0.113.1"); code != http.StatusUnprocessableEntity {
+			t.Fatalf("failed login %d: want status %d; got %d", i+1, http.StatusUnprocessableEntity, code)
+		}
+	}
+	if code := login("203.0.113.1"); code != http.StatusTooManyRequests {
+		t.Errorf("same client: want status %d; got %d", http.StatusTooManyRequests, code)
+	}
+	if code := login("198.51.100.2"); code != http.StatusUnprocessableEntity {
+		t.Errorf("other client: want status %d; got %d", http.StatusUnprocessableEntity, code)
+	}
+}
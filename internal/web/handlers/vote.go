@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/vote"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+type voteGroup struct {
+	vote interface {
+		Vote(maybeID string, value int, userID string) error
+		CreateRound(nr vote.NewRound, workspaceID string, userID string) (vote.Round, error)
+		QueryRounds(workspaceID string, userID string) (vote.Rounds, error)
+		QueryRoundByID(roundID string, userID string) (vote.Round, error)
+		QueryBallot(roundID string, userID string) (vote.Ballots, error)
+		CastDots(roundID string, maybeID string, dots int, userID string) error
+		QueryResults(roundID string, userID string) (vote.Results, error)
+	}
+}
+
+// voteError maps errors of the vote repository to HTTP errors.
+func voteError(err error, msg string) error {
+	switch errors.Cause(err) {
+	case vote.ErrInvalidID, vote.ErrInvalidVote:
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	case vote.ErrForbidden:
+		return web.StatusError{Err: err, Code: http.StatusForbidden}
+	case vote.ErrNotFound:
+		return web.StatusError{Err: err, Code: http.StatusNotFound}
+	default:
+		return errors.Wrap(err, msg)
+	}
+}
+
+func (vg voteGroup) voteMaybe(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+
+	form := forms.New(r.PostForm)
+	form.Required("value")
+	form.PermittedValues("value", "1", "0", "-1")
+
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid vote form"), Code: http.StatusBadRequest}
+	}
+
+	value, _ := strconv.Atoi(form.Get("value"))
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := vg.vote.Vote(id, value, userID); err != nil {
+		return voteError(err, fmt.Sprintf("voting for maybe with ID: %s", id))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/maybes/view/%s", id), http.StatusSeeOther)
+	return nil
+}
+
+func (vg voteGroup) getAllRounds(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return vg.renderRounds(e, w, r, forms.New(nil), http.StatusOK)
+}
+
+// renderRounds lists the voting rounds of the current workspace. The personal
+// space has no rounds.
+func (vg voteGroup) renderRounds(e *env.Env, w http.ResponseWriter, r *http.Request, form *forms.Form, status int) error {
+	var rounds vote.Rounds
+	if workspaceID := web.CurrentWorkspaceID(r); workspaceID != "" {
+		userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+		var err error
+		rounds, err = vg.vote.QueryRounds(workspaceID, userID)
+		if err != nil {
+			return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+		}
+	}
+
+	return web.Render(e, w, r, "rounds.page.tmpl", &data.TemplateData{Rounds: rounds, Form: form}, status)
+}
+
+func (vg voteGroup) createRound(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("name", "budget", "closes")
+	form.MaxLength("name", 255)
+	form.PermittedValues("budget", "3", "5", "10")
+	form.PermittedValues("closes", "1", "3", "7", "14")
+
+	if !form.Valid() {
+		return vg.renderRounds(e, w, r, form, http.StatusUnprocessableEntity)
+	}
+
+	workspaceID := web.CurrentWorkspaceID(r)
+	if workspaceID == "" {
+		return web.StatusError{Err: errors.New("voting rounds need a workspace"), Code: http.StatusBadRequest}
+	}
+
+	budget, _ := strconv.Atoi(form.Get("budget"))
+	days, _ := strconv.Atoi(form.Get("closes"))
+	nr := vote.NewRound{
+		Name:     strings.TrimSpace(form.Get("name")),
+		Budget:   budget,
+		ClosesAt: time.Now().UTC().AddDate(0, 0, days),
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	round, err := vg.vote.CreateRound(nr, workspaceID, userID)
+	if err != nil {
+		return voteError(err, "creating new voting round")
+	}
+
+	e.Session.Put(r.Context(), "flash", "Voting round successfully started!")
+
+	http.Redirect(w, r, fmt.Sprintf("/rounds/view/%s", round.ID), http.StatusSeeOther)
+	return nil
+}
+
+func (vg voteGroup) getRoundByID(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	round, err := vg.vote.QueryRoundByID(id, userID)
+	if err != nil {
+		return voteError(err, fmt.Sprintf("selecting voting round with ID: %s", id))
+	}
+
+	ballots, err := vg.vote.QueryBallot(id, userID)
+	if err != nil {
+		return voteError(err, fmt.Sprintf("selecting ballot for voting round with ID: %s", id))
+	}
+
+	return web.Render(e, w, r, "round_detail.page.tmpl", &data.TemplateData{Round: &round, Ballots: ballots}, http.StatusOK)
+}
+
+func (vg voteGroup) castDots(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+
+	form := forms.New(r.PostForm)
+	form.Required("maybe", "dots")
+
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid dots form"), Code: http.StatusBadRequest}
+	}
+
+	dots, err := strconv.Atoi(form.Get("dots"))
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	}
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	err = vg.vote.CastDots(id, form.Get("maybe"), dots, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case vote.ErrRoundClosed:
+			e.Session.Put(r.Context(), "flash", "The voting round is closed.")
+		case vote.ErrBudgetExceeded:
+			e.Session.Put(r.Context(), "flash", "You do not have enough dots left.")
+		default:
+			return voteError(err, fmt.Sprintf("casting dots in voting round with ID: %s", id))
+		}
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/rounds/view/%s", id), http.StatusSeeOther)
+	return nil
+}
+
+func (vg voteGroup) getRoundResults(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	round, err := vg.vote.QueryRoundByID(id, userID)
+	if err != nil {
+		return voteError(err, fmt.Sprintf("selecting voting round with ID: %s", id))
+	}
+
+	results, err := vg.vote.QueryResults(id, userID)
+	if err != nil {
+		return voteError(err, fmt.Sprintf("selecting results for voting round with ID: %s", id))
+	}
+
+	return web.Render(e, w, r, "round_results.page.tmpl", &data.TemplateData{Round: &round, Results: results}, http.StatusOK)
+}
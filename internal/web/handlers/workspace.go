@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sophiabrandt/go-maybe-list/internal/data"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/forms"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
+)
+
+type workspaceGroup struct {
+	workspace interface {
+		Query(userID string) (workspace.Infos, error)
+		QueryByID(workspaceID string, userID string) (workspace.Info, error)
+		Create(nw workspace.NewWorkspace, userID string) (workspace.Info, error)
+		Delete(workspaceID string, userID string) error
+		QueryMembers(workspaceID string, userID string) (workspace.Members, error)
+		UpdateRole(workspaceID string, memberID string, role string, userID string) error
+		RemoveMember(workspaceID string, memberID string, userID string) error
+		Invite(workspaceID string, ni workspace.NewInvite, userID string) (workspace.Invite, error)
+		QueryInvites(workspaceID string, userID string) (workspace.Invites, error)
+		QueryInvitesForUser(userID string) (workspace.Invites, error)
+		AcceptInvite(inviteID string, userID string) (workspace.Invite, error)
+		DeclineInvite(inviteID string, userID string) error
+		RevokeInvite(workspaceID string, inviteID string, userID string) error
+	}
+	user interface {
+		QueryByID(userID string) (user.Info, error)
+	}
+}
+
+// workspaceError maps errors of the workspace repository to HTTP errors.
+func workspaceError(err error, msg string) error {
+	switch errors.Cause(err) {
+	case workspace.ErrInvalidID, workspace.ErrInvalidRole:
+		return web.StatusError{Err: err, Code: http.StatusBadRequest}
+	case workspace.ErrForbidden:
+		return web.StatusError{Err: err, Code: http.StatusForbidden}
+	case workspace.ErrNotFound:
+		return web.StatusError{Err: err, Code: http.StatusNotFound}
+	default:
+		return errors.Wrap(err, msg)
+	}
+}
+
+func (wg workspaceGroup) getAllWorkspaces(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return wg.renderWorkspaces(e, w, r, forms.New(nil), http.StatusOK)
+}
+
+func (wg workspaceGroup) renderWorkspaces(e *env.Env, w http.ResponseWriter, r *http.Request, form *forms.Form, status int) error {
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	invites, err := wg.workspace.QueryInvitesForUser(userID)
+	if err != nil {
+		return web.StatusError{Err: err, Code: http.StatusInternalServerError}
+	}
+
+	return web.Render(e, w, r, "workspaces.page.tmpl", &data.TemplateData{Invites: invites, Form: form}, status)
+}
+
+func (wg workspaceGroup) createWorkspace(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 255)
+
+	if !form.Valid() {
+		return wg.renderWorkspaces(e, w, r, form, http.StatusUnprocessableEntity)
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	ws, err := wg.workspace.Create(workspace.NewWorkspace{Name: strings.TrimSpace(form.Get("name"))}, userID)
+	if err != nil {
+		return errors.Wrap(err, "creating new workspace")
+	}
+
+	e.Session.Put(r.Context(), "flash", "Workspace successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", ws.ID), http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) getWorkspaceByID(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	return wg.renderWorkspace(e, w, r, forms.New(nil), http.StatusOK)
+}
+
+func (wg workspaceGroup) renderWorkspace(e *env.Env, w http.ResponseWriter, r *http.Request, form *forms.Form, status int) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	ws, err := wg.workspace.QueryByID(id, userID)
+	if err != nil {
+		return workspaceError(err, fmt.Sprintf("ID : %s", id))
+	}
+
+	members, err := wg.workspace.QueryMembers(id, userID)
+	if err != nil {
+		return workspaceError(err, fmt.Sprintf("ID : %s", id))
+	}
+
+	var invites workspace.Invites
+	if ws.IsOwner() {
+		invites, err = wg.workspace.QueryInvites(id, userID)
+		if err != nil {
+			return workspaceError(err, fmt.Sprintf("ID : %s", id))
+		}
+	}
+
+	td := &data.TemplateData{Workspace: &ws, Members: members, Invites: invites, Form: form}
+	return web.Render(e, w, r, "workspace_detail.page.tmpl", td, status)
+}
+
+func (wg workspaceGroup) deleteWorkspace(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := wg.workspace.Delete(id, userID); err != nil {
+		return workspaceError(err, fmt.Sprintf("deleting workspace with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "flash", "Workspace successfully deleted!")
+
+	http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+	return nil
+}
+
+// switchWorkspace changes the space whose maybes are shown. An empty workspace
+// switches back to the personal space.
+func (wg workspaceGroup) switchWorkspace(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.PostForm)
+	id := form.Get("workspace")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if id == "" {
+		e.Session.Remove(r.Context(), "currentWorkspaceID")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	if _, err := wg.workspace.QueryByID(id, userID); err != nil {
+		return workspaceError(err, fmt.Sprintf("switching to workspace with ID: %s", id))
+	}
+	e.Session.Put(r.Context(), "currentWorkspaceID", id)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) inviteMember(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	form.Required("email", "role")
+	form.MatchesPattern("email", forms.EmailRegex)
+	form.PermittedValues("role", workspace.RoleOwner, workspace.RoleEditor, workspace.RoleViewer)
+
+	if !form.Valid() {
+		return wg.renderWorkspace(e, w, r, form, http.StatusUnprocessableEntity)
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	ni := workspace.NewInvite{Email: form.Get("email"), Role: form.Get("role")}
+	inv, err := wg.workspace.Invite(id, ni, userID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case workspace.ErrDuplicateInvite:
+			form.Errors.Add("email", "This email has already been invited or is a member")
+			return wg.renderWorkspace(e, w, r, form, http.StatusUnprocessableEntity)
+		default:
+			return workspaceError(err, fmt.Sprintf("inviting %q to workspace with ID: %s", ni.Email, id))
+		}
+	}
+
+	// the invitation also shows up when the invitee logs in, even if the email fails
+	if err := wg.sendInvite(e, inv); err != nil {
+		e.Log.ErrorContext(r.Context(), "sending workspace invitation", "invite_id", inv.ID, "err", err)
+	}
+
+	e.Session.Put(r.Context(), "flash", "Invitation successfully sent!")
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+	return nil
+}
+
+// sendInvite emails an invitation to a workspace with a link to the page where
+// the invitee can accept or decline it.
+func (wg workspaceGroup) sendInvite(e *env.Env, inv workspace.Invite) error {
+	inviter, err := wg.user.QueryByID(inv.InvitedBy)
+	if err != nil {
+		return errors.Wrapf(err, "ID : %s", inv.InvitedBy)
+	}
+	msg, err := e.MailTemplates.Render("invite", inv.Email, struct {
+		Inviter   string
+		Workspace string
+		Role      string
+		Link      string
+	}{
+		Inviter:   inviter.Name,
+		Workspace: inv.WorkspaceName,
+		Role:      inv.Role,
+		Link:      e.BaseURL + "/workspaces",
+	})
+	if err != nil {
+		return err
+	}
+	return e.Mailer.Send(msg)
+}
+
+func (wg workspaceGroup) revokeInvite(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := wg.workspace.RevokeInvite(id, form.Get("invite"), userID); err != nil {
+		return workspaceError(err, fmt.Sprintf("revoking invite for workspace with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "flash", "Invitation successfully revoked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) updateMember(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	form.Required("member", "role")
+	form.PermittedValues("role", workspace.RoleOwner, workspace.RoleEditor, workspace.RoleViewer)
+
+	if !form.Valid() {
+		return web.StatusError{Err: errors.New("invalid member form"), Code: http.StatusBadRequest}
+	}
+
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := wg.workspace.UpdateRole(id, form.Get("member"), form.Get("role"), userID); err != nil {
+		switch errors.Cause(err) {
+		case workspace.ErrLastOwner:
+			e.Session.Put(r.Context(), "flash", "A workspace needs at least one owner.")
+			http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+			return nil
+		default:
+			return workspaceError(err, fmt.Sprintf("updating member of workspace with ID: %s", id))
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "Role successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) removeMember(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	form := forms.New(r.PostForm)
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+	memberID := form.Get("member")
+
+	if err := wg.workspace.RemoveMember(id, memberID, userID); err != nil {
+		switch errors.Cause(err) {
+		case workspace.ErrLastOwner:
+			e.Session.Put(r.Context(), "flash", "A workspace needs at least one owner.")
+			http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+			return nil
+		default:
+			return workspaceError(err, fmt.Sprintf("removing member of workspace with ID: %s", id))
+		}
+	}
+
+	if memberID == userID {
+		e.Session.Put(r.Context(), "flash", "You left the workspace.")
+		http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+		return nil
+	}
+
+	e.Session.Put(r.Context(), "flash", "Member successfully removed!")
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) leaveWorkspace(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := wg.workspace.RemoveMember(id, userID, userID); err != nil {
+		switch errors.Cause(err) {
+		case workspace.ErrLastOwner:
+			e.Session.Put(r.Context(), "flash", "A workspace needs at least one owner.")
+			http.Redirect(w, r, fmt.Sprintf("/workspaces/view/%v", id), http.StatusSeeOther)
+			return nil
+		default:
+			return workspaceError(err, fmt.Sprintf("leaving workspace with ID: %s", id))
+		}
+	}
+
+	e.Session.Put(r.Context(), "flash", "You left the workspace.")
+
+	http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) acceptInvite(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	inv, err := wg.workspace.AcceptInvite(id, userID)
+	if err != nil {
+		return workspaceError(err, fmt.Sprintf("accepting invite with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "currentWorkspaceID", inv.WorkspaceID)
+	e.Session.Put(r.Context(), "flash", fmt.Sprintf("Welcome to %s!", inv.WorkspaceName))
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+func (wg workspaceGroup) declineInvite(e *env.Env, w http.ResponseWriter, r *http.Request) error {
+	id := web.ParamByName(r, "id")
+	userID := e.Session.GetString(r.Context(), "authenticatedUserID")
+
+	if err := wg.workspace.DeclineInvite(id, userID); err != nil {
+		return workspaceError(err, fmt.Sprintf("declining invite with ID: %s", id))
+	}
+
+	e.Session.Put(r.Context(), "flash", "Invitation declined.")
+
+	http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+	return nil
+}
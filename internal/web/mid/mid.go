@@ -6,23 +6,37 @@ package mid
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"regexp"
 	"runtime/debug"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/justinas/nosurf"
 	"github.com/pkg/errors"
 	"github.com/sophiabrandt/go-maybe-list/internal/data/user"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/logger"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
 	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
+// requestIDHeader carries the ID of a request from proxies and to clients.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID matches request IDs from clients and proxies that are safe
+// to log and to send back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
 // responseWriter is a minimal wrapper for http.ResponseWriter that allows the
-// written HTTP status code to be captured for logging.
+// written HTTP status code and the number of bytes to be captured for logging.
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
+	bytes       int
 	wroteHeader bool
 }
 
@@ -46,24 +60,68 @@ func (rw *responseWriter) WriteHeader(code int) {
 	return
 }
 
-// LogRequest logs information about each request.
-func LogRequest(log *log.Logger) func(http.Handler) http.Handler {
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the wrapped response writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// RequestID gives each request an ID, the one in the X-Request-ID header if a
+// proxy or client sent a valid one. The ID is sent back in the same header
+// and logged with every line logged for the request.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), web.ContextKeyRequestID, id)
+		ctx = logger.NewContext(ctx, slog.String("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RealIP resolves the IP address of the client behind the trusted proxies
+// once, so that web.ClientIP returns it for the rest of the request. It goes
+// first, before anything that logs, limits or records the client.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			defer func() {
-				if err := recover(); err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					log.Printf(
-						"ERROR: %s, trace: %s", err, debug.Stack(),
-					)
-				}
-			}()
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), web.ContextKeyClientIP, web.ForwardedFor(r, trusted))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
+// LogRequest logs information about each request. It goes after RequestID
+// and before RecoverPanic, so requests that panicked are logged with status 500.
+func LogRequest(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			wrapped := wrapResponseWriter(w)
 			next.ServeHTTP(wrapped, r)
-			log.Printf(
-				"status: %d, method: %s, path: %s, duration: %s", wrapped.status, r.Method, r.URL.EscapedPath(), time.Since(start),
+
+			status := wrapped.status
+			if !wrapped.wroteHeader {
+				status = http.StatusOK
+			}
+			log.InfoContext(r.Context(), "request",
+				"method", r.Method,
+				"path", r.URL.EscapedPath(),
+				"status", status,
+				"bytes", wrapped.bytes,
+				"latency", time.Since(start),
 			)
 		}
 
@@ -71,20 +129,44 @@ func LogRequest(log *log.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// Metrics counts requests and their latency by the route pattern that routes
+// them, "unmatched" for requests that match none.
+func Metrics(m *metrics.App, routes interface {
+	Handler(*http.Request) (http.Handler, string)
+}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := wrapResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			status := wrapped.status
+			if !wrapped.wroteHeader {
+				status = http.StatusOK
+			}
+			_, route := routes.Handler(r)
+			if route == "" {
+				route = "unmatched"
+			}
+			m.ObserveRequest(route, status, time.Since(start))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
 // RecoverPanic closes a connection and returns an error response.
-func RecoverPanic(log *log.Logger) func(http.Handler) http.Handler {
+func RecoverPanic(log *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
-				if r := recover(); r != nil {
-					err := errors.Errorf("%v", r)
-					log.Printf(
-						"PANIC: %s, trace: %s", err, debug.Stack(),
-					)
+				if rec := recover(); rec != nil {
+					err := errors.Errorf("%v", rec)
+					log.ErrorContext(r.Context(), "panic", "err", err, "stack", string(debug.Stack()))
 					// Set a "Connection: close" header on the response.
 					w.Header().Set("Connection", "close")
 					// return internal server error
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					web.WriteError(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				}
 			}()
 
@@ -116,17 +198,72 @@ func Authenticate(e *env.Env, ur user.UserRepository) func(http.Handler) http.Ha
 
 			// check for existing user
 			usr, err := ur.QueryByID(e.Session.GetString(r.Context(), "authenticatedUserID"))
-			if errors.Is(err, user.ErrNotFound) || !usr.Active {
+			// a password reset logs out all older sessions of the user
+			if errors.Is(err, user.ErrNotFound) || !usr.Active || usr.SessionVersion != e.Session.GetInt(r.Context(), "sessionVersion") {
 				e.Session.Remove(r.Context(), "authenticatedUserID")
 				next.ServeHTTP(w, r)
 				return
 			} else if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				web.WriteError(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
 
-			// add authentication context key
+			// check that the session was not revoked
+			err = ur.TouchSession(e.Session.GetString(r.Context(), "sessionID"), usr.ID, web.ClientIP(r), time.Now())
+			if errors.Is(err, user.ErrNotFound) {
+				e.Session.Remove(r.Context(), "authenticatedUserID")
+				e.Session.Remove(r.Context(), "sessionID")
+				next.ServeHTTP(w, r)
+				return
+			} else if err != nil {
+				web.WriteError(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			logger.Add(r.Context(), slog.String("user_id", usr.ID))
+
+			// add authentication context keys
 			ctx := context.WithValue(r.Context(), web.ContextKeyIsAuthenticated, true)
+			ctx = context.WithValue(ctx, web.ContextKeyIsAdmin, usr.Role == user.RoleAdmin)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// LoadWorkspaces adds the workspaces of the authenticated user and the workspace
+// they switched to to the request context. A workspace the user is no longer a
+// member of is removed from the session.
+func LoadWorkspaces(e *env.Env, wr workspace.WorkspaceRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !web.IsAuthenticated(e, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			workspaces, err := wr.Query(e.Session.GetString(r.Context(), "authenticatedUserID"))
+			if err != nil {
+				web.WriteError(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), web.ContextKeyWorkspaces, workspaces)
+
+			currentID := e.Session.GetString(r.Context(), "currentWorkspaceID")
+			if currentID != "" {
+				var current *workspace.Info
+				for i := range workspaces {
+					if workspaces[i].ID == currentID {
+						current = &workspaces[i]
+					}
+				}
+				if current == nil {
+					e.Session.Remove(r.Context(), "currentWorkspaceID")
+				} else {
+					ctx = context.WithValue(ctx, web.ContextKeyCurrentWorkspace, current)
+				}
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 		return http.HandlerFunc(fn)
@@ -156,6 +293,22 @@ func RequireAuthentication(e *env.Env) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireAdmin responds with 403 Forbidden to users who are not admins. It
+// goes after RequireAuthentication.
+func RequireAdmin(e *env.Env) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !web.IsAdmin(r) {
+				web.WriteError(w, r, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
 // CSRF Protection middleware
 func NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -1,10 +1,21 @@
 package mid
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/env"
+	"github.com/sophiabrandt/go-maybe-list/internal/logger"
+	"github.com/sophiabrandt/go-maybe-list/internal/metrics"
+	"github.com/sophiabrandt/go-maybe-list/internal/web/web"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -53,3 +64,187 @@ func TestSecureHeaders(t *testing.T) {
 		t.Errorf("want body to equal %q", "OK")
 	}
 }
+
+func TestRequireAdmin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	for _, isAdmin := range []bool{false, true} {
+		rr := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, "/admin/stats", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), web.ContextKeyIsAdmin, isAdmin))
+
+		RequireAdmin(&env.Env{})(next).ServeHTTP(rr, r)
+
+		want := http.StatusForbidden
+		if isAdmin {
+			want = http.StatusOK
+		}
+		if rr.Code != want {
+			t.Errorf("admin %t: want %d; got %d", isAdmin, want, rr.Code)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, logger.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = web.RequestID(r)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("OK"))
+	})
+	h := RequestID(LogRequest(log)(next))
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"", false},
+		{"abc-123.proxy:1", true},
+		{"spaces are not allowed", false},
+		{strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		rr := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, "/maybes", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			r.Header.Set("X-Request-ID", tt.header)
+		}
+
+		h.ServeHTTP(rr, r)
+
+		if got == "" || (got == tt.header) != tt.keep {
+			t.Errorf("header %q: got request ID %q, keep %t", tt.header, got, tt.keep)
+		}
+		if rr.Header().Get("X-Request-ID") != got {
+			t.Errorf("header %q: response header %q, want %q", tt.header, rr.Header().Get("X-Request-ID"), got)
+		}
+
+		var line map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("header %q: %v in log %q", tt.header, err, buf.String())
+		}
+		if line["request_id"] != got || line["status"] != float64(http.StatusTeapot) || line["bytes"] != float64(2) {
+			t.Errorf("header %q: got log line %v", tt.header, line)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.NewApp()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /maybes/view/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	h := Metrics(m, mux)(mux)
+
+	for _, path := range []string{"/maybes/view/1", "/maybes/view/2", "/nowhere"} {
+		r, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Handler(slog.New(slog.NewTextHandler(ioutil.Discard, nil))).ServeHTTP(rr, r)
+	for _, want := range []string{
+		`maybelist_http_requests_total{route="GET /maybes/view/{id}",status="200"} 2`,
+		`maybelist_http_requests_total{route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(rr.Body.String(), want+"\n") {
+			t.Errorf("want %q in\n%s", want, rr.Body.String())
+		}
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, logger.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	h := LogRequest(log)(RecoverPanic(log)(next))
+
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/maybes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("want status %d; got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	// the panic is logged once, followed by the request with its status
+	var msgs []string
+	var status any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]any
+		if err := json.Unmarshal([]byte(l), &line); err != nil {
+			t.Fatalf("%v in log %q", err, buf.String())
+		}
+		msgs = append(msgs, line["msg"].(string))
+		if line["msg"] == "request" {
+			status = line["status"]
+		}
+	}
+	if strings.Join(msgs, ",") != "panic,request" || status != float64(http.StatusInternalServerError) {
+		t.Errorf("got log %q", buf.String())
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted := []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)}}
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = web.ClientIP(r)
+	})
+
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"127.0.0.1:1234", "203.0.113.7"},
+		{"198.51.100.1:1234", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = tt.remote
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.7")
+
+		RealIP(trusted)(next).ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != tt.want {
+			t.Errorf("from %q: want client IP %q; got %q", tt.remote, tt.want, got)
+		}
+	}
+}
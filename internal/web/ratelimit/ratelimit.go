@@ -0,0 +1,53 @@
+// Package ratelimit limits how often something can happen per key, e.g. per IP address.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to Max events per key within a sliding Window. It keeps
+// its state in memory, so limits reset when the app restarts.
+type Limiter struct {
+	Max    int
+	Window time.Duration
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// New creates a limiter for max events per window.
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{Max: max, Window: window, events: map[string][]time.Time{}}
+}
+
+// Allow records an event for the key and reports whether it is within the limit.
+// Events over the limit are not recorded, so clients that keep trying are let
+// through again once their earlier events leave the window.
+func (l *Limiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	if len(l.events[key]) >= l.Max {
+		return false
+	}
+	l.events[key] = append(l.events[key], now)
+	return true
+}
+
+// prune forgets events that left the window.
+func (l *Limiter) prune(now time.Time) {
+	for key, events := range l.events {
+		i := 0
+		for i < len(events) && now.Sub(events[i]) >= l.Window {
+			i++
+		}
+		if i == len(events) {
+			delete(l.events, key)
+		} else {
+			l.events[key] = events[i:]
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+
+	tests := []struct {
+		name string
+		key  string
+		at   time.Duration
+		want bool
+	}{
+		{"First", "a", 0, true},
+		{"Second", "a", 10 * time.Second, true},
+		{"Over the limit", "a", 20 * time.Second, false},
+		{"Other key", "b", 20 * time.Second, true},
+		{"First event left the window", "a", time.Minute, true},
+		{"Still over the limit", "a", time.Minute + time.Second, false},
+		{"All events left the window", "a", 3 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		if got := l.Allow(tt.key, start.Add(tt.at)); got != tt.want {
+			t.Errorf("%s: want %t; got %t", tt.name, tt.want, got)
+		}
+	}
+}
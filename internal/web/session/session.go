@@ -7,10 +7,14 @@ import (
 	"github.com/alexedwards/scs/v2"
 )
 
-// New creates the session manager.
+// Lifetime is how long a session lasts after logging in.
+const Lifetime = 12 * time.Hour
+
+// New creates the session manager. Its sessions are kept in memory unless
+// the Store is replaced, e.g. with a SQLiteStore.
 func New() *scs.SessionManager {
 	session := scs.New()
-	session.Lifetime = 12 * time.Hour
+	session.Lifetime = Lifetime
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteStrictMode
 	session.Cookie.Secure = true
@@ -0,0 +1,66 @@
+package session
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// SQLiteStore keeps session data in the sessions table of the database, so
+// sessions survive restarts and can be shared by several instances of the app.
+type SQLiteStore struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteStore creates a session store in the database. Expired sessions
+// stay in the table until DeleteExpired is called, e.g. by a scheduled job.
+func NewSQLiteStore(db *sqlx.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// Find returns the data of a session that has not expired.
+func (s *SQLiteStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+	const q = `SELECT data FROM sessions WHERE token = $1 AND expiry > $2`
+	if err := s.db.Get(&b, q, token, time.Now().UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(err, "selecting session")
+	}
+	return b, true, nil
+}
+
+// Commit adds or replaces the data of a session.
+func (s *SQLiteStore) Commit(token string, b []byte, expiry time.Time) error {
+	const q = `
+	INSERT INTO sessions
+		(token, data, expiry)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT(token) DO UPDATE SET
+		data = excluded.data,
+		expiry = excluded.expiry`
+	if _, err := s.db.Exec(q, token, b, expiry.UTC()); err != nil {
+		return errors.Wrap(err, "committing session")
+	}
+	return nil
+}
+
+// Delete removes a session. Deleting a session that does not exist is not an error.
+func (s *SQLiteStore) Delete(token string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE token = $1`, token); err != nil {
+		return errors.Wrap(err, "deleting session")
+	}
+	return nil
+}
+
+// DeleteExpired removes all sessions that expired before now.
+func (s *SQLiteStore) DeleteExpired(now time.Time) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expiry <= $1`, now.UTC()); err != nil {
+		return errors.Wrap(err, "deleting expired sessions")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/adapter/database"
+	"github.com/sophiabrandt/go-maybe-list/internal/data/schema"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "sessions.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := schema.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSQLiteStore(db)
+	now := time.Now()
+
+	if err := s.Commit("active", []byte("a"), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit("active", []byte("b"), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit("expired", []byte("c"), now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token string
+		want  string
+		found bool
+	}{
+		{"active", "b", true},
+		{"expired", "", false},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		b, found, err := s.Find(tt.token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != tt.found || string(b) != tt.want {
+			t.Errorf("%s: want %q, %t; got %q, %t", tt.token, tt.want, tt.found, b, found)
+		}
+	}
+
+	if err := s.DeleteExpired(now); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM sessions`); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("want 1 session after cleanup; got %d", count)
+	}
+
+	if err := s.Delete("active"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("active"); found {
+		t.Error("want deleted session to be gone")
+	}
+	if err := s.Delete("unknown"); err != nil {
+		t.Errorf("want no error deleting unknown session; got %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package templates
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
 	"html/template"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/yuin/goldmark"
 )
 
 // humanDate returns time in a friendlier format.
@@ -20,7 +27,48 @@ func humanDate(date string) string {
 	return day + " at " + hours
 }
 
-var functions = template.FuncMap{"humanDate": humanDate}
+// humanTime returns a time value in the same format as humanDate.
+func humanTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 at 15:04:05")
+}
+
+// humanSize returns a size in bytes in the largest unit it has at least one of.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGT"[exp])
+}
+
+// markdown renders user-provided markdown as HTML. Raw HTML and dangerous
+// links (e.g. javascript:) are stripped by goldmark's default renderer.
+func markdown(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(source), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+	return template.HTML(buf.String())
+}
+
+// qrCode renders content as a QR code in a PNG data URL for img tags.
+func qrCode(content string) template.URL {
+	png, err := qrcode.Encode(content, qrcode.Medium, 256)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+}
+
+var functions = template.FuncMap{"humanDate": humanDate, "humanTime": humanTime, "humanSize": humanSize, "markdown": markdown, "qrCode": qrCode}
 
 // NewCache creates a new cache.
 func NewCache(dir string) (map[string]*template.Template, error) {
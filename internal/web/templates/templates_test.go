@@ -32,3 +32,23 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		want string
+	}{
+		{name: "Bytes", size: 512, want: "512 B"},
+		{name: "KiB", size: 1536, want: "1.5 KiB"},
+		{name: "MiB", size: 70 * 1024 * 1024, want: "70.0 MiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hs := humanSize(tt.size); hs != tt.want {
+				t.Errorf("want %q; got %q", tt.want, hs)
+			}
+		})
+	}
+}
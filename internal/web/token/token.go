@@ -0,0 +1,71 @@
+// Package token creates and checks signed, expiring tokens for links in emails.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalid occurs when a token is malformed, has a wrong signature or was made for another purpose.
+	ErrInvalid = errors.New("token is not valid")
+
+	// ErrExpired occurs when a token is valid but has expired.
+	ErrExpired = errors.New("token has expired")
+)
+
+// Sign creates a token for a subject, e.g. a user ID, that is valid for one
+// purpose until it expires. The subject is readable by everyone holding the
+// token, only the signature keeps it from being changed.
+func Sign(secret []byte, purpose string, subject string, expires time.Time) string {
+	payload := strings.Join([]string{purpose, subject, strconv.FormatInt(expires.Unix(), 10)}, "|")
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(signature(secret, payload))
+}
+
+// Verify checks a token for a purpose and returns its subject.
+func Verify(secret []byte, purpose string, token string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", ErrInvalid
+	}
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if !hmac.Equal(sig, signature(secret, string(payload))) {
+		return "", ErrInvalid
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 || fields[0] != purpose {
+		return "", ErrInvalid
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if now.Unix() > expires {
+		return "", ErrExpired
+	}
+
+	return fields[1], nil
+}
+
+func signature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	valid := Sign(secret, "verify", "user-1", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		purpose string
+		token   string
+		now     time.Time
+		want    string
+		wantErr error
+	}{
+		{"Valid", secret, "verify", valid, now, "user-1", nil},
+		{"Expired", secret, "verify", valid, now.Add(2 * time.Hour), "", ErrExpired},
+		{"Other secret", []byte("other"), "verify", valid, now, "", ErrInvalid},
+		{"Other purpose", secret, "reset", valid, now, "", ErrInvalid},
+		{"Tampered", secret, "verify", "x" + valid, now, "", ErrInvalid},
+		{"Malformed", secret, "verify", "nodot", now, "", ErrInvalid},
+		{"Empty", secret, "verify", "", now, "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.secret, tt.purpose, tt.token, tt.now)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v; got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want subject %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
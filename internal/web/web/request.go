@@ -1,8 +1,13 @@
 package web
 
 import (
+	"net"
 	"net/http"
+	"strings"
 
+	"github.com/pkg/errors"
+
+	"github.com/sophiabrandt/go-maybe-list/internal/data/workspace"
 	"github.com/sophiabrandt/go-maybe-list/internal/env"
 )
 
@@ -11,6 +16,12 @@ func ParamByName(r *http.Request, name string) string {
 	return r.PathValue(name)
 }
 
+// RequestID returns the ID of the current request.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(ContextKeyRequestID).(string)
+	return id
+}
+
 // IsAuthenticated checks the current request for an authenticated user.
 func IsAuthenticated(e *env.Env, r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(ContextKeyIsAuthenticated).(bool)
@@ -19,3 +30,119 @@ func IsAuthenticated(e *env.Env, r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// IsAdmin checks if the authenticated user of the current request is an admin.
+func IsAdmin(r *http.Request) bool {
+	isAdmin, _ := r.Context().Value(ContextKeyIsAdmin).(bool)
+	return isAdmin
+}
+
+// Workspaces returns the workspaces of the authenticated user.
+func Workspaces(r *http.Request) workspace.Infos {
+	workspaces, _ := r.Context().Value(ContextKeyWorkspaces).(workspace.Infos)
+	return workspaces
+}
+
+// CurrentWorkspace returns the workspace the authenticated user has switched to
+// or nil for the personal space.
+func CurrentWorkspace(r *http.Request) *workspace.Info {
+	ws, _ := r.Context().Value(ContextKeyCurrentWorkspace).(*workspace.Info)
+	return ws
+}
+
+// CurrentWorkspaceID returns the ID of the current workspace or an empty
+// string for the personal space.
+func CurrentWorkspaceID(r *http.Request) string {
+	if ws := CurrentWorkspace(r); ws != nil {
+		return ws.ID
+	}
+	return ""
+}
+
+// ClientIP returns the IP address of the client that sent the request, as
+// resolved by the RealIP middleware, or the address of the connection.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ContextKeyClientIP).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the connection of the request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ForwardedFor returns the IP address of the client behind the trusted
+// proxies: the rightmost address in X-Forwarded-For that is not a trusted
+// proxy. Clients can set the header freely, so it is only read if the request
+// comes from a trusted proxy, and addresses left of the first untrusted one are
+// never used. Without trusted proxies it returns the address of the connection.
+func ForwardedFor(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !contains(trusted, ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// the proxies only append valid addresses, stop at the last one we can trust
+			return ip
+		}
+		ip = hop
+		if !contains(trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// contains reports whether the IP address is in one of the networks.
+func contains(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses a comma separated list of CIDR networks. A plain IP
+// address is a network with only that address.
+func ParseNetworks(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address %q", part)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %q", part)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
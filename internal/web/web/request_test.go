@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestForwardedFor(t *testing.T) {
+	trusted, err := ParseNetworks("127.0.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		header  []string
+		trusted bool
+		want    string
+	}{
+		{"NoProxies", "127.0.0.1:1234", []string{"203.0.113.7"}, false, "127.0.0.1"},
+		{"UntrustedRemote", "198.51.100.1:1234", []string{"203.0.113.7"}, true, "198.51.100.1"},
+		{"NoHeader", "127.0.0.1:1234", nil, true, "127.0.0.1"},
+		{"Client", "127.0.0.1:1234", []string{"203.0.113.7"}, true, "203.0.113.7"},
+		{"Spoofed", "127.0.0.1:1234", []string{"1.2.3.4, 203.0.113.7"}, true, "203.0.113.7"},
+		{"ProxyChain", "127.0.0.1:1234", []string{"203.0.113.7, 10.0.0.2"}, true, "203.0.113.7"},
+		{"SeveralHeaders", "127.0.0.1:1234", []string{"1.2.3.4", "203.0.113.7"}, true, "203.0.113.7"},
+		{"OnlyProxies", "127.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, true, "10.0.0.3"},
+		{"Invalid", "127.0.0.1:1234", []string{"203.0.113.7, unknown, 10.0.0.2"}, true, "10.0.0.2"},
+		{"IPv6", "127.0.0.1:1234", []string{"2001:db8::1"}, true, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = tt.remote
+			for _, h := range tt.header {
+				r.Header.Add("X-Forwarded-For", h)
+			}
+			networks := trusted
+			if !tt.trusted {
+				networks = nil
+			}
+
+			if got := ForwardedFor(r, networks); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks(" 127.0.0.1, ::1 ,10.0.0.0/8,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("want 3 networks; got %v", networks)
+	}
+	for i, want := range []string{"127.0.0.1/32", "::1/128", "10.0.0.0/8"} {
+		if networks[i].String() != want {
+			t.Errorf("network %d: want %q; got %q", i, want, networks[i])
+		}
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "localhost"} {
+		if _, err := ParseNetworks(s); err == nil {
+			t.Errorf("want an error for %q", s)
+		}
+	}
+}
@@ -19,7 +19,13 @@ func addDefaultData(e *env.Env, r *http.Request, dt *data.TemplateData) *data.Te
 	dt.CurrentYear = time.Now().Year()
 	dt.Flash = e.Session.PopString(r.Context(), "flash")
 	dt.IsAuthenticated = IsAuthenticated(e, r)
+	dt.IsAdmin = IsAdmin(r)
+	dt.Workspaces = Workspaces(r)
+	dt.CurrentWorkspace = CurrentWorkspace(r)
 	dt.CSRFToken = nosurf.Token(r)
+	if e.OIDC != nil {
+		dt.SSOName = e.OIDC.Name
+	}
 
 	return dt
 }
@@ -34,7 +40,9 @@ func Render(e *env.Env, w http.ResponseWriter, r *http.Request, tmpl string, dt
 		}
 
 		buf := new(bytes.Buffer)
+		start := time.Now()
 		err := ts.Execute(buf, addDefaultData(e, r, d))
+		e.Metrics.ObserveRender(tmpl, time.Since(start))
 		if err != nil {
 			return StatusError{err, http.StatusInternalServerError}
 		}
@@ -43,11 +51,21 @@ func Render(e *env.Env, w http.ResponseWriter, r *http.Request, tmpl string, dt
 		buf.WriteTo(w)
 
 	case error:
-		http.Error(w, d.Error(), statusCode)
+		WriteError(w, r, d.Error(), statusCode)
 		return nil
 
 	default:
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		WriteError(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 	return nil
 }
+
+// WriteError replies with a plain text error message like http.Error. The
+// message ends with the ID of the request, so that users can tell it when
+// they report the error and it can be found in the log.
+func WriteError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if id := RequestID(r); id != "" {
+		msg += "\n\nRequest ID: " + id
+	}
+	http.Error(w, msg, code)
+}
@@ -2,6 +2,7 @@
 package web
 
 import (
+	"log/slog"
 	"net/http"
 	"path/filepath"
 
@@ -10,7 +11,14 @@ import (
 
 type contextKey string
 
-const ContextKeyIsAuthenticated = contextKey("isAuthenticated")
+const (
+	ContextKeyRequestID        = contextKey("requestID")
+	ContextKeyClientIP         = contextKey("clientIP")
+	ContextKeyIsAuthenticated  = contextKey("isAuthenticated")
+	ContextKeyIsAdmin          = contextKey("isAdmin")
+	ContextKeyWorkspaces       = contextKey("workspaces")
+	ContextKeyCurrentWorkspace = contextKey("currentWorkspace")
+)
 
 // Error represents a handler error. It provides methods for a HTTP status
 // code and embeds the built-in error interface.
@@ -48,13 +56,17 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		switch e := err.(type) {
 		case Error:
 			// We can retrieve the status here and write out a specific
-			// HTTP status code.
-			h.E.Log.Printf("HTTP %d - %s", e.Status(), e)
+			// HTTP status code. Client errors are not errors of the app.
+			level := slog.LevelWarn
+			if e.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			h.E.Log.Log(r.Context(), level, "handler error", "status", e.Status(), "err", e)
 			Render(h.E, w, r, "", e, e.Status())
 		default:
 			// Any error types we don't specifically look out for default
 			// to serving a HTTP 500
-			h.E.Log.Printf("HTTP 500 - %s", e)
+			h.E.Log.ErrorContext(r.Context(), "handler error", "status", http.StatusInternalServerError, "err", e)
 			Render(h.E, w, r, "", http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		}
 	}